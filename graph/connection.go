@@ -0,0 +1,52 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/url"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// retrieveUserConnection retrieve a page of the followers or following of the user through the REST handler
+func (r *Resolver) retrieveUserConnection(ctx context.Context, login string, relation string, first *int, after *string) (*model.GithubUserConnection, error) {
+	query := url.Values{}
+	setPageArgs(query, first, after)
+	target := fmt.Sprintf("/users/%s/%s?%s", url.PathEscape(login), relation, query.Encode())
+	return userConnectionFromHandler(ctx, r.RetrieveUserConnectionHandler, target)
+}
+
+// setPageArgs set the first and after query values (if specified)
+func setPageArgs(query url.Values, first *int, after *string) {
+	if first != nil {
+		query.Set("first", fmt.Sprint(*first))
+	}
+	if after != nil {
+		query.Set("after", *after)
+	}
+}
+
+// userConnectionFromHandler call the REST handler returning ResultRetrieveUserConnection and return its connection
+func userConnectionFromHandler(ctx context.Context, handler func(w http.ResponseWriter, r *http.Request), target string) (*model.GithubUserConnection, error) {
+	// Parse json response (string) to ResultRetrieveUserConnection
+	jsonResponseData := &model.ResultRetrieveUserConnection{}
+	err := callHandler(ctx, handler, target, jsonResponseData)
+	if err != nil {
+		return nil, err
+	}
+	if jsonResponseData.Connection == nil {
+		if len(jsonResponseData.Errors) > 0 {
+			return nil, errors.New(jsonResponseData.Errors[0].Message)
+		}
+		return nil, nil
+	}
+
+	// Users of the page that could not be retrieved are reported as errors along with the partial connection
+	for _, eachError := range jsonResponseData.Errors {
+		graphql.AddErrorf(ctx, "%s", eachError.Message)
+	}
+	return jsonResponseData.Connection, nil
+}
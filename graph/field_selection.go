@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// isFieldRequested check whether the field at the given path (relative to the field being resolved) is selected in the query
+func isFieldRequested(ctx context.Context, path ...string) bool {
+	return hasField(graphql.GetOperationContext(ctx), graphql.CollectFieldsCtx(ctx, nil), path)
+}
+
+// hasField check whether the collected fields contain the field at the given path
+func hasField(opCtx *graphql.OperationContext, fields []graphql.CollectedField, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	for _, eachField := range fields {
+		if eachField.Name != path[0] {
+			continue
+		}
+
+		if len(path) == 1 || hasField(opCtx, graphql.CollectFields(opCtx, eachField.Selections, nil), path[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+// callHandler call the REST handler with a GET request to target and parse the JSON response into result
+func callHandler(ctx context.Context, handler func(w http.ResponseWriter, r *http.Request), target string, result interface{}) error {
+	_, responseData, err := serveHandler(ctx, handler, http.MethodGet, target)
+	if err != nil {
+		return err
+	}
+
+	// Parse json response (string) to result
+	return json.Unmarshal(responseData, result)
+}
+
+// callAdminHandler call the admin REST handler with a request of the method to target and parse the JSON response into result,
+// the detail of the problem responded on failure is returned as error
+func callAdminHandler(ctx context.Context, handler func(w http.ResponseWriter, r *http.Request), method string, target string, result interface{}) error {
+	statusCode, responseData, err := serveHandler(ctx, handler, method, target)
+	if err != nil {
+		return err
+	}
+	if statusCode >= http.StatusBadRequest {
+		problem := &struct {
+			Detail string `json:"detail"`
+		}{}
+		json.Unmarshal(responseData, problem)
+		if problem.Detail == "" {
+			problem.Detail = http.StatusText(statusCode)
+		}
+		return errors.New(problem.Detail)
+	}
+
+	// Parse json response (string) to result
+	return json.Unmarshal(responseData, result)
+}
+
+// serveHandler call the REST handler with a request of the method to target and return the status code and the body of the response
+func serveHandler(ctx context.Context, handler func(w http.ResponseWriter, r *http.Request), method string, target string) (int, []byte, error) {
+	responseRecorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, target, nil).WithContext(ctx)
+	handler(responseRecorder, request)
+
+	// Process response
+	response := responseRecorder.Result()
+	defer response.Body.Close()
+	responseData, err := io.ReadAll(response.Body)
+	return response.StatusCode, responseData, err
+}
+
+// adminCacheTarget return the target of the admin cache handler for the cache (every cache when nil) and the key prefix (if specified)
+func adminCacheTarget(cache *string, prefix *string) string {
+	target := "/admin/cache"
+	if cache != nil {
+		target += "/" + url.PathEscape(*cache)
+	}
+	if prefix != nil {
+		target += fmt.Sprintf("?prefix=%s", url.QueryEscape(*prefix))
+	}
+	return target
+}
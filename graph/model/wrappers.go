@@ -4,13 +4,15 @@ import "encoding/json"
 
 // GithubUserInfo github user info wrapper
 type GithubUserInfo struct {
-	Message                   string  `json:"message,omitempty"`
-	Name                      string  `json:"name"`
-	Login                     string  `json:"login"`
-	Company                   string  `json:"company"`
-	Followers                 int     `json:"followers"`
-	PublicRepos               int     `json:"public_repos"`
-	AvgFollowersPerPublicRepo float32 `json:"avg_followers_per_public_repo"`
+	Message                   string           `json:"message,omitempty" yaml:"message,omitempty" xml:"message,omitempty"`
+	Name                      string           `json:"name" yaml:"name" xml:"name"`
+	Login                     string           `json:"login" yaml:"login" xml:"login"`
+	Company                   string           `json:"company" yaml:"company" xml:"company"`
+	Followers                 int              `json:"followers" yaml:"followers" xml:"followers"`
+	Following                 int              `json:"following" yaml:"following" xml:"following"`
+	PublicRepos               int              `json:"public_repos" yaml:"public_repos" xml:"public_repos"`
+	AvgFollowersPerPublicRepo float32          `json:"avg_followers_per_public_repo" yaml:"avg_followers_per_public_repo" xml:"avg_followers_per_public_repo"`
+	RepoStats                 *GithubRepoStats `json:"repo_stats,omitempty" yaml:"repo_stats,omitempty" xml:"repo_stats,omitempty"`
 }
 
 // String GithubUserInfo should comply with server.ICacheable which required String() implementation
@@ -22,9 +24,111 @@ func (i GithubUserInfo) String() string {
 	return string(result)
 }
 
+// GithubRepoInfo github repository info wrapper
+type GithubRepoInfo struct {
+	Name            string `json:"name" yaml:"name" xml:"name"`
+	FullName        string `json:"full_name" yaml:"full_name" xml:"full_name"`
+	Language        string `json:"language" yaml:"language" xml:"language"`
+	Fork            bool   `json:"fork" yaml:"fork" xml:"fork"`
+	StargazersCount int    `json:"stargazers_count" yaml:"stargazers_count" xml:"stargazers_count"`
+	ForksCount      int    `json:"forks_count" yaml:"forks_count" xml:"forks_count"`
+}
+
+// LanguageStat number of repositories and bytes of code of a language
+type LanguageStat struct {
+	Language  string `json:"language" yaml:"language" xml:"language"`
+	RepoCount int    `json:"repo_count" yaml:"repo_count" xml:"repo_count"`
+	Bytes     int    `json:"bytes" yaml:"bytes" xml:"bytes"`
+}
+
+// GithubRepoStats aggregated statistics computed from the repositories of a github user
+type GithubRepoStats struct {
+	TotalStargazers       int             `json:"total_stargazers" yaml:"total_stargazers" xml:"total_stargazers"`
+	TotalForks            int             `json:"total_forks" yaml:"total_forks" xml:"total_forks"`
+	MostStarredRepo       *GithubRepoInfo `json:"most_starred_repo" yaml:"most_starred_repo" xml:"most_starred_repo"`
+	Languages             []*LanguageStat `json:"languages" yaml:"languages" xml:"languages>language"`
+	LanguagesSampledRepos int             `json:"languages_sampled_repos" yaml:"languages_sampled_repos" xml:"languages_sampled_repos"` // repositories inspected for the bytes of the languages, the most starred first
+	LanguagesTruncated    bool            `json:"languages_truncated" yaml:"languages_truncated" xml:"languages_truncated"`             // some repositories were not inspected, the bytes of the languages are partial
+}
+
+// GithubOrganizationInfo github organization info wrapper
+type GithubOrganizationInfo struct {
+	Login       string `json:"login"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Blog        string `json:"blog"`
+	Location    string `json:"location"`
+	Email       string `json:"email"`
+	IsVerified  bool   `json:"is_verified"`
+	Followers   int    `json:"followers"`
+	PublicRepos int    `json:"public_repos"`
+	HTMLURL     string `json:"html_url"`
+}
+
+// String GithubOrganizationInfo should comply with server.ICacheable which required String() implementation
+func (i GithubOrganizationInfo) String() string {
+	result, err := json.Marshal(i)
+	if err != nil {
+		return ""
+	}
+	return string(result)
+}
+
+// GithubOrganizationMembers public members of a github organization
+type GithubOrganizationMembers struct {
+	Login   string   `json:"login"`
+	Members []string `json:"members"`
+}
+
+// String GithubOrganizationMembers should comply with server.ICacheable which required String() implementation
+func (m GithubOrganizationMembers) String() string {
+	result, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(result)
+}
+
+// GithubFollowList logins in the followers or following list of a github user
+type GithubFollowList struct {
+	Login     string   `json:"login"`
+	Logins    []string `json:"logins"`
+	Truncated bool     `json:"truncated"` // the list is longer than the traversal bound
+}
+
+// String GithubFollowList should comply with server.ICacheable which required String() implementation
+func (l GithubFollowList) String() string {
+	result, err := json.Marshal(l)
+	if err != nil {
+		return ""
+	}
+	return string(result)
+}
+
+// PageInfo pagination information of a connection
+type PageInfo struct {
+	HasNextPage bool    `json:"has_next_page"`
+	EndCursor   *string `json:"end_cursor"`
+}
+
+// GithubUserConnection a page of github users
+type GithubUserConnection struct {
+	TotalCount int               `json:"total_count"`
+	Nodes      []*GithubUserInfo `json:"nodes"`
+	PageInfo   *PageInfo         `json:"page_info"`
+}
+
+// MutualFollow two github users following each other
+type MutualFollow struct {
+	Login      string `json:"login"`
+	OtherLogin string `json:"other_login"`
+}
+
 // ResultError error to include in result object
 type ResultError struct {
-	Message string `json:"message"`
+	Message    string `json:"message" yaml:"message" xml:"message"`
+	RequestID  string `json:"request_id,omitempty" yaml:"request_id,omitempty" xml:"request_id,omitempty"` // X-Request-ID of the request the error happened in
+	StatusCode int    `json:"-" yaml:"-" xml:"-"`                                                          // HTTP status matching the error, used to pick the status of a request where every username failed
 }
 
 // String return text representation of the struct
@@ -38,8 +142,8 @@ func (re ResultError) String() string {
 
 // ResultRetrieveUsers result struct when calling retrieveUsers
 type ResultRetrieveUsers struct {
-	Users  []*GithubUserInfo `json:"users"`
-	Errors []*ResultError    `json:"errors"`
+	Users  []*GithubUserInfo `json:"users" yaml:"users" xml:"users>user"`
+	Errors []*ResultError    `json:"errors" yaml:"errors" xml:"errors>error"`
 }
 
 // String return text representation of the struct
@@ -50,3 +154,85 @@ func (rru ResultRetrieveUsers) String() string {
 	}
 	return ""
 }
+
+// ResultRetrieveOrganization result struct when calling retrieveOrganization
+type ResultRetrieveOrganization struct {
+	Organization *GithubOrganizationInfo `json:"organization"`
+	Errors       []*ResultError          `json:"errors"`
+}
+
+// String return text representation of the struct
+func (rro ResultRetrieveOrganization) String() string {
+	bytes, err := json.Marshal(rro)
+	if err == nil {
+		return string(bytes)
+	}
+	return ""
+}
+
+// ResultRetrieveUserConnection result struct when calling retrieveUserConnection
+type ResultRetrieveUserConnection struct {
+	Connection *GithubUserConnection `json:"connection"`
+	Errors     []*ResultError        `json:"errors"`
+}
+
+// String return text representation of the struct
+func (rruc ResultRetrieveUserConnection) String() string {
+	bytes, err := json.Marshal(rruc)
+	if err == nil {
+		return string(bytes)
+	}
+	return ""
+}
+
+// ResultRelationships result struct when calling relationships
+type ResultRelationships struct {
+	Logins          []string        `json:"logins"`
+	MutualFollows   []*MutualFollow `json:"mutual_follows"`
+	CommonFollowers []string        `json:"common_followers"`
+	CommonFollowing []string        `json:"common_following"`
+	Truncated       bool            `json:"truncated"` // at least one follow list is longer than the traversal bound
+	Errors          []*ResultError  `json:"errors"`
+}
+
+// String return text representation of the struct
+func (rr ResultRelationships) String() string {
+	bytes, err := json.Marshal(rr)
+	if err == nil {
+		return string(bytes)
+	}
+	return ""
+}
+
+// CachePartitionKeys keys of the fresh entries of a cache partition
+type CachePartitionKeys struct {
+	ID   string   `json:"id"`
+	Keys []string `json:"keys"`
+}
+
+// CacheKeys keys of a cache by partition
+type CacheKeys struct {
+	Name       string                `json:"name"`
+	Partitions []*CachePartitionKeys `json:"partitions"`
+}
+
+// ResultCacheKeys result struct when listing the cache keys
+type ResultCacheKeys struct {
+	Caches []*CacheKeys `json:"caches"`
+}
+
+// CacheEntry a cached value with its age
+type CacheEntry struct {
+	Cache      string          `json:"cache"`
+	Key        string          `json:"key"`
+	Partition  string          `json:"partition"`
+	StoredAt   string          `json:"stored_at"`            // RFC 3339 time the value was stored
+	ExpiresAt  *string         `json:"expires_at,omitempty"` // RFC 3339 time the value expires, nil when it never expires
+	AgeSeconds float64         `json:"age_seconds"`
+	Value      json.RawMessage `json:"value"`
+}
+
+// ResultCachePurge result struct when deleting cache entries
+type ResultCachePurge struct {
+	Deleted int `json:"deleted"` // number of fresh entries deleted
+}
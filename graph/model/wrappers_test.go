@@ -105,3 +105,129 @@ func TestResultRetrieveUsersToString(t *testing.T) {
 		})
 	}
 }
+
+func TestGithubOrganizationInfoToString(t *testing.T) {
+	tests := map[string]struct {
+		Input *GithubOrganizationInfo
+	}{
+		"GithubOrganizationInfo": {
+			Input: &GithubOrganizationInfo{
+				Login:       "A",
+				Name:        "B",
+				Description: "C",
+				Followers:   3,
+				PublicRepos: 10,
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result := test.Input.String()
+			expectedBytes, _ := json.Marshal(test.Input)
+			expected := string(expectedBytes)
+			if result != expected {
+				t.Errorf("expected %v, got %v", expected, result)
+			}
+		})
+	}
+}
+
+func TestResultRetrieveOrganizationToString(t *testing.T) {
+	tests := map[string]struct {
+		Input *ResultRetrieveOrganization
+	}{
+		"ResultRetrieveOrganization": {
+			Input: &ResultRetrieveOrganization{
+				Organization: &GithubOrganizationInfo{
+					Login: "A",
+					Name:  "B",
+				},
+				Errors: []*ResultError{
+					{
+						Message: "A",
+					},
+				},
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result := test.Input.String()
+			expectedBytes, _ := json.Marshal(test.Input)
+			expected := string(expectedBytes)
+			if result != expected {
+				t.Errorf("expected %v, got %v", expected, result)
+			}
+		})
+	}
+}
+
+func TestResultRetrieveUserConnectionToString(t *testing.T) {
+	endCursor := "A"
+	tests := map[string]struct {
+		Input *ResultRetrieveUserConnection
+	}{
+		"ResultRetrieveUserConnection": {
+			Input: &ResultRetrieveUserConnection{
+				Connection: &GithubUserConnection{
+					TotalCount: 3,
+					Nodes: []*GithubUserInfo{
+						{
+							Login: "A",
+						},
+					},
+					PageInfo: &PageInfo{
+						HasNextPage: true,
+						EndCursor:   &endCursor,
+					},
+				},
+				Errors: []*ResultError{},
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result := test.Input.String()
+			expectedBytes, _ := json.Marshal(test.Input)
+			expected := string(expectedBytes)
+			if result != expected {
+				t.Errorf("expected %v, got %v", expected, result)
+			}
+		})
+	}
+}
+
+func TestResultRelationshipsToString(t *testing.T) {
+	tests := map[string]struct {
+		Input *ResultRelationships
+	}{
+		"ResultRelationships": {
+			Input: &ResultRelationships{
+				Logins: []string{"A", "B"},
+				MutualFollows: []*MutualFollow{
+					{
+						Login:      "A",
+						OtherLogin: "B",
+					},
+				},
+				CommonFollowers: []string{"C"},
+				CommonFollowing: []string{},
+				Errors:          []*ResultError{},
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result := test.Input.String()
+			expectedBytes, _ := json.Marshal(test.Input)
+			expected := string(expectedBytes)
+			if result != expected {
+				t.Errorf("expected %v, got %v", expected, result)
+			}
+		})
+	}
+}
@@ -9,5 +9,10 @@ import (
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	RetrieveUsersHandler func(w http.ResponseWriter, r *http.Request)
+	RetrieveUsersHandler          func(w http.ResponseWriter, r *http.Request)
+	RetrieveOrganizationHandler   func(w http.ResponseWriter, r *http.Request)
+	RetrieveUserConnectionHandler func(w http.ResponseWriter, r *http.Request)
+	SearchUsersHandler            func(w http.ResponseWriter, r *http.Request)
+	AdminCacheHandler             func(w http.ResponseWriter, r *http.Request)
+	AdminRefreshUserHandler       func(w http.ResponseWriter, r *http.Request)
 }
@@ -6,54 +6,172 @@ package graph
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"machshipgithubapi/graph/model"
 	"net/http"
-	"net/http/httptest"
+	"net/url"
 	"strings"
 )
 
+// Value is the resolver for the value field.
+func (r *cacheEntryResolver) Value(ctx context.Context, obj *model.CacheEntry) (*string, error) {
+	if obj.Value == nil {
+		return nil, nil
+	}
+	result := string(obj.Value)
+	return &result, nil
+}
+
 // AvgFollowersPerPublicRepo is the resolver for the avg_followers_per_public_repo field.
 func (r *githubUserInfoResolver) AvgFollowersPerPublicRepo(ctx context.Context, obj *model.GithubUserInfo) (*float64, error) {
 	result := float64(obj.AvgFollowersPerPublicRepo)
 	return &result, nil
 }
 
+// FollowersConnection is the resolver for the followers_connection field.
+func (r *githubUserInfoResolver) FollowersConnection(ctx context.Context, obj *model.GithubUserInfo, first *int, after *string) (*model.GithubUserConnection, error) {
+	return r.retrieveUserConnection(ctx, obj.Login, "followers", first, after)
+}
+
+// FollowingConnection is the resolver for the following_connection field.
+func (r *githubUserInfoResolver) FollowingConnection(ctx context.Context, obj *model.GithubUserInfo, first *int, after *string) (*model.GithubUserConnection, error) {
+	return r.retrieveUserConnection(ctx, obj.Login, "following", first, after)
+}
+
+// DeleteCacheEntry is the resolver for the deleteCacheEntry field.
+func (r *mutationResolver) DeleteCacheEntry(ctx context.Context, cache string, key string) (*model.ResultCachePurge, error) {
+	target := fmt.Sprintf("/admin/cache/%s/%s", url.PathEscape(cache), url.PathEscape(key))
+
+	// Parse json response (string) to ResultCachePurge
+	jsonResponseData := &model.ResultCachePurge{}
+	err := callAdminHandler(ctx, r.AdminCacheHandler, http.MethodDelete, target, jsonResponseData)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResponseData, nil
+}
+
+// PurgeCache is the resolver for the purgeCache field.
+func (r *mutationResolver) PurgeCache(ctx context.Context, cache *string, prefix *string) (*model.ResultCachePurge, error) {
+	target := adminCacheTarget(cache, prefix)
+
+	// Parse json response (string) to ResultCachePurge
+	jsonResponseData := &model.ResultCachePurge{}
+	err := callAdminHandler(ctx, r.AdminCacheHandler, http.MethodDelete, target, jsonResponseData)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResponseData, nil
+}
+
+// RefreshUser is the resolver for the refreshUser field.
+func (r *mutationResolver) RefreshUser(ctx context.Context, username string) (*model.GithubUserInfo, error) {
+	target := fmt.Sprintf("/admin/refresh/%s", url.PathEscape(username))
+
+	// Parse json response (string) to GithubUserInfo
+	jsonResponseData := &model.GithubUserInfo{}
+	err := callAdminHandler(ctx, r.AdminRefreshUserHandler, http.MethodPost, target, jsonResponseData)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResponseData, nil
+}
+
 // RetrieveUsers is the resolver for the retrieveUsers field.
 func (r *queryResolver) RetrieveUsers(ctx context.Context, usernames []*string) (*model.ResultRetrieveUsers, error) {
-	responseRecorder := httptest.NewRecorder()
-
 	// Create compatible []string from usernames []*string
 	usernamesStr := make([]string, len(usernames))
 	for i, eachUsernamePointer := range usernames {
 		usernamesStr[i] = *eachUsernamePointer
 	}
-	target := fmt.Sprintf("/retrieveUsers?usernames=%v", strings.Join(usernamesStr, ","))
-	request := httptest.NewRequest(http.MethodGet, target, nil)
-	r.RetrieveUsersHandler(responseRecorder, request)
+	target := fmt.Sprintf("/retrieveUsers?usernames=%v", url.QueryEscape(strings.Join(usernamesStr, ",")))
+	if isFieldRequested(ctx, "users", "repo_stats") {
+		// Repository statistics cost extra API calls, only compute them when the query select them
+		target += "&includeRepoStats=true"
+	}
 
-	// Process response
-	response := responseRecorder.Result()
-	defer response.Body.Close()
-	responseData, err := io.ReadAll(response.Body)
+	// Parse json response (string) to ResultRetrieveUsers
+	jsonResponseData := &model.ResultRetrieveUsers{}
+	err := callHandler(ctx, r.RetrieveUsersHandler, target, jsonResponseData)
+	if jsonResponseData.Users == nil {
+		// Whole request failures are problem details carrying only the errors
+		jsonResponseData.Users = make([]*model.GithubUserInfo, 0)
+	}
+	return jsonResponseData, err
+}
+
+// Organization is the resolver for the organization field.
+func (r *queryResolver) Organization(ctx context.Context, login string) (*model.GithubOrganizationInfo, error) {
+	target := fmt.Sprintf("/orgs/%s", url.PathEscape(login))
 
+	// Parse json response (string) to ResultRetrieveOrganization
+	jsonResponseData := &model.ResultRetrieveOrganization{}
+	err := callHandler(ctx, r.RetrieveOrganizationHandler, target, jsonResponseData)
 	if err != nil {
 		return nil, err
 	}
+	if len(jsonResponseData.Errors) > 0 {
+		return nil, errors.New(jsonResponseData.Errors[0].Message)
+	}
+	return jsonResponseData.Organization, nil
+}
 
-	// Parse json response (string) to ResultRetrieveUsers
-	jsonResponseData := &model.ResultRetrieveUsers{}
-	err = json.Unmarshal([]byte(responseData), &jsonResponseData)
-	return jsonResponseData, err
+// SearchUsers is the resolver for the searchUsers field.
+func (r *queryResolver) SearchUsers(ctx context.Context, query *string, location *string, language *string, minFollowers *int, first *int, after *string) (*model.GithubUserConnection, error) {
+	searchQuery := url.Values{}
+	for name, value := range map[string]*string{"query": query, "location": location, "language": language} {
+		if value != nil {
+			searchQuery.Set(name, *value)
+		}
+	}
+	if minFollowers != nil {
+		searchQuery.Set("minFollowers", fmt.Sprint(*minFollowers))
+	}
+	setPageArgs(searchQuery, first, after)
+	target := fmt.Sprintf("/search/users?%s", searchQuery.Encode())
+	return userConnectionFromHandler(ctx, r.SearchUsersHandler, target)
 }
 
+// CacheKeys is the resolver for the cacheKeys field.
+func (r *queryResolver) CacheKeys(ctx context.Context, cache *string, prefix *string) ([]*model.CacheKeys, error) {
+	target := adminCacheTarget(cache, prefix)
+
+	// Parse json response (string) to ResultCacheKeys
+	jsonResponseData := &model.ResultCacheKeys{}
+	err := callAdminHandler(ctx, r.AdminCacheHandler, http.MethodGet, target, jsonResponseData)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResponseData.Caches, nil
+}
+
+// CacheEntry is the resolver for the cacheEntry field.
+func (r *queryResolver) CacheEntry(ctx context.Context, cache string, key string) (*model.CacheEntry, error) {
+	target := fmt.Sprintf("/admin/cache/%s/%s", url.PathEscape(cache), url.PathEscape(key))
+
+	// Parse json response (string) to CacheEntry
+	jsonResponseData := &model.CacheEntry{}
+	err := callAdminHandler(ctx, r.AdminCacheHandler, http.MethodGet, target, jsonResponseData)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResponseData, nil
+}
+
+// CacheEntry returns CacheEntryResolver implementation.
+func (r *Resolver) CacheEntry() CacheEntryResolver { return &cacheEntryResolver{r} }
+
 // GithubUserInfo returns GithubUserInfoResolver implementation.
 func (r *Resolver) GithubUserInfo() GithubUserInfoResolver { return &githubUserInfoResolver{r} }
 
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+type cacheEntryResolver struct{ *Resolver }
 type githubUserInfoResolver struct{ *Resolver }
+type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
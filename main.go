@@ -1,18 +1,30 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"machshipgithubapi/server"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 const (
-	defaultPort          = 8777
-	defaultGithubAPIURL  = "https://api.github.com"
-	defaultGithubAPIUser = "users"
+	defaultPort             = 8777
+	defaultGithubAPIURL     = "https://api.github.com"
+	defaultGithubAPIUser    = "users"
+	defaultGithubAPIOrg     = "orgs"
+	defaultGithubGraphQLURL = "https://api.github.com/graphql"
 )
 
+// version of the server reported by /status, set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
 	portEnv := os.Getenv("PORT")
 	port := defaultPort
@@ -30,10 +42,187 @@ func main() {
 		githubAPIUser = defaultGithubAPIUser
 	}
 
-	config := server.NewServerConfig("", port, githubAPIURL, githubAPIUser)
+	githubAPIOrg := os.Getenv("GITHUB_API_ORG")
+	if githubAPIOrg == "" {
+		githubAPIOrg = defaultGithubAPIOrg
+	}
+
+	githubUpstream := os.Getenv("GITHUB_UPSTREAM")
+	if githubUpstream == "" {
+		githubUpstream = server.GITHUB_UPSTREAM_REST
+	}
+
+	githubGraphQLURL := os.Getenv("GITHUB_GRAPHQL_URL")
+	if githubGraphQLURL == "" {
+		githubGraphQLURL = defaultGithubGraphQLURL
+	}
+
+	config := server.NewServerConfig("", port, githubAPIURL, githubAPIUser).
+		WithGithubAPIOrg(githubAPIOrg).
+		WithGithubToken(os.Getenv("GITHUB_TOKEN")).
+		WithAdminToken(os.Getenv("ADMIN_TOKEN")).
+		WithGithubUpstream(githubUpstream).
+		WithGithubGraphQLURL(githubGraphQLURL).
+		WithVersion(version)
+
+	legacyRoutesSunsetEnv := os.Getenv("LEGACY_ROUTES_SUNSET")
+	if legacyRoutesSunsetEnv != "" {
+		legacyRoutesSunset, err := time.Parse("2006-01-02", legacyRoutesSunsetEnv)
+		if err != nil {
+			log.Fatalln("LEGACY_ROUTES_SUNSET is not a valid date", err)
+		}
+		config.WithLegacyRoutesSunset(legacyRoutesSunset)
+	}
+	cacheTTLEnv := os.Getenv("CACHE_TTL")
+	if cacheTTLEnv != "" {
+		cacheTTL, err := time.ParseDuration(cacheTTLEnv)
+		if err != nil {
+			log.Fatalln("CACHE_TTL is not a valid duration", err)
+		}
+		config.WithCacheTTL(cacheTTL)
+	}
+	config.WithCacheSnapshotPath(os.Getenv("CACHE_SNAPSHOT_PATH"))
+	cacheSnapshotIntervalEnv := os.Getenv("CACHE_SNAPSHOT_INTERVAL")
+	if cacheSnapshotIntervalEnv != "" {
+		cacheSnapshotInterval, err := time.ParseDuration(cacheSnapshotIntervalEnv)
+		if err != nil || cacheSnapshotInterval < 0 {
+			log.Fatalln("CACHE_SNAPSHOT_INTERVAL is not a valid duration", cacheSnapshotIntervalEnv)
+		}
+		config.WithCacheSnapshotInterval(cacheSnapshotInterval)
+	}
+	cacheBackendEnv := os.Getenv("CACHE_BACKEND")
+	if cacheBackendEnv != "" {
+		if cacheBackendEnv != server.CACHE_BACKEND_MEMORY && cacheBackendEnv != server.CACHE_BACKEND_REDIS {
+			log.Fatalln("CACHE_BACKEND is not a valid backend, expected memory or redis", cacheBackendEnv)
+		}
+		config.WithCacheBackend(cacheBackendEnv)
+	}
+	redisURLEnv := os.Getenv("REDIS_URL")
+	if redisURLEnv != "" {
+		redisOptions, err := server.ParseRedisURL(redisURLEnv)
+		if err != nil {
+			log.Fatalln("REDIS_URL is not a valid redis URL", err)
+		}
+		config.WithRedisOptions(redisOptions)
+	}
+	clusterSelfURLEnv := os.Getenv("CLUSTER_SELF_URL")
+	if clusterSelfURLEnv != "" {
+		if !validHTTPURL(clusterSelfURLEnv) {
+			log.Fatalln("CLUSTER_SELF_URL is not a valid http(s) URL", clusterSelfURLEnv)
+		}
+		config.WithClusterSelfURL(clusterSelfURLEnv)
+	}
+	clusterPeersEnv := os.Getenv("CLUSTER_PEERS")
+	if clusterPeersEnv != "" {
+		clusterPeers := splitList(clusterPeersEnv)
+		for _, eachPeer := range clusterPeers {
+			if !validHTTPURL(eachPeer) {
+				log.Fatalln("CLUSTER_PEERS is not a list of valid http(s) URLs", eachPeer)
+			}
+		}
+		config.WithClusterPeers(clusterPeers)
+	}
+	clusterHeartbeatIntervalEnv := os.Getenv("CLUSTER_HEARTBEAT_INTERVAL")
+	if clusterHeartbeatIntervalEnv != "" {
+		clusterHeartbeatInterval, err := time.ParseDuration(clusterHeartbeatIntervalEnv)
+		if err != nil || clusterHeartbeatInterval < 0 {
+			log.Fatalln("CLUSTER_HEARTBEAT_INTERVAL is not a valid duration", clusterHeartbeatIntervalEnv)
+		}
+		config.WithClusterHeartbeatInterval(clusterHeartbeatInterval)
+	}
+	clusterTokenEnv := os.Getenv("CLUSTER_TOKEN")
+	if clusterSelfURLEnv != "" && clusterTokenEnv == "" {
+		log.Fatalln("CLUSTER_TOKEN is required when CLUSTER_SELF_URL is set, the replicas authenticate each other with it")
+	}
+	config.WithClusterToken(clusterTokenEnv)
+	corsAllowedOriginsEnv := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if corsAllowedOriginsEnv != "" {
+		config.WithCORSAllowedOrigins(splitList(corsAllowedOriginsEnv))
+	}
+	corsAllowedMethodsEnv := os.Getenv("CORS_ALLOWED_METHODS")
+	if corsAllowedMethodsEnv != "" {
+		config.WithCORSAllowedMethods(splitList(strings.ToUpper(corsAllowedMethodsEnv)))
+	}
+	corsAllowedHeadersEnv := os.Getenv("CORS_ALLOWED_HEADERS")
+	if corsAllowedHeadersEnv != "" {
+		config.WithCORSAllowedHeaders(splitList(corsAllowedHeadersEnv))
+	}
+	corsAllowCredentialsEnv := os.Getenv("CORS_ALLOW_CREDENTIALS")
+	if corsAllowCredentialsEnv != "" {
+		corsAllowCredentials, err := strconv.ParseBool(corsAllowCredentialsEnv)
+		if err != nil {
+			log.Fatalln("CORS_ALLOW_CREDENTIALS is not a valid boolean", err)
+		}
+		config.WithCORSAllowCredentials(corsAllowCredentials)
+	}
+	corsMaxAgeEnv := os.Getenv("CORS_MAX_AGE")
+	if corsMaxAgeEnv != "" {
+		corsMaxAge, err := time.ParseDuration(corsMaxAgeEnv)
+		if err != nil {
+			log.Fatalln("CORS_MAX_AGE is not a valid duration", err)
+		}
+		config.WithCORSMaxAge(corsMaxAge)
+	}
+	logLevelEnv := os.Getenv("LOG_LEVEL")
+	if logLevelEnv != "" {
+		logLevel, err := server.ParseLogLevel(logLevelEnv)
+		if err != nil {
+			log.Fatalln("LOG_LEVEL is not a valid level", err)
+		}
+		config.WithLogLevel(logLevel)
+	}
+	otlpEndpointEnv := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if otlpEndpointEnv != "" {
+		if !validHTTPURL(otlpEndpointEnv) {
+			log.Fatalln("OTEL_EXPORTER_OTLP_ENDPOINT is not a valid http(s) URL", otlpEndpointEnv)
+		}
+		config.WithOTLPEndpoint(otlpEndpointEnv)
+	}
+	shutdownGracePeriodEnv := os.Getenv("SHUTDOWN_GRACE_PERIOD")
+	if shutdownGracePeriodEnv != "" {
+		shutdownGracePeriod, err := time.ParseDuration(shutdownGracePeriodEnv)
+		if err != nil {
+			log.Fatalln("SHUTDOWN_GRACE_PERIOD is not a valid duration", err)
+		}
+		config.WithShutdownGracePeriod(shutdownGracePeriod)
+	}
 	s := server.NewServer(config)
+
+	// Drain the server on SIGTERM or SIGINT, a second signal exit immediately
+	shutdownDone := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		<-signals
+		signal.Stop(signals)
+		err := s.Shutdown()
+		if err != nil {
+			log.Println("Server.Shutdown encounter error", err)
+		}
+		close(shutdownDone)
+	}()
+
 	err := s.Serve()
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalln("Server.Serve encounter error", err)
 	}
+	<-shutdownDone
+}
+
+// splitList split a comma separated environment variable into its trimmed non empty items
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, eachItem := range strings.Split(value, ",") {
+		eachItem = strings.TrimSpace(eachItem)
+		if eachItem != "" {
+			items = append(items, eachItem)
+		}
+	}
+	return items
+}
+
+// validHTTPURL check whether the value is an absolute http(s) URL
+func validHTTPURL(value string) bool {
+	parsedURL, err := url.Parse(value)
+	return err == nil && (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
 }
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// adminContextKey key of whether the GraphQL request calling the admin handlers is authenticated
+	adminContextKey contextKey = "admin"
+)
+
+// adminCache type independent view of a cache for the admin API
+type adminCache struct {
+	Name         string
+	Keys         func(prefix string) []ServerCachePartitionKeys
+	Entry        func(key string) (*model.CacheEntry, error)
+	Delete       func(key string) bool
+	DeletePrefix func(prefix string) int
+}
+
+// newAdminCache return the admin view of the cache
+func newAdminCache[T ICacheable](name string, cache *ServerCache[T]) *adminCache {
+	return &adminCache{
+		Name: name,
+		Keys: cache.Keys,
+		Entry: func(key string) (*model.CacheEntry, error) {
+			entry := cache.GetEntry(key)
+			if entry == nil {
+				return nil, nil
+			}
+			value, err := json.Marshal(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			cacheEntry := &model.CacheEntry{
+				Cache:      name,
+				Key:        key,
+				Partition:  cache.PartitionID(key),
+				StoredAt:   entry.StoredAt.UTC().Format(time.RFC3339Nano),
+				AgeSeconds: time.Since(entry.StoredAt).Seconds(),
+				Value:      value,
+			}
+			if !entry.ExpiresAt.IsZero() {
+				expiresAt := entry.ExpiresAt.UTC().Format(time.RFC3339Nano)
+				cacheEntry.ExpiresAt = &expiresAt
+			}
+			return cacheEntry, nil
+		},
+		Delete:       cache.Delete,
+		DeletePrefix: cache.DeletePrefix,
+	}
+}
+
+// adminCaches return the caches of the server managed by the admin API
+func (s *Server) adminCaches() []*adminCache {
+	return []*adminCache{
+		newAdminCache(CACHE_USER_INFO, s.githubUserInfoCache),
+		newAdminCache(CACHE_ORG_INFO, s.githubOrgInfoCache),
+		newAdminCache(CACHE_ORG_MEMBERS, s.githubOrgMembersCache),
+		newAdminCache(CACHE_FOLLOW_LIST, s.githubFollowListCache),
+	}
+}
+
+// adminTokenValid check whether the Authorization header of the request carries the admin token as bearer token
+func (s *Server) adminTokenValid(r *http.Request) bool {
+	return bearerTokenValid(r, s.config.adminToken)
+}
+
+// bearerTokenValid check whether the Authorization header of the request carries the token as bearer token
+func bearerTokenValid(r *http.Request, expectedToken string) bool {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(expectedToken)) == 1
+}
+
+// authenticateAdmin check whether the request is authenticated with the admin token, directly or through the GraphQL request calling the handler,
+// otherwise write 403 when the admin API is disabled (no admin token configured) or 401 and return false
+func (s *Server) authenticateAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.config.adminToken == "" {
+		writeProblem(w, r, http.StatusForbidden, "admin API is disabled, no admin token is configured", nil)
+		return false
+	}
+	if authenticated, _ := r.Context().Value(adminContextKey).(bool); authenticated || s.adminTokenValid(r) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+	writeProblem(w, r, http.StatusUnauthorized, "admin token is missing or invalid", nil)
+	return false
+}
+
+// adminGraphQLHandler keep whether the GraphQL request is authenticated with the admin token in its context, for the admin handlers called by the resolvers
+func (s *Server) adminGraphQLHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated := s.config.adminToken != "" && s.adminTokenValid(r)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey, authenticated)))
+	})
+}
+
+// adminCache handling /admin/cache[/{cache}[/{key}]]:
+// GET list the keys of each partition (starting with the prefix query value) or get an entry with its age,
+// DELETE delete an entry or purge the entries starting with the prefix query value (every entry when empty)
+func (s *Server) adminCache(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead, http.MethodDelete) {
+		return
+	}
+	if !s.authenticateAdmin(w, r) {
+		return
+	}
+	// Admin responses are never cached, including by the GraphQL query calling the handler
+	w.Header().Set("Cache-Control", "no-store")
+	uncacheable := newCacheFreshness()
+	uncacheable.setUncacheable()
+	recordCacheFreshness(r.Context(), uncacheable)
+
+	name, key, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/cache"), "/"), "/")
+	caches := s.adminCaches()
+	if name != "" {
+		caches = nil
+		for _, eachCache := range s.adminCaches() {
+			if eachCache.Name == name {
+				caches = append(caches, eachCache)
+			}
+		}
+		if len(caches) == 0 {
+			writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("cache %q not found", name), nil)
+			return
+		}
+	}
+	prefix := r.URL.Query().Get("prefix")
+
+	switch {
+	case key == "" && r.Method == http.MethodDelete:
+		resultObj := &model.ResultCachePurge{}
+		for _, eachCache := range caches {
+			resultObj.Deleted += eachCache.DeletePrefix(prefix)
+		}
+		s.logger.info("cache purged", logFields{"request_id": requestIDFromContext(r.Context()), "cache": name, "prefix": prefix, "deleted": resultObj.Deleted})
+		writeJSONResponse(w, r, resultObj)
+	case key == "":
+		resultObj := &model.ResultCacheKeys{
+			Caches: make([]*model.CacheKeys, 0, len(caches)),
+		}
+		for _, eachCache := range caches {
+			cacheKeys := &model.CacheKeys{Name: eachCache.Name}
+			for _, eachPartition := range eachCache.Keys(prefix) {
+				cacheKeys.Partitions = append(cacheKeys.Partitions, &model.CachePartitionKeys{ID: eachPartition.ID, Keys: eachPartition.Keys})
+			}
+			resultObj.Caches = append(resultObj.Caches, cacheKeys)
+		}
+		writeJSONResponse(w, r, resultObj)
+	case r.Method == http.MethodDelete:
+		if !caches[0].Delete(key) {
+			writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("key %q not found in cache %q", key, name), nil)
+			return
+		}
+		s.logger.info("cache entry deleted", logFields{"request_id": requestIDFromContext(r.Context()), "cache": name, "key": key})
+		writeJSONResponse(w, r, &model.ResultCachePurge{Deleted: 1})
+	default:
+		entry, err := caches[0].Entry(key)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, fmt.Sprintf("encounter err when encoding the entry %q of cache %q: %v", key, name, err), nil)
+			return
+		}
+		if entry == nil {
+			writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("key %q not found in cache %q", key, name), nil)
+			return
+		}
+		writeJSONResponse(w, r, entry)
+	}
+}
+
+// adminRefreshUser handling POST /admin/refresh/{username}, drop the cached profile and follow lists of the user and fetch the profile again from github
+func (s *Server) adminRefreshUser(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if !s.authenticateAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	username := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/refresh"), "/")
+	if !githubLoginPattern.MatchString(username) {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("invalid username %q, expected at most 39 alphanumeric characters or hyphens", username), nil)
+		return
+	}
+
+	s.githubFollowListCache.Delete("followers:" + username)
+	s.githubFollowListCache.Delete("following:" + username)
+	userInfo, err := s.refreshUserInfo(s.newGithubClient(r.Context()), username)
+	if err != nil {
+		writeProblem(w, r, upstreamErrorStatus(err), fmt.Sprintf("encounter err when refreshing username %q: %v", username, err), nil)
+		return
+	}
+	s.logger.info("user refreshed", logFields{"request_id": requestIDFromContext(r.Context()), "username": username})
+	if userInfo.Message == GITHUB_API_MESSAGE_USER_NOT_FOUND {
+		writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("username %q not found", username), nil)
+		return
+	}
+	writeJSONResponse(w, r, userInfo)
+}
+
+// refreshUserInfo drop the cached profile of the user and fetch it again from github, in a cluster the replica owning the user refresh it
+// so its cache (shared with the other replicas) does not keep the previous profile, the profile is fetched here when the owner fails
+func (s *Server) refreshUserInfo(client *http.Client, username string) (*model.GithubUserInfo, error) {
+	s.githubUserInfoCache.Delete(username)
+	if s.cluster != nil {
+		if owner := s.cluster.owner(username); owner != s.cluster.self {
+			ctx := githubClientContext(client)
+			ownerResults, err := s.forwardUserInfos(ctx, owner, []string{username}, true)
+			if err == nil {
+				return ownerResults[username].userInfo, ownerResults[username].err
+			}
+			s.logger.warn("encounter err when refreshing the user on its owner, fetching it from github", logFields{"request_id": requestIDFromContext(ctx), "peer": owner, "username": username, "err": err})
+		}
+	}
+	userFetchResult := s.retrieveUserInfosFrom(client, []string{username}, false)[username]
+	return userFetchResult.userInfo, userFetchResult.err
+}
@@ -0,0 +1,394 @@
+package server
+
+import (
+	"encoding/json"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newAdminTestServer return a server with the admin token "secret" whose user info cache hold a, b and notfound, and follow list cache hold followers:a
+func newAdminTestServer(githubAPIURL string) *Server {
+	s := NewServer(NewServerConfig("", 8777, githubAPIURL, "users").WithAdminToken("secret"))
+	s.registerHandlers()
+	s.serverMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/retrieveUsers?usernames=a,b,notfound", nil))
+	s.githubFollowListCache.Set("followers:a", &model.GithubFollowList{Logins: []string{"b"}})
+	return s
+}
+
+// cachedKeys return the keys of the cache listed by the admin API, sorted
+func cachedKeys(resultObj *model.ResultCacheKeys, name string) []string {
+	keys := make([]string, 0)
+	for _, eachCache := range resultObj.Caches {
+		if eachCache.Name != name {
+			continue
+		}
+		for _, eachPartition := range eachCache.Partitions {
+			keys = append(keys, eachPartition.Keys...)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestAdminAuthentication(t *testing.T) {
+	tests := map[string]struct {
+		AdminToken           string
+		Authorization        string
+		ExpectedStatusCode   int
+		ExpectedAuthenticate bool
+	}{
+		"Test admin API disabled": {
+			Authorization:      "Bearer secret",
+			ExpectedStatusCode: http.StatusForbidden,
+		},
+		"Test missing token": {
+			AdminToken:           "secret",
+			ExpectedStatusCode:   http.StatusUnauthorized,
+			ExpectedAuthenticate: true,
+		},
+		"Test invalid token": {
+			AdminToken:           "secret",
+			Authorization:        "Bearer other",
+			ExpectedStatusCode:   http.StatusUnauthorized,
+			ExpectedAuthenticate: true,
+		},
+		"Test basic scheme": {
+			AdminToken:           "secret",
+			Authorization:        "Basic secret",
+			ExpectedStatusCode:   http.StatusUnauthorized,
+			ExpectedAuthenticate: true,
+		},
+		"Test valid token": {
+			AdminToken:         "secret",
+			Authorization:      "bearer secret",
+			ExpectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			s := NewServer(NewServerConfig("", 8777, "http://localhost", "users").WithAdminToken(test.AdminToken))
+			s.registerHandlers()
+			request := httptest.NewRequest(http.MethodGet, "/admin/cache", nil)
+			if test.Authorization != "" {
+				request.Header.Set("Authorization", test.Authorization)
+			}
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Errorf("expected status %v, got %v", test.ExpectedStatusCode, responseRecorder.Code)
+			}
+			if authenticate := responseRecorder.Header().Get("WWW-Authenticate") != ""; authenticate != test.ExpectedAuthenticate {
+				t.Errorf("expected WWW-Authenticate %v, got %q", test.ExpectedAuthenticate, responseRecorder.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAdminCache(t *testing.T) {
+	tests := map[string]struct {
+		Method              string
+		Target              string
+		ExpectedStatusCode  int
+		ExpectedDeleted     int
+		ExpectedUserKeys    []string
+		ExpectedFollowKeys  []string
+		ExpectedEntryLogin  string
+		ExpectedEntryExpiry bool
+	}{
+		"Test list every cache": {
+			Method:             http.MethodGet,
+			Target:             "/admin/cache",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUserKeys:   []string{"a", "b", "notfound"},
+			ExpectedFollowKeys: []string{"followers:a"},
+		},
+		"Test list a cache by prefix": {
+			Method:             http.MethodGet,
+			Target:             "/admin/cache/user_info?prefix=not",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUserKeys:   []string{"notfound"},
+			ExpectedFollowKeys: []string{},
+		},
+		"Test get entry": {
+			Method:              http.MethodGet,
+			Target:              "/admin/cache/user_info/a",
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedEntryLogin:  "a",
+			ExpectedEntryExpiry: true,
+		},
+		"Test get missing entry": {
+			Method:             http.MethodGet,
+			Target:             "/admin/cache/user_info/c",
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+		"Test unknown cache": {
+			Method:             http.MethodGet,
+			Target:             "/admin/cache/unknown",
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+		"Test delete entry": {
+			Method:             http.MethodDelete,
+			Target:             "/admin/cache/user_info/a",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedDeleted:    1,
+			ExpectedUserKeys:   []string{"b", "notfound"},
+			ExpectedFollowKeys: []string{"followers:a"},
+		},
+		"Test delete missing entry": {
+			Method:             http.MethodDelete,
+			Target:             "/admin/cache/follow_list/following:a",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedUserKeys:   []string{"a", "b", "notfound"},
+			ExpectedFollowKeys: []string{"followers:a"},
+		},
+		"Test purge cache by prefix": {
+			Method:             http.MethodDelete,
+			Target:             "/admin/cache/user_info?prefix=not",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedDeleted:    1,
+			ExpectedUserKeys:   []string{"a", "b"},
+			ExpectedFollowKeys: []string{"followers:a"},
+		},
+		"Test purge every cache": {
+			Method:             http.MethodDelete,
+			Target:             "/admin/cache",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedDeleted:    4,
+			ExpectedUserKeys:   []string{},
+			ExpectedFollowKeys: []string{},
+		},
+		"Test method not allowed": {
+			Method:             http.MethodPost,
+			Target:             "/admin/cache",
+			ExpectedStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newCacheTestServer()
+			defer githubAPITestServer.Close()
+			s := newAdminTestServer(githubAPITestServer.URL)
+
+			request := httptest.NewRequest(test.Method, test.Target, nil)
+			request.Header.Set("Authorization", "Bearer secret")
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Fatalf("expected status %v, got %v: %s", test.ExpectedStatusCode, responseRecorder.Code, responseRecorder.Body.String())
+			}
+			if cacheControl := responseRecorder.Header().Get("Cache-Control"); test.ExpectedStatusCode == http.StatusOK && cacheControl != "no-store" {
+				t.Errorf("expected admin responses not to be stored, got %q", cacheControl)
+			}
+
+			if test.Method == http.MethodDelete && test.ExpectedStatusCode == http.StatusOK {
+				purgeResult := &model.ResultCachePurge{}
+				json.Unmarshal(responseRecorder.Body.Bytes(), purgeResult)
+				if purgeResult.Deleted != test.ExpectedDeleted {
+					t.Errorf("expected %v entries deleted, got %v", test.ExpectedDeleted, purgeResult.Deleted)
+				}
+			}
+			if test.ExpectedEntryLogin != "" {
+				entry := &model.CacheEntry{}
+				json.Unmarshal(responseRecorder.Body.Bytes(), entry)
+				userInfo := &model.GithubUserInfo{}
+				json.Unmarshal(entry.Value, userInfo)
+				if entry.Cache != CACHE_USER_INFO || entry.Partition != s.githubUserInfoCache.PartitionID("a") || userInfo.Login != test.ExpectedEntryLogin {
+					t.Errorf("expected entry of %v, got %s", test.ExpectedEntryLogin, responseRecorder.Body.String())
+				}
+				if entry.StoredAt == "" || entry.AgeSeconds < 0 || (entry.ExpiresAt != nil) != test.ExpectedEntryExpiry {
+					t.Errorf("expected entry age and expiry, got %s", responseRecorder.Body.String())
+				}
+			}
+
+			if test.ExpectedUserKeys == nil {
+				return
+			}
+			if test.Method == http.MethodDelete {
+				// List the keys left
+				request = httptest.NewRequest(http.MethodGet, "/admin/cache", nil)
+				request.Header.Set("Authorization", "Bearer secret")
+				responseRecorder = httptest.NewRecorder()
+				s.serverMux.ServeHTTP(responseRecorder, request)
+			}
+			resultObj := &model.ResultCacheKeys{}
+			json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+			if userKeys := cachedKeys(resultObj, CACHE_USER_INFO); strings.Join(userKeys, ",") != strings.Join(test.ExpectedUserKeys, ",") {
+				t.Errorf("expected user info keys %v, got %v", test.ExpectedUserKeys, userKeys)
+			}
+			if followKeys := cachedKeys(resultObj, CACHE_FOLLOW_LIST); strings.Join(followKeys, ",") != strings.Join(test.ExpectedFollowKeys, ",") {
+				t.Errorf("expected follow list keys %v, got %v", test.ExpectedFollowKeys, followKeys)
+			}
+		})
+	}
+}
+
+func TestAdminRefreshUser(t *testing.T) {
+	tests := map[string]struct {
+		Username           string
+		ExpectedStatusCode int
+		ExpectedCalls      int32
+	}{
+		"Test refresh cached user": {
+			Username:           "a",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCalls:      2,
+		},
+		"Test refresh user not found": {
+			Username:           "notfound",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedCalls:      2,
+		},
+		"Test invalid username": {
+			Username:           "in_valid",
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedCalls:      0,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			calls := int32(0)
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := atomic.AddInt32(&calls, 1)
+				login := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+				if login == "notfound" {
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{"message":"Not Found"}`))
+					return
+				}
+				jsonString, _ := json.Marshal(model.GithubUserInfo{Login: login, Followers: int(call)})
+				w.Write(jsonString)
+			}))
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users").WithAdminToken("secret"))
+			s.registerHandlers()
+			s.serverMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/retrieveUsers?usernames="+test.Username, nil))
+			s.githubFollowListCache.Set("followers:"+test.Username, &model.GithubFollowList{})
+
+			request := httptest.NewRequest(http.MethodPost, "/admin/refresh/"+test.Username, nil)
+			request.Header.Set("Authorization", "Bearer secret")
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Fatalf("expected status %v, got %v: %s", test.ExpectedStatusCode, responseRecorder.Code, responseRecorder.Body.String())
+			}
+			if atomic.LoadInt32(&calls) != test.ExpectedCalls {
+				t.Errorf("expected %v upstream calls, got %v", test.ExpectedCalls, calls)
+			}
+			if test.ExpectedStatusCode == http.StatusBadRequest {
+				return
+			}
+
+			// The refreshed profile replaced the cached one and the follow lists were dropped
+			if s.githubFollowListCache.GetEntry("followers:"+test.Username) != nil {
+				t.Errorf("expected the follow list of %v to be dropped", test.Username)
+			}
+			if test.ExpectedStatusCode == http.StatusOK {
+				userInfo := &model.GithubUserInfo{}
+				json.Unmarshal(responseRecorder.Body.Bytes(), userInfo)
+				if userInfo.Followers != 2 {
+					t.Errorf("expected the refreshed profile, got %+v", userInfo)
+				}
+				if cachedUserInfo := s.githubUserInfoCache.Get(test.Username); cachedUserInfo == nil || cachedUserInfo.Followers != 2 {
+					t.Errorf("expected the refreshed profile to be cached, got %+v", cachedUserInfo)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminGraphQL(t *testing.T) {
+	tests := map[string]struct {
+		Query          string
+		Authorization  string
+		ExpectedData   string
+		ExpectedError  string
+		ExpectedCached []string
+	}{
+		"Test list keys": {
+			Query:          `{cacheKeys(cache:"user_info",prefix:"a"){name partitions{keys}}}`,
+			Authorization:  "Bearer secret",
+			ExpectedData:   `"keys":["a"]`,
+			ExpectedCached: []string{"a", "b", "notfound"},
+		},
+		"Test get entry": {
+			Query:          `{cacheEntry(cache:"follow_list",key:"followers:a"){partition age_seconds value}}`,
+			Authorization:  "Bearer secret",
+			ExpectedData:   `\"logins\":[\"b\"]`,
+			ExpectedCached: []string{"a", "b", "notfound"},
+		},
+		"Test delete entry": {
+			Query:          `mutation {deleteCacheEntry(cache:"user_info",key:"b"){deleted}}`,
+			Authorization:  "Bearer secret",
+			ExpectedData:   `{"deleteCacheEntry":{"deleted":1}}`,
+			ExpectedCached: []string{"a", "notfound"},
+		},
+		"Test purge everything": {
+			Query:          `mutation {purgeCache{deleted}}`,
+			Authorization:  "Bearer secret",
+			ExpectedData:   `{"purgeCache":{"deleted":4}}`,
+			ExpectedCached: []string{},
+		},
+		"Test refresh user": {
+			Query:          `mutation {refreshUser(username:"a"){login}}`,
+			Authorization:  "Bearer secret",
+			ExpectedData:   `{"refreshUser":{"login":"a"}}`,
+			ExpectedCached: []string{"a", "b", "notfound"},
+		},
+		"Test mutation without token": {
+			Query:          `mutation {purgeCache(cache:"user_info"){deleted}}`,
+			ExpectedError:  "admin token is missing or invalid",
+			ExpectedCached: []string{"a", "b", "notfound"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newCacheTestServer()
+			defer githubAPITestServer.Close()
+			s := newAdminTestServer(githubAPITestServer.URL)
+
+			body, _ := json.Marshal(map[string]string{"query": test.Query})
+			request := httptest.NewRequest(http.MethodPost, "/graphql/query", strings.NewReader(string(body)))
+			request.Header.Set("Content-Type", "application/json")
+			if test.Authorization != "" {
+				request.Header.Set("Authorization", test.Authorization)
+			}
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+
+			graphQLResponse := &struct {
+				Data   json.RawMessage `json:"data"`
+				Errors []struct {
+					Message string `json:"message"`
+				} `json:"errors"`
+			}{}
+			json.Unmarshal(responseRecorder.Body.Bytes(), graphQLResponse)
+			if test.ExpectedError != "" {
+				if len(graphQLResponse.Errors) != 1 || graphQLResponse.Errors[0].Message != test.ExpectedError {
+					t.Errorf("expected error %q, got %s", test.ExpectedError, responseRecorder.Body.String())
+				}
+			} else if len(graphQLResponse.Errors) > 0 || !strings.Contains(string(graphQLResponse.Data), test.ExpectedData) {
+				t.Errorf("expected data %s, got %s", test.ExpectedData, responseRecorder.Body.String())
+			}
+
+			cached := make([]string, 0)
+			for _, eachPartition := range s.githubUserInfoCache.Keys("") {
+				cached = append(cached, eachPartition.Keys...)
+			}
+			sort.Strings(cached)
+			if strings.Join(cached, ",") != strings.Join(test.ExpectedCached, ",") {
+				t.Errorf("expected cached users %v, got %v", test.ExpectedCached, cached)
+			}
+		})
+	}
+}
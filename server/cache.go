@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"math/big"
 	"strconv"
+	"time"
 
 	"github.com/buraksezer/consistent"
 )
@@ -66,6 +67,31 @@ func NewServerCache[T ICacheable](numberOfCachePartitions int) *ServerCache[T] {
 	}
 }
 
+// WithTTL set how long values are kept in the cache (values never expire when 0), to be called before the cache is used
+func (sc *ServerCache[T]) WithTTL(ttl time.Duration) *ServerCache[T] {
+	for _, eachPartition := range sc.hashRing {
+		eachPartition.ttl = ttl
+	}
+	return sc
+}
+
+// WithBackend set the backend storing the entries of each partition (in memory by default), newBackend is called with the id of each partition,
+// to be called before the cache is used
+func (sc *ServerCache[T]) WithBackend(newBackend func(partitionID string) CacheBackend[T]) *ServerCache[T] {
+	for eachPartitionID, eachPartition := range sc.hashRing {
+		eachPartition.backend = newBackend(eachPartitionID)
+	}
+	return sc
+}
+
+// WithErrorHandler set the function called with the errors of the backend, which are otherwise handled as missing entries, to be called before the cache is used
+func (sc *ServerCache[T]) WithErrorHandler(errorHandler func(err error)) *ServerCache[T] {
+	for _, eachPartition := range sc.hashRing {
+		eachPartition.errorHandler = errorHandler
+	}
+	return sc
+}
+
 // Get get cache value by key
 func (sc *ServerCache[T]) Get(key string) *T {
 	// Find the partitionID associate with the map we need to look for the key
@@ -78,6 +104,18 @@ func (sc *ServerCache[T]) Get(key string) *T {
 	return nil
 }
 
+// GetEntry get cache entry by key, with the time the value was stored and the time it expires (not counted in the hits or misses)
+func (sc *ServerCache[T]) GetEntry(key string) *ServerCacheEntry[T] {
+	// Find the partitionID associate with the map we need to look for the key
+	partitionID := sc.consistentHasher.LocateKey([]byte(key))
+	if partitionID != nil {
+		// Use the partition to get the cache entry
+		cachePartition := sc.hashRing[partitionID.String()]
+		return cachePartition.GetEntry(key)
+	}
+	return nil
+}
+
 // Set set cache value by key
 func (sc *ServerCache[T]) Set(key string, value *T) {
 	// Find the partitionID associate with the map we need to look for the key
@@ -88,3 +126,153 @@ func (sc *ServerCache[T]) Set(key string, value *T) {
 		cachePartition.Set(key, value)
 	}
 }
+
+// SetEntry set cache entry by key with the time its value was stored (now when it is in the future), it expires at the earliest of its expiry
+// and its stored time plus the ttl, return whether it was stored (not when it is expired or the backend fails)
+func (sc *ServerCache[T]) SetEntry(key string, entry *ServerCacheEntry[T]) bool {
+	// Find the partitionID associate with the map we need to look for the key
+	partitionID := sc.consistentHasher.LocateKey([]byte(key))
+	if partitionID != nil {
+		// Use the partition to set the cache entry
+		cachePartition := sc.hashRing[partitionID.String()]
+		return cachePartition.restore(key, entry.Value, entry.StoredAt, entry.ExpiresAt, time.Now())
+	}
+	return false
+}
+
+// GetOrLoad get cache value by key, when there is no fresh value it is loaded by the loader and stored (unless the loader fails or return nil),
+// the concurrent lookups of a key being loaded wait for the value instead of calling the loader again
+func (sc *ServerCache[T]) GetOrLoad(key string, loader func(key string) (*T, error)) (*T, error) {
+	// Find the partitionID associate with the map we need to look for the key
+	partitionID := sc.consistentHasher.LocateKey([]byte(key))
+	if partitionID != nil {
+		// Use the partition to get or load the cache data
+		cachePartition := sc.hashRing[partitionID.String()]
+		return cachePartition.GetOrLoad(key, loader)
+	}
+	return loader(key)
+}
+
+// Delete remove the entry of the key, return whether a fresh entry was removed
+func (sc *ServerCache[T]) Delete(key string) bool {
+	// Find the partitionID associate with the map we need to look for the key
+	partitionID := sc.consistentHasher.LocateKey([]byte(key))
+	if partitionID != nil {
+		// Use the partition to delete the cache entry
+		cachePartition := sc.hashRing[partitionID.String()]
+		return cachePartition.Delete(key)
+	}
+	return false
+}
+
+// DeletePrefix remove the entries whose key start with the prefix from every partition (every entry when empty), return the number of fresh entries removed
+func (sc *ServerCache[T]) DeletePrefix(prefix string) int {
+	deleted := 0
+	for _, eachPartition := range sc.hashRing {
+		deleted += eachPartition.DeletePrefix(prefix)
+	}
+	return deleted
+}
+
+// Len return the number of fresh entries of every partition
+func (sc *ServerCache[T]) Len() int {
+	length := 0
+	for _, eachPartition := range sc.hashRing {
+		length += eachPartition.Len()
+	}
+	return length
+}
+
+// Range call f for each fresh entry, ordered by partition then by key, until f return false, the entries of every partition are
+// a snapshot taken at once when Range is called so f may modify the cache
+func (sc *ServerCache[T]) Range(f func(key string, value *T) bool) {
+	sc.rangeEntries(func(key string, entry *ServerCacheEntry[T]) bool {
+		return f(key, entry.Value)
+	})
+}
+
+// rangeEntries call f for each fresh entry of the snapshot taken at once across partitions, ordered by partition then by key, until f return false
+func (sc *ServerCache[T]) rangeEntries(f func(key string, entry *ServerCacheEntry[T]) bool) {
+	partitions := make([]*ServerCachePartition[T], 0, len(sc.hashRing))
+	for i := 0; i < len(sc.hashRing); i++ {
+		partitions = append(partitions, sc.hashRing[strconv.Itoa(i)])
+	}
+
+	// Hold every in-memory partition at once for the snapshot to be consistent across partitions, the entries of the other backends are listed
+	// one partition at a time
+	now := time.Now()
+	memoryBackends := make([]*memoryCacheBackend[T], len(partitions))
+	for i, eachPartition := range partitions {
+		if memoryBackend, ok := eachPartition.backend.(*memoryCacheBackend[T]); ok {
+			memoryBackends[i] = memoryBackend
+			memoryBackend.cacheLock.RLock()
+		}
+	}
+	cacheEntries := make([]map[string]*ServerCacheEntry[T], len(partitions))
+	for i, eachBackend := range memoryBackends {
+		if eachBackend != nil {
+			cacheEntries[i] = eachBackend.copyEntries()
+			eachBackend.cacheLock.RUnlock()
+		}
+	}
+	for i, eachPartition := range partitions {
+		if memoryBackends[i] == nil {
+			cacheEntries[i] = eachPartition.entries()
+		}
+	}
+
+	for i := range partitions {
+		keys, entries := freshEntries(cacheEntries[i], now)
+		for j, eachKey := range keys {
+			if !f(eachKey, entries[j]) {
+				return
+			}
+		}
+	}
+}
+
+// Clear remove every entry of every partition, return the number of fresh entries removed
+func (sc *ServerCache[T]) Clear() int {
+	cleared := 0
+	for _, eachPartition := range sc.hashRing {
+		cleared += eachPartition.Clear()
+	}
+	return cleared
+}
+
+// ServerCachePartitionKeys keys of the fresh entries of a cache partition
+type ServerCachePartitionKeys struct {
+	ID   string
+	Keys []string
+}
+
+// Keys return the keys of the fresh entries starting with the prefix, by partition ordered by partition
+func (sc *ServerCache[T]) Keys(prefix string) []ServerCachePartitionKeys {
+	keys := make([]ServerCachePartitionKeys, 0, len(sc.hashRing))
+	for i := 0; i < len(sc.hashRing); i++ {
+		partitionID := strconv.Itoa(i)
+		keys = append(keys, ServerCachePartitionKeys{
+			ID:   partitionID,
+			Keys: sc.hashRing[partitionID].Keys(prefix),
+		})
+	}
+	return keys
+}
+
+// PartitionID return the id of the partition holding the key (empty if there is none)
+func (sc *ServerCache[T]) PartitionID(key string) string {
+	partitionID := sc.consistentHasher.LocateKey([]byte(key))
+	if partitionID != nil {
+		return partitionID.String()
+	}
+	return ""
+}
+
+// Stats return the lookups and size of each partition, ordered by partition
+func (sc *ServerCache[T]) Stats() []ServerCachePartitionStats {
+	stats := make([]ServerCachePartitionStats, 0, len(sc.hashRing))
+	for i := 0; i < len(sc.hashRing); i++ {
+		stats = append(stats, sc.hashRing[strconv.Itoa(i)].Stats())
+	}
+	return stats
+}
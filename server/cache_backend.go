@@ -0,0 +1,121 @@
+package server
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// CACHE_BACKEND_MEMORY the cache entries are kept in the memory of the server
+	CACHE_BACKEND_MEMORY = "memory"
+	// CACHE_BACKEND_REDIS the cache entries are kept in a Redis (or Redis protocol compatible) server shared by the replicas
+	CACHE_BACKEND_REDIS = "redis"
+)
+
+// CacheBackend storage of the entries of a cache partition, the partition check the expiry of the entries, count the lookups and load the missing values,
+// the backend may keep the expired entries until they are overwritten or removed
+type CacheBackend[T ICacheable] interface {
+	// Load return the entry stored for the key, nil when there is none
+	Load(key string) (*ServerCacheEntry[T], error)
+	// Store store the entry for the key, replacing the previous one
+	Store(key string, entry *ServerCacheEntry[T]) error
+	// Remove remove the entry of the key, return the entry removed, nil when there was none
+	Remove(key string) (*ServerCacheEntry[T], error)
+	// Entries return the stored entries by key
+	Entries() (map[string]*ServerCacheEntry[T], error)
+	// Clear remove every entry, return the entries removed by key
+	Clear() (map[string]*ServerCacheEntry[T], error)
+}
+
+// CacheBackendCounter cache backend able to count its entries without listing them, the size of the partitions is only reported
+// for such backends since it is read on every metrics scrape
+type CacheBackendCounter interface {
+	// Len return the number of stored entries
+	Len() (int, error)
+}
+
+// memoryCacheBackend cache backend keeping the entries in a map of the server memory
+type memoryCacheBackend[T ICacheable] struct {
+	cache     map[string]*ServerCacheEntry[T]
+	cacheLock *sync.RWMutex
+}
+
+// newMemoryCacheBackend return new in-memory cache backend
+func newMemoryCacheBackend[T ICacheable]() *memoryCacheBackend[T] {
+	return &memoryCacheBackend[T]{
+		cache:     make(map[string]*ServerCacheEntry[T]),
+		cacheLock: &sync.RWMutex{},
+	}
+}
+
+// Load return the entry stored for the key, nil when there is none
+func (mcb *memoryCacheBackend[T]) Load(key string) (*ServerCacheEntry[T], error) {
+	mcb.cacheLock.RLock()
+	defer mcb.cacheLock.RUnlock()
+	return mcb.cache[key], nil
+}
+
+// Store store the entry for the key, replacing the previous one
+func (mcb *memoryCacheBackend[T]) Store(key string, entry *ServerCacheEntry[T]) error {
+	mcb.cacheLock.Lock()
+	defer mcb.cacheLock.Unlock()
+	mcb.cache[key] = entry
+	return nil
+}
+
+// Remove remove the entry of the key, return the entry removed, nil when there was none
+func (mcb *memoryCacheBackend[T]) Remove(key string) (*ServerCacheEntry[T], error) {
+	mcb.cacheLock.Lock()
+	defer mcb.cacheLock.Unlock()
+	entry := mcb.cache[key]
+	delete(mcb.cache, key)
+	return entry, nil
+}
+
+// Entries return the stored entries by key
+func (mcb *memoryCacheBackend[T]) Entries() (map[string]*ServerCacheEntry[T], error) {
+	mcb.cacheLock.RLock()
+	defer mcb.cacheLock.RUnlock()
+	return mcb.copyEntries(), nil
+}
+
+// copyEntries return a copy of the map of the entries, to be called with the lock held
+func (mcb *memoryCacheBackend[T]) copyEntries() map[string]*ServerCacheEntry[T] {
+	entries := make(map[string]*ServerCacheEntry[T], len(mcb.cache))
+	for eachKey, eachEntry := range mcb.cache {
+		entries[eachKey] = eachEntry
+	}
+	return entries
+}
+
+// Clear remove every entry, return the entries removed by key
+func (mcb *memoryCacheBackend[T]) Clear() (map[string]*ServerCacheEntry[T], error) {
+	mcb.cacheLock.Lock()
+	defer mcb.cacheLock.Unlock()
+	entries := mcb.cache
+	mcb.cache = make(map[string]*ServerCacheEntry[T])
+	return entries, nil
+}
+
+// Len return the number of stored entries
+func (mcb *memoryCacheBackend[T]) Len() (int, error) {
+	mcb.cacheLock.RLock()
+	defer mcb.cacheLock.RUnlock()
+	return len(mcb.cache), nil
+}
+
+// configureCache set the error handler of the cache, logging the errors of its backend, and keep its entries in Redis with the redis cache backend
+func configureCache[T ICacheable](s *Server, name string, cache *ServerCache[T]) {
+	cache.WithErrorHandler(func(err error) {
+		if errors.Is(err, errRedisCircuitOpen) {
+			// The failures opening the circuit have been logged, the lookups skipping Redis are only counted
+			return
+		}
+		s.logger.warn("encounter err in the cache backend", logFields{"cache": name, "backend": s.config.cacheBackend, "err": err})
+	})
+	if s.redisClient != nil {
+		cache.WithBackend(func(partitionID string) CacheBackend[T] {
+			return newRedisCacheBackend[T](s.redisClient, name, partitionID)
+		})
+	}
+}
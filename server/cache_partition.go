@@ -1,38 +1,272 @@
 package server
 
-import "sync"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 type ICacheable interface {
 	String() string
 }
 
+// ServerCacheEntry a cached value with the time it was stored and the time it expires (zero when it never expires)
+type ServerCacheEntry[T ICacheable] struct {
+	Value     *T
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// expired check whether the entry is expired at the time
+func (e *ServerCacheEntry[T]) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// serverCacheLoad a value being loaded by GetOrLoad, the concurrent lookups of the same key wait for it instead of loading it again
+type serverCacheLoad[T ICacheable] struct {
+	done  chan struct{}
+	value *T
+	err   error
+}
+
 // ServerCachePartition a partition inside the cache
 type ServerCachePartition[T ICacheable] struct {
-	id        string
-	cache     map[string]*T
-	cacheLock *sync.RWMutex
+	id            string
+	backend       CacheBackend[T]                // storage of the entries
+	loads         map[string]*serverCacheLoad[T] // values being loaded by GetOrLoad, by key
+	loadsLock     *sync.Mutex
+	ttl           time.Duration // how long a value is kept, values never expire when 0
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	backendErrors atomic.Uint64
+	errorHandler  func(err error) // called with the errors of the backend, which are otherwise handled as missing entries
+}
+
+// ServerCachePartitionStats lookups and size of a cache partition
+type ServerCachePartitionStats struct {
+	ID     string
+	Hits   uint64 // lookups finding a fresh value
+	Misses uint64 // lookups finding no value, or an expired one
+	Errors uint64 // backend operations failing
+	Size   int    // stored entries, including the expired ones not overwritten yet
+	Sized  bool   // the backend counted its entries, the redis backend does not (counting would scan the keyspace)
 }
 
-// NewServerCachePartition return new cache partition
+// NewServerCachePartition return new cache partition keeping its entries in memory
 func NewServerCachePartition[T ICacheable](id string) *ServerCachePartition[T] {
+	return NewServerCachePartitionWithBackend[T](id, newMemoryCacheBackend[T]())
+}
+
+// NewServerCachePartitionWithBackend return new cache partition keeping its entries in the backend
+func NewServerCachePartitionWithBackend[T ICacheable](id string, backend CacheBackend[T]) *ServerCachePartition[T] {
 	return &ServerCachePartition[T]{
 		id:        id,
-		cache:     make(map[string]*T),
-		cacheLock: &sync.RWMutex{},
+		backend:   backend,
+		loads:     make(map[string]*serverCacheLoad[T]),
+		loadsLock: &sync.Mutex{},
 	}
 }
 
-// Get get cache value by key
+// backendFailed count the error of the backend and pass it to the error handler
+func (scp *ServerCachePartition[T]) backendFailed(operation string, err error) {
+	scp.backendErrors.Add(1)
+	if scp.errorHandler != nil {
+		scp.errorHandler(fmt.Errorf("encounter err when %s in cache partition %q: %w", operation, scp.id, err))
+	}
+}
+
+// Get get cache value by key, nil when the value is expired, the lookup is counted in the hits or misses
 func (scp *ServerCachePartition[T]) Get(key string) *T {
-	scp.cacheLock.RLock()
-	defer scp.cacheLock.RUnlock()
-	value := scp.cache[key]
-	return value
+	entry := scp.GetEntry(key)
+	if entry == nil {
+		scp.misses.Add(1)
+		return nil
+	}
+	scp.hits.Add(1)
+	return entry.Value
+}
+
+// GetEntry get cache entry by key, nil when the entry is expired or the backend fails, the lookup only inspects the cache and is not counted in the hits or misses
+func (scp *ServerCachePartition[T]) GetEntry(key string) *ServerCacheEntry[T] {
+	entry, err := scp.backend.Load(key)
+	if err != nil {
+		scp.backendFailed(fmt.Sprintf("loading key %q", key), err)
+		return nil
+	}
+	if entry == nil || entry.expired(time.Now()) {
+		return nil
+	}
+	return entry
 }
 
-// Set set cache value by key
+// Set set cache value by key, the value expires after the ttl of the partition
 func (scp *ServerCachePartition[T]) Set(key string, value *T) {
-	scp.cacheLock.Lock()
-	defer scp.cacheLock.Unlock()
-	scp.cache[key] = value
+	scp.store(key, scp.newEntry(value))
+}
+
+// store store the entry in the backend, a failure is reported and the value is not cached
+func (scp *ServerCachePartition[T]) store(key string, entry *ServerCacheEntry[T]) {
+	if err := scp.backend.Store(key, entry); err != nil {
+		scp.backendFailed(fmt.Sprintf("storing key %q", key), err)
+	}
+}
+
+// newEntry return the entry of the value stored now, expiring after the ttl of the partition
+func (scp *ServerCachePartition[T]) newEntry(value *T) *ServerCacheEntry[T] {
+	now := time.Now()
+	entry := &ServerCacheEntry[T]{
+		Value:    value,
+		StoredAt: now,
+	}
+	if scp.ttl > 0 {
+		entry.ExpiresAt = now.Add(scp.ttl)
+	}
+	return entry
+}
+
+// GetOrLoad get cache value by key, when there is no fresh value it is loaded and stored (unless the loader fails or return nil),
+// the concurrent lookups of a key being loaded wait for the value instead of calling the loader again, the lookup is counted in the hits or misses
+func (scp *ServerCachePartition[T]) GetOrLoad(key string, loader func(key string) (*T, error)) (*T, error) {
+	if value := scp.Get(key); value != nil {
+		return value, nil
+	}
+
+	scp.loadsLock.Lock()
+	if load := scp.loads[key]; load != nil {
+		scp.loadsLock.Unlock()
+		<-load.done
+		return load.value, load.err
+	}
+	// A load completing before the lock was taken stored its value before releasing the key
+	if entry := scp.GetEntry(key); entry != nil {
+		scp.loadsLock.Unlock()
+		return entry.Value, nil
+	}
+	load := &serverCacheLoad[T]{done: make(chan struct{})}
+	scp.loads[key] = load
+	scp.loadsLock.Unlock()
+
+	defer func() {
+		if load.err == nil && load.value != nil {
+			scp.store(key, scp.newEntry(load.value))
+		}
+		scp.loadsLock.Lock()
+		delete(scp.loads, key)
+		scp.loadsLock.Unlock()
+		close(load.done)
+	}()
+	load.value, load.err = loader(key)
+	return load.value, load.err
+}
+
+// entries return the stored entries by key, none when the backend fails
+func (scp *ServerCachePartition[T]) entries() map[string]*ServerCacheEntry[T] {
+	entries, err := scp.backend.Entries()
+	if err != nil {
+		scp.backendFailed("listing the entries", err)
+		return nil
+	}
+	return entries
+}
+
+// Len return the number of fresh entries
+func (scp *ServerCachePartition[T]) Len() int {
+	keys, _ := freshEntries(scp.entries(), time.Now())
+	return len(keys)
+}
+
+// Range call f for each fresh entry sorted by key, until f return false, the entries are a snapshot taken when Range is called
+// so f may modify the partition
+func (scp *ServerCachePartition[T]) Range(f func(key string, value *T) bool) {
+	keys, entries := freshEntries(scp.entries(), time.Now())
+	for i, eachKey := range keys {
+		if !f(eachKey, entries[i].Value) {
+			return
+		}
+	}
+}
+
+// freshEntries return the keys of the fresh entries sorted and their entries
+func freshEntries[T ICacheable](cache map[string]*ServerCacheEntry[T], now time.Time) ([]string, []*ServerCacheEntry[T]) {
+	keys := make([]string, 0, len(cache))
+	for eachKey, eachEntry := range cache {
+		if !eachEntry.expired(now) {
+			keys = append(keys, eachKey)
+		}
+	}
+	sort.Strings(keys)
+	entries := make([]*ServerCacheEntry[T], len(keys))
+	for i, eachKey := range keys {
+		entries[i] = cache[eachKey]
+	}
+	return keys, entries
+}
+
+// Keys return the keys of the fresh entries starting with the prefix, sorted
+func (scp *ServerCachePartition[T]) Keys(prefix string) []string {
+	keys := make([]string, 0)
+	scp.Range(func(key string, value *T) bool {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// Delete remove the entry of the key, return whether a fresh entry was removed
+func (scp *ServerCachePartition[T]) Delete(key string) bool {
+	entry, err := scp.backend.Remove(key)
+	if err != nil {
+		scp.backendFailed(fmt.Sprintf("removing key %q", key), err)
+		return false
+	}
+	return entry != nil && !entry.expired(time.Now())
+}
+
+// DeletePrefix remove the entries whose key start with the prefix (every entry when empty), return the number of fresh entries removed
+func (scp *ServerCachePartition[T]) DeletePrefix(prefix string) int {
+	if prefix == "" {
+		return scp.Clear()
+	}
+	deleted := 0
+	for eachKey := range scp.entries() {
+		if strings.HasPrefix(eachKey, prefix) && scp.Delete(eachKey) {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Clear remove every entry, return the number of fresh entries removed
+func (scp *ServerCachePartition[T]) Clear() int {
+	entries, err := scp.backend.Clear()
+	if err != nil {
+		scp.backendFailed("clearing the entries", err)
+		return 0
+	}
+	keys, _ := freshEntries(entries, time.Now())
+	return len(keys)
+}
+
+// Stats return the lookups and size of the partition
+func (scp *ServerCachePartition[T]) Stats() ServerCachePartitionStats {
+	stats := ServerCachePartitionStats{
+		ID:     scp.id,
+		Hits:   scp.hits.Load(),
+		Misses: scp.misses.Load(),
+		Errors: scp.backendErrors.Load(),
+	}
+	if counter, ok := scp.backend.(CacheBackendCounter); ok {
+		size, err := counter.Len()
+		if err != nil {
+			scp.backendFailed("counting the entries", err)
+		} else {
+			stats.Size, stats.Sized = size, true
+		}
+	}
+	return stats
 }
@@ -1,6 +1,14 @@
 package server
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 type TestCacheableStruct struct {
 	data string
@@ -52,3 +60,293 @@ func TestCachePartitionSetAndRetrieveValue(t *testing.T) {
 		})
 	}
 }
+
+func TestCachePartitionTTL(t *testing.T) {
+	tests := map[string]struct {
+		TTL             time.Duration
+		Age             time.Duration
+		ExpectedExpired bool
+	}{
+		"Test no ttl": {
+			TTL:             0,
+			Age:             time.Hour,
+			ExpectedExpired: false,
+		},
+		"Test fresh value": {
+			TTL:             time.Minute,
+			Age:             30 * time.Second,
+			ExpectedExpired: false,
+		},
+		"Test expired value": {
+			TTL:             time.Minute,
+			Age:             2 * time.Minute,
+			ExpectedExpired: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cachePartition := NewServerCachePartition[TestCacheableStruct]("Partition1")
+			cachePartition.ttl = test.TTL
+			cachePartition.Set("Key1", &TestCacheableStruct{data: "Data1"})
+
+			entry := cachePartition.GetEntry("Key1")
+			if entry == nil || entry.Value.data != "Data1" {
+				t.Fatalf("expected fresh entry, got %v", entry)
+			}
+			if test.TTL == 0 && !entry.ExpiresAt.IsZero() {
+				t.Errorf("expected no expiry, got %v", entry.ExpiresAt)
+			}
+			if test.TTL > 0 && !entry.ExpiresAt.Equal(entry.StoredAt.Add(test.TTL)) {
+				t.Errorf("expected expiry %v after %v, got %v", test.TTL, entry.StoredAt, entry.ExpiresAt)
+			}
+
+			// Age the entry
+			entry.StoredAt = entry.StoredAt.Add(-test.Age)
+			if !entry.ExpiresAt.IsZero() {
+				entry.ExpiresAt = entry.ExpiresAt.Add(-test.Age)
+			}
+			if expired := cachePartition.Get("Key1") == nil; expired != test.ExpectedExpired {
+				t.Errorf("expected expired %v, got %v", test.ExpectedExpired, expired)
+			}
+		})
+	}
+}
+
+func TestCachePartitionDeleteAndClear(t *testing.T) {
+	tests := map[string]struct {
+		Operation       func(cachePartition *ServerCachePartition[TestCacheableStruct]) int
+		ExpectedRemoved int
+		ExpectedKeys    []string
+	}{
+		"Test delete key": {
+			Operation: func(cachePartition *ServerCachePartition[TestCacheableStruct]) int {
+				if cachePartition.Delete("Key1") {
+					return 1
+				}
+				return 0
+			},
+			ExpectedRemoved: 1,
+			ExpectedKeys:    []string{"Key2", "Other1"},
+		},
+		"Test delete missing key": {
+			Operation: func(cachePartition *ServerCachePartition[TestCacheableStruct]) int {
+				if cachePartition.Delete("Key3") {
+					return 1
+				}
+				return 0
+			},
+			ExpectedKeys: []string{"Key1", "Key2", "Other1"},
+		},
+		"Test delete prefix": {
+			Operation: func(cachePartition *ServerCachePartition[TestCacheableStruct]) int {
+				return cachePartition.DeletePrefix("Key")
+			},
+			ExpectedRemoved: 2,
+			ExpectedKeys:    []string{"Other1"},
+		},
+		"Test clear": {
+			Operation: func(cachePartition *ServerCachePartition[TestCacheableStruct]) int {
+				return cachePartition.Clear()
+			},
+			ExpectedRemoved: 3,
+			ExpectedKeys:    []string{},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cachePartition := NewServerCachePartition[TestCacheableStruct]("Partition1")
+			for _, eachKey := range []string{"Key2", "Other1", "Key1"} {
+				cachePartition.Set(eachKey, &TestCacheableStruct{data: eachKey})
+			}
+
+			if removed := test.Operation(cachePartition); removed != test.ExpectedRemoved {
+				t.Errorf("expected %v entries removed, got %v", test.ExpectedRemoved, removed)
+			}
+			if keys := cachePartition.Keys(""); strings.Join(keys, ",") != strings.Join(test.ExpectedKeys, ",") {
+				t.Errorf("expected keys %v, got %v", test.ExpectedKeys, keys)
+			}
+			if length := cachePartition.Len(); length != len(test.ExpectedKeys) {
+				t.Errorf("expected length %v, got %v", len(test.ExpectedKeys), length)
+			}
+		})
+	}
+}
+
+func TestCachePartitionRange(t *testing.T) {
+	cachePartition := NewServerCachePartition[TestCacheableStruct]("Partition1")
+	cachePartition.ttl = time.Minute
+	for _, eachKey := range []string{"Key3", "Key1", "Key2"} {
+		cachePartition.Set(eachKey, &TestCacheableStruct{data: eachKey})
+	}
+	cachePartition.backend.Store("Expired", &ServerCacheEntry[TestCacheableStruct]{
+		Value:     &TestCacheableStruct{data: "Expired"},
+		StoredAt:  time.Now().Add(-2 * time.Minute),
+		ExpiresAt: time.Now().Add(-time.Second),
+	})
+
+	// Expired entries are skipped and the entries modified by f are not seen
+	visited := make([]string, 0)
+	cachePartition.Range(func(key string, value *TestCacheableStruct) bool {
+		visited = append(visited, key+"="+value.data)
+		cachePartition.Delete("Key3")
+		cachePartition.Set("Key4", &TestCacheableStruct{data: "Key4"})
+		return true
+	})
+	if strings.Join(visited, ",") != "Key1=Key1,Key2=Key2,Key3=Key3" {
+		t.Errorf("expected the snapshot of the fresh entries sorted by key, got %v", visited)
+	}
+	if cachePartition.Len() != 3 {
+		t.Errorf("expected 3 fresh entries after the range, got %v", cachePartition.Len())
+	}
+
+	// Stop when f return false
+	visited = visited[:0]
+	cachePartition.Range(func(key string, value *TestCacheableStruct) bool {
+		visited = append(visited, key)
+		return len(visited) < 2
+	})
+	if strings.Join(visited, ",") != "Key1,Key2" {
+		t.Errorf("expected the range to stop after 2 entries, got %v", visited)
+	}
+}
+
+func TestCachePartitionGetOrLoad(t *testing.T) {
+	tests := map[string]struct {
+		Cached         bool
+		LoadedValue    *TestCacheableStruct
+		LoadErr        error
+		ExpectedValue  string
+		ExpectedError  bool
+		ExpectedLoads  int32
+		ExpectedStored bool
+	}{
+		"Test cached value": {
+			Cached:         true,
+			ExpectedValue:  "Cached",
+			ExpectedStored: true,
+		},
+		"Test loaded value": {
+			LoadedValue:    &TestCacheableStruct{data: "Loaded"},
+			ExpectedValue:  "Loaded",
+			ExpectedLoads:  1,
+			ExpectedStored: true,
+		},
+		"Test loader error": {
+			LoadErr:       errors.New("failed"),
+			ExpectedError: true,
+			ExpectedLoads: 1,
+		},
+		"Test nil value": {
+			ExpectedLoads: 1,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cachePartition := NewServerCachePartition[TestCacheableStruct]("Partition1")
+			if test.Cached {
+				cachePartition.Set("Key1", &TestCacheableStruct{data: "Cached"})
+			}
+
+			loads := int32(0)
+			value, err := cachePartition.GetOrLoad("Key1", func(key string) (*TestCacheableStruct, error) {
+				atomic.AddInt32(&loads, 1)
+				return test.LoadedValue, test.LoadErr
+			})
+			if (err != nil) != test.ExpectedError {
+				t.Errorf("expected error %v, got %v", test.ExpectedError, err)
+			}
+			if (value == nil && test.ExpectedValue != "") || (value != nil && value.data != test.ExpectedValue) {
+				t.Errorf("expected value %q, got %v", test.ExpectedValue, value)
+			}
+			if loads != test.ExpectedLoads {
+				t.Errorf("expected %v loads, got %v", test.ExpectedLoads, loads)
+			}
+			if stored := cachePartition.GetEntry("Key1") != nil; stored != test.ExpectedStored {
+				t.Errorf("expected stored %v, got %v", test.ExpectedStored, stored)
+			}
+		})
+	}
+}
+
+func TestCachePartitionConcurrentGetOrLoad(t *testing.T) {
+	cachePartition := NewServerCachePartition[TestCacheableStruct]("Partition1")
+	loads := int32(0)
+	release := make(chan struct{})
+	loader := func(key string) (*TestCacheableStruct, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return &TestCacheableStruct{data: key}, nil
+	}
+
+	// Every lookup starts while the first load is blocked
+	waitGroup := &sync.WaitGroup{}
+	values := make([]*TestCacheableStruct, 50)
+	for i := range values {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			values[i], _ = cachePartition.GetOrLoad("Key1", loader)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	waitGroup.Wait()
+
+	if loads != 1 {
+		t.Errorf("expected the value to be loaded once, got %v loads", loads)
+	}
+	for _, eachValue := range values {
+		if eachValue == nil || eachValue.data != "Key1" {
+			t.Fatalf("expected every lookup to get the loaded value, got %v", eachValue)
+		}
+	}
+}
+
+func TestCachePartitionConcurrentOperations(t *testing.T) {
+	cachePartition := NewServerCachePartition[TestCacheableStruct]("Partition1")
+	cachePartition.ttl = time.Minute
+
+	waitGroup := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("Key%d", (i+j)%10)
+				switch j % 7 {
+				case 0:
+					cachePartition.Set(key, &TestCacheableStruct{data: key})
+				case 1:
+					cachePartition.Get(key)
+				case 2:
+					cachePartition.Delete(key)
+				case 3:
+					cachePartition.Range(func(key string, value *TestCacheableStruct) bool {
+						if value.data != key {
+							t.Errorf("expected value of %v, got %v", key, value.data)
+						}
+						return true
+					})
+				case 4:
+					cachePartition.Len()
+				case 5:
+					cachePartition.GetOrLoad(key, func(key string) (*TestCacheableStruct, error) {
+						return &TestCacheableStruct{data: key}, nil
+					})
+				case 6:
+					if j%70 == 6 {
+						cachePartition.Clear()
+					}
+				}
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	if length, keys := cachePartition.Len(), cachePartition.Keys(""); length != len(keys) || length > 10 {
+		t.Errorf("expected at most 10 entries, got length %v and keys %v", length, keys)
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// CACHE_SNAPSHOT_VERSION version of the cache snapshot format, snapshots of another version are discarded
+	CACHE_SNAPSHOT_VERSION = 1
+)
+
+// ServerCacheSnapshotEntry a cache entry as written in a snapshot, the value is encoded in JSON
+type ServerCacheSnapshotEntry struct {
+	Key       string          `json:"key"`
+	StoredAt  time.Time       `json:"stored_at"`
+	ExpiresAt time.Time       `json:"expires_at"` // zero when the entry never expires
+	Value     json.RawMessage `json:"value"`
+}
+
+// Snapshot return the fresh entries of every partition, taken at once, ordered by partition then by key
+func (sc *ServerCache[T]) Snapshot() ([]ServerCacheSnapshotEntry, error) {
+	entries := make([]ServerCacheSnapshotEntry, 0)
+	var err error
+	sc.rangeEntries(func(key string, entry *ServerCacheEntry[T]) bool {
+		var value []byte
+		value, err = json.Marshal(entry.Value)
+		if err != nil {
+			err = fmt.Errorf("encounter err when encoding the value of key %q: %w", key, err)
+			return false
+		}
+		entries = append(entries, ServerCacheSnapshotEntry{
+			Key:       key,
+			StoredAt:  entry.StoredAt,
+			ExpiresAt: entry.ExpiresAt,
+			Value:     value,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Restore store the entries of a snapshot with their original time, an entry expires at the earliest of its snapshot expiry and its stored time plus the ttl
+// of the partition, return the number of entries restored and the number discarded because they are expired, their value can not be decoded or the backend fails
+func (sc *ServerCache[T]) Restore(entries []ServerCacheSnapshotEntry) (int, int) {
+	restored, discarded := 0, 0
+	now := time.Now()
+	for _, eachEntry := range entries {
+		partitionID := sc.consistentHasher.LocateKey([]byte(eachEntry.Key))
+		value := new(T)
+		if partitionID == nil || json.Unmarshal(eachEntry.Value, value) != nil {
+			discarded++
+			continue
+		}
+		if sc.hashRing[partitionID.String()].restore(eachEntry.Key, value, eachEntry.StoredAt, eachEntry.ExpiresAt, now) {
+			restored++
+		} else {
+			discarded++
+		}
+	}
+	return restored, discarded
+}
+
+// restore store the value with its original time unless it is expired, return whether it was stored (not when the backend fails),
+// a time in the future is not trusted, the value is stored now so it expires within the ttl
+func (scp *ServerCachePartition[T]) restore(key string, value *T, storedAt time.Time, expiresAt time.Time, now time.Time) bool {
+	if storedAt.After(now) {
+		storedAt = now
+	}
+	entry := &ServerCacheEntry[T]{
+		Value:     value,
+		StoredAt:  storedAt,
+		ExpiresAt: expiresAt,
+	}
+	if scp.ttl > 0 {
+		// The ttl may have been shortened since the snapshot
+		if maxExpiresAt := storedAt.Add(scp.ttl); entry.ExpiresAt.IsZero() || maxExpiresAt.Before(entry.ExpiresAt) {
+			entry.ExpiresAt = maxExpiresAt
+		}
+	}
+	if entry.expired(now) {
+		return false
+	}
+
+	if err := scp.backend.Store(key, entry); err != nil {
+		scp.backendFailed(fmt.Sprintf("restoring key %q", key), err)
+		return false
+	}
+	return true
+}
+
+// cacheSnapshot content of the snapshot file, the entries of each cache by cache name
+type cacheSnapshot struct {
+	Version   int                                   `json:"version"`
+	CreatedAt time.Time                             `json:"created_at"`
+	Caches    map[string][]ServerCacheSnapshotEntry `json:"caches"`
+}
+
+// snapshotCache a cache that can be written to and restored from a snapshot, whatever its value type
+type snapshotCache interface {
+	Snapshot() ([]ServerCacheSnapshotEntry, error)
+	Restore(entries []ServerCacheSnapshotEntry) (int, int)
+}
+
+// snapshotCaches return the caches of the server written in the snapshot, by cache name
+func (s *Server) snapshotCaches() map[string]snapshotCache {
+	return map[string]snapshotCache{
+		CACHE_USER_INFO:   s.githubUserInfoCache,
+		CACHE_ORG_INFO:    s.githubOrgInfoCache,
+		CACHE_ORG_MEMBERS: s.githubOrgMembersCache,
+		CACHE_FOLLOW_LIST: s.githubFollowListCache,
+	}
+}
+
+// writeCacheSnapshot write the fresh entries of the caches to the snapshot file, the file is replaced at once so a crash while writing keep the previous snapshot,
+// return the number of entries written, to be called with the snapshot lock held
+func (s *Server) writeCacheSnapshot() (int, error) {
+	snapshot := &cacheSnapshot{
+		Version:   CACHE_SNAPSHOT_VERSION,
+		CreatedAt: time.Now().UTC(),
+		Caches:    make(map[string][]ServerCacheSnapshotEntry),
+	}
+	entries := 0
+	for eachName, eachCache := range s.snapshotCaches() {
+		cacheEntries, err := eachCache.Snapshot()
+		if err != nil {
+			return 0, fmt.Errorf("encounter err when taking the snapshot of cache %q: %w", eachName, err)
+		}
+		snapshot.Caches[eachName] = cacheEntries
+		entries += len(cacheEntries)
+	}
+
+	path := s.config.cacheSnapshotPath
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	err = json.NewEncoder(file).Encode(snapshot)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entries, nil
+}
+
+// restoreCacheSnapshot restore the caches from the snapshot file, a missing, corrupt or other version snapshot is logged and the caches start empty
+func (s *Server) restoreCacheSnapshot() {
+	path := s.config.cacheSnapshotPath
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.logger.info("no cache snapshot to restore", logFields{"path": path})
+		return
+	}
+	if err != nil {
+		s.logger.warn("encounter err when reading the cache snapshot, caches start empty", logFields{"path": path, "err": err})
+		return
+	}
+
+	snapshot := &cacheSnapshot{}
+	err = json.Unmarshal(content, snapshot)
+	if err != nil {
+		s.logger.warn("cache snapshot is corrupt, caches start empty", logFields{"path": path, "err": err})
+		return
+	}
+	if snapshot.Version != CACHE_SNAPSHOT_VERSION {
+		s.logger.warn("cache snapshot version is not supported, caches start empty", logFields{"path": path, "version": snapshot.Version, "supported_version": CACHE_SNAPSHOT_VERSION})
+		return
+	}
+
+	restored, discarded := 0, 0
+	caches := s.snapshotCaches()
+	for eachName, eachEntries := range snapshot.Caches {
+		cache, found := caches[eachName]
+		if !found {
+			discarded += len(eachEntries)
+			continue
+		}
+		cacheRestored, cacheDiscarded := cache.Restore(eachEntries)
+		restored += cacheRestored
+		discarded += cacheDiscarded
+	}
+	s.logger.info("cache snapshot restored", logFields{"path": path, "created_at": snapshot.CreatedAt, "restored": restored, "discarded": discarded})
+}
+
+// snapshotCachesPeriodically write the cache snapshot every cache snapshot interval until the snapshots are stopped
+func (s *Server) snapshotCachesPeriodically() {
+	ticker := time.NewTicker(s.config.cacheSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.snapshotLock.Lock()
+			if s.snapshotCtx.Err() == nil {
+				entries, err := s.writeCacheSnapshot()
+				if err != nil {
+					s.logger.error("encounter err when writing the cache snapshot", logFields{"path": s.config.cacheSnapshotPath, "err": err})
+				} else {
+					s.logger.debug("cache snapshot written", logFields{"path": s.config.cacheSnapshotPath, "entries": entries})
+				}
+			}
+			s.snapshotLock.Unlock()
+		case <-s.snapshotCtx.Done():
+			return
+		}
+	}
+}
+
+// flushCacheSnapshot stop the periodic snapshots and write the last snapshot
+func (s *Server) flushCacheSnapshot() error {
+	s.stopSnapshots()
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+	entries, err := s.writeCacheSnapshot()
+	if err != nil {
+		return err
+	}
+	s.logger.info("cache snapshot written", logFields{"path": s.config.cacheSnapshotPath, "entries": entries})
+	return nil
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheSnapshotAndRestore(t *testing.T) {
+	tests := map[string]struct {
+		Entry             ServerCacheSnapshotEntry
+		TTL               time.Duration
+		ExpectedRestored  int
+		ExpectedExpiresAt func(entry ServerCacheSnapshotEntry) time.Time
+	}{
+		"Test fresh entry": {
+			Entry: ServerCacheSnapshotEntry{
+				Key:       "a",
+				StoredAt:  time.Now().Add(-time.Minute),
+				ExpiresAt: time.Now().Add(time.Minute),
+				Value:     json.RawMessage(`{"login":"a"}`),
+			},
+			TTL:              10 * time.Minute,
+			ExpectedRestored: 1,
+			ExpectedExpiresAt: func(entry ServerCacheSnapshotEntry) time.Time {
+				return entry.ExpiresAt
+			},
+		},
+		"Test expired entry": {
+			Entry: ServerCacheSnapshotEntry{
+				Key:       "a",
+				StoredAt:  time.Now().Add(-2 * time.Minute),
+				ExpiresAt: time.Now().Add(-time.Minute),
+				Value:     json.RawMessage(`{"login":"a"}`),
+			},
+			TTL: 10 * time.Minute,
+		},
+		"Test entry expired by a shorter ttl": {
+			Entry: ServerCacheSnapshotEntry{
+				Key:       "a",
+				StoredAt:  time.Now().Add(-2 * time.Minute),
+				ExpiresAt: time.Now().Add(8 * time.Minute),
+				Value:     json.RawMessage(`{"login":"a"}`),
+			},
+			TTL: time.Minute,
+		},
+		"Test entry without expiry in a cache with ttl": {
+			Entry: ServerCacheSnapshotEntry{
+				Key:      "a",
+				StoredAt: time.Now().Add(-time.Minute),
+				Value:    json.RawMessage(`{"login":"a"}`),
+			},
+			TTL:              10 * time.Minute,
+			ExpectedRestored: 1,
+			ExpectedExpiresAt: func(entry ServerCacheSnapshotEntry) time.Time {
+				return entry.StoredAt.Add(10 * time.Minute)
+			},
+		},
+		"Test entry without expiry in a cache without ttl": {
+			Entry: ServerCacheSnapshotEntry{
+				Key:      "a",
+				StoredAt: time.Now().Add(-time.Hour),
+				Value:    json.RawMessage(`{"login":"a"}`),
+			},
+			ExpectedRestored: 1,
+			ExpectedExpiresAt: func(entry ServerCacheSnapshotEntry) time.Time {
+				return time.Time{}
+			},
+		},
+		"Test value not decodable": {
+			Entry: ServerCacheSnapshotEntry{
+				Key:      "a",
+				StoredAt: time.Now(),
+				Value:    json.RawMessage(`["a"]`),
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cache := NewServerCache[model.GithubUserInfo](7).WithTTL(test.TTL)
+			restored, discarded := cache.Restore([]ServerCacheSnapshotEntry{test.Entry})
+			if restored != test.ExpectedRestored || restored+discarded != 1 {
+				t.Fatalf("expected %v entry restored, got %v restored and %v discarded", test.ExpectedRestored, restored, discarded)
+			}
+			if test.ExpectedRestored == 0 {
+				if cache.Len() != 0 {
+					t.Errorf("expected no entry, got %v", cache.Len())
+				}
+				return
+			}
+
+			entry := cache.GetEntry(test.Entry.Key)
+			if entry == nil || entry.Value.Login != "a" || !entry.StoredAt.Equal(test.Entry.StoredAt) {
+				t.Fatalf("expected entry of a stored at %v, got %+v", test.Entry.StoredAt, entry)
+			}
+			if expectedExpiresAt := test.ExpectedExpiresAt(test.Entry); !entry.ExpiresAt.Equal(expectedExpiresAt) {
+				t.Errorf("expected expiry %v, got %v", expectedExpiresAt, entry.ExpiresAt)
+			}
+
+			// The snapshot of the restored cache carry the same entry
+			snapshot, err := cache.Snapshot()
+			if err != nil || len(snapshot) != 1 || snapshot[0].Key != "a" || !snapshot[0].StoredAt.Equal(entry.StoredAt) || !snapshot[0].ExpiresAt.Equal(entry.ExpiresAt) {
+				t.Errorf("expected snapshot of the entry, got %+v %v", snapshot, err)
+			}
+		})
+	}
+}
+
+func TestCacheSnapshotAcrossRestarts(t *testing.T) {
+	calls := int32(0)
+	githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		login := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		jsonString, _ := json.Marshal(model.GithubUserInfo{Login: login, Name: login})
+		w.Write(jsonString)
+	}))
+	defer githubAPITestServer.Close()
+	snapshotPath := filepath.Join(t.TempDir(), "cache.json")
+	newServer := func() *Server {
+		s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users").WithCacheSnapshotPath(snapshotPath))
+		s.logger = newLogger(&bytes.Buffer{}, LOG_LEVEL_INFO)
+		s.registerHandlers()
+		return s
+	}
+
+	s := newServer()
+	s.serverMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/retrieveUsers?usernames=a,b", nil))
+	s.githubFollowListCache.Set("followers:a", &model.GithubFollowList{Logins: []string{"b"}})
+	err := s.flushCacheSnapshot()
+	if err != nil {
+		t.Fatalf("expected no error when writing the snapshot, got %v", err)
+	}
+	if matches, _ := filepath.Glob(snapshotPath + ".*.tmp"); len(matches) > 0 {
+		t.Errorf("expected no temporary file left, got %v", matches)
+	}
+
+	restarted := newServer()
+	restarted.restoreCacheSnapshot()
+	if restarted.githubUserInfoCache.Len() != 2 || restarted.githubFollowListCache.Len() != 1 {
+		t.Errorf("expected 2 users and 1 follow list restored, got %v and %v", restarted.githubUserInfoCache.Len(), restarted.githubFollowListCache.Len())
+	}
+	if storedAt, restoredAt := s.githubUserInfoCache.GetEntry("a").StoredAt, restarted.githubUserInfoCache.GetEntry("a").StoredAt; !storedAt.Equal(restoredAt) {
+		t.Errorf("expected the original stored time %v, got %v", storedAt, restoredAt)
+	}
+
+	// Users restored are not fetched again
+	responseRecorder := httptest.NewRecorder()
+	restarted.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/v1/retrieveUsers?usernames=a,b", nil))
+	if responseRecorder.Code != http.StatusOK || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("expected the users to be served from the restored cache, got status %v after %v calls", responseRecorder.Code, calls)
+	}
+}
+
+func TestRestoreCacheSnapshotFailures(t *testing.T) {
+	tests := map[string]struct {
+		Content         string // no snapshot file when empty
+		ExpectedMessage string
+		ExpectedLevel   string
+	}{
+		"Test no snapshot": {
+			ExpectedMessage: "no cache snapshot to restore",
+			ExpectedLevel:   LOG_LEVEL_INFO,
+		},
+		"Test corrupt snapshot": {
+			Content:         `{"version":1,"caches":{"user_info":[{"key":"a"`,
+			ExpectedMessage: "cache snapshot is corrupt, caches start empty",
+			ExpectedLevel:   LOG_LEVEL_WARN,
+		},
+		"Test version mismatch": {
+			Content:         fmt.Sprintf(`{"version":%d,"caches":{"user_info":[{"key":"a","stored_at":"%s","value":{"login":"a"}}]}}`, CACHE_SNAPSHOT_VERSION+1, time.Now().Format(time.RFC3339)),
+			ExpectedMessage: "cache snapshot version is not supported, caches start empty",
+			ExpectedLevel:   LOG_LEVEL_WARN,
+		},
+		"Test unknown cache": {
+			Content:         fmt.Sprintf(`{"version":%d,"caches":{"unknown":[{"key":"a","stored_at":"%s","value":{"login":"a"}}]}}`, CACHE_SNAPSHOT_VERSION, time.Now().Format(time.RFC3339)),
+			ExpectedMessage: "cache snapshot restored",
+			ExpectedLevel:   LOG_LEVEL_INFO,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			snapshotPath := filepath.Join(t.TempDir(), "cache.json")
+			if test.Content != "" {
+				os.WriteFile(snapshotPath, []byte(test.Content), 0600)
+			}
+			logBuffer := &bytes.Buffer{}
+			s := NewServer(NewServerConfig("", 8777, "http://localhost", "users").WithCacheSnapshotPath(snapshotPath))
+			s.logger = newLogger(logBuffer, LOG_LEVEL_INFO)
+			s.restoreCacheSnapshot()
+
+			record := make(map[string]interface{})
+			json.Unmarshal(logBuffer.Bytes(), &record)
+			if record["msg"] != test.ExpectedMessage || record["level"] != test.ExpectedLevel {
+				t.Errorf("expected %v record %q, got %s", test.ExpectedLevel, test.ExpectedMessage, logBuffer.String())
+			}
+			if s.githubUserInfoCache.Len() != 0 {
+				t.Errorf("expected caches to start empty, got %v users", s.githubUserInfoCache.Len())
+			}
+		})
+	}
+}
+
+func TestCacheSnapshotPeriodically(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "cache.json")
+	s := NewServer(NewServerConfig("", 8777, "http://localhost", "users").WithCacheSnapshotPath(snapshotPath).WithCacheSnapshotInterval(20 * time.Millisecond))
+	s.logger = newLogger(&bytes.Buffer{}, LOG_LEVEL_INFO)
+	s.githubUserInfoCache.Set("a", &model.GithubUserInfo{Login: "a"})
+	go s.snapshotCachesPeriodically()
+	defer s.stopSnapshots()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		content, err := os.ReadFile(snapshotPath)
+		if err == nil {
+			snapshot := &cacheSnapshot{}
+			if err := json.Unmarshal(content, snapshot); err != nil || len(snapshot.Caches[CACHE_USER_INFO]) != 1 {
+				t.Errorf("expected a snapshot with 1 user, got %s", content)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected the snapshot to be written periodically")
+}
@@ -1,6 +1,13 @@
 package server
 
-import "testing"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
 
 func TestCacheSetAndRetrieveValue(t *testing.T) {
 	tests := map[string]struct {
@@ -136,3 +143,128 @@ func TestCacheSetAndRetrieveValue(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheLenRangeAndClear(t *testing.T) {
+	tests := map[string]struct {
+		NumberOfCachePartitions int
+		Keys                    []string
+		ExpectedLen             int
+	}{
+		"Test 1 partition": {
+			NumberOfCachePartitions: 1,
+			Keys:                    []string{"Key1", "Key2", "Key3"},
+			ExpectedLen:             3,
+		},
+		"Test 7 partitions": {
+			NumberOfCachePartitions: 7,
+			Keys:                    []string{"Key1", "Key2", "Key3", "Key4", "Key5", "Key6", "Key7", "Key8"},
+			ExpectedLen:             8,
+		},
+		"Test 0 partition": {
+			NumberOfCachePartitions: 0,
+			Keys:                    []string{"Key1"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cache := NewServerCache[TestCacheableStruct](test.NumberOfCachePartitions)
+			for _, eachKey := range test.Keys {
+				cache.Set(eachKey, &TestCacheableStruct{data: eachKey})
+			}
+			if cache.Len() != test.ExpectedLen {
+				t.Errorf("expected length %v, got %v", test.ExpectedLen, cache.Len())
+			}
+
+			// Every entry is visited once, ordered by partition
+			visited := make([]string, 0)
+			partitionIDs := make([]string, 0)
+			cache.Range(func(key string, value *TestCacheableStruct) bool {
+				if value.data != key {
+					t.Errorf("expected value of %v, got %v", key, value.data)
+				}
+				visited = append(visited, key)
+				partitionIDs = append(partitionIDs, cache.PartitionID(key))
+				return true
+			})
+			if !sort.StringsAreSorted(partitionIDs) {
+				t.Errorf("expected entries ordered by partition, got partitions %v", partitionIDs)
+			}
+			sort.Strings(visited)
+			if len(visited) != test.ExpectedLen || (test.ExpectedLen > 0 && strings.Join(visited, ",") != strings.Join(test.Keys, ",")) {
+				t.Errorf("expected keys %v, got %v", test.Keys, visited)
+			}
+
+			if cleared := cache.Clear(); cleared != test.ExpectedLen {
+				t.Errorf("expected %v entries cleared, got %v", test.ExpectedLen, cleared)
+			}
+			if cache.Len() != 0 {
+				t.Errorf("expected no entry after clear, got %v", cache.Len())
+			}
+		})
+	}
+}
+
+func TestCacheDelete(t *testing.T) {
+	cache := NewServerCache[TestCacheableStruct](7)
+	cache.Set("Key1", &TestCacheableStruct{data: "Data1"})
+	if !cache.Delete("Key1") {
+		t.Errorf("expected Key1 to be deleted")
+	}
+	if cache.Delete("Key1") {
+		t.Errorf("expected Key1 to be deleted once")
+	}
+	if cache.Get("Key1") != nil {
+		t.Errorf("expected no value after delete")
+	}
+}
+
+func TestCacheConcurrentOperations(t *testing.T) {
+	cache := NewServerCache[TestCacheableStruct](7)
+	loads := int32(0)
+
+	waitGroup := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("Key%d", (i+j)%20)
+				switch j % 6 {
+				case 0:
+					cache.Set(key, &TestCacheableStruct{data: key})
+				case 1:
+					cache.Delete(key)
+				case 2:
+					cache.Range(func(key string, value *TestCacheableStruct) bool {
+						if value.data != key {
+							t.Errorf("expected value of %v, got %v", key, value.data)
+						}
+						// Modifying the cache from f must not deadlock
+						cache.Set(key, value)
+						return true
+					})
+				case 3:
+					cache.Len()
+				case 4:
+					cache.GetOrLoad(key, func(key string) (*TestCacheableStruct, error) {
+						atomic.AddInt32(&loads, 1)
+						return &TestCacheableStruct{data: key}, nil
+					})
+				case 5:
+					if j%60 == 5 {
+						cache.Clear()
+					}
+				}
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	if cache.Len() > 20 {
+		t.Errorf("expected at most 20 entries, got %v", cache.Len())
+	}
+	if atomic.LoadInt32(&loads) == 0 {
+		t.Errorf("expected some values to be loaded")
+	}
+}
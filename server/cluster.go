@@ -0,0 +1,502 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/consistent"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	// CLUSTER_USERS_PATH internal route serving the users owned by the replica to the other replicas of the cluster
+	CLUSTER_USERS_PATH = "/internal/cluster/users"
+	// CLUSTER_HEARTBEAT_PATH internal route exchanging the members of the cluster between the replicas
+	CLUSTER_HEARTBEAT_PATH = "/internal/cluster/heartbeat"
+	// CLUSTER_PEER_TIMEOUT timeout of the calls to the other replicas
+	CLUSTER_PEER_TIMEOUT = 5 * time.Second
+	// CLUSTER_PEER_FAILURE_THRESHOLD consecutive failed heartbeats removing a replica from the ring, its users are then owned by the other replicas
+	CLUSTER_PEER_FAILURE_THRESHOLD = 3
+	// CLUSTER_RING_PARTITIONS number of partitions of the ring the usernames are placed on
+	CLUSTER_RING_PARTITIONS = 271
+)
+
+// clusterPeer another replica of the cluster
+type clusterPeer struct {
+	alive    bool // whether the peer is on the ring and owns usernames
+	failures int  // consecutive failed heartbeats
+}
+
+// cluster the replicas sharing the users fetched from github, each username is owned by the replica the consistent hash ring place it on,
+// the other replicas fetch it from its owner so the cluster fetch each user from github once
+type cluster struct {
+	self  string // URL the other replicas reach this replica on
+	lock  sync.Mutex
+	peers map[string]*clusterPeer // other replicas by URL, alive or not
+	ring  *consistent.Consistent  // this replica and the alive peers
+	ctx   context.Context         // done once the heartbeats are stopped
+	stop  context.CancelFunc      // stop the heartbeats
+}
+
+// clusterMembers members of the cluster known by a replica, exchanged by the heartbeats
+type clusterMembers struct {
+	Member  string   `json:"member"`  // URL of the replica sending the members
+	Members []string `json:"members"` // URL of the alive replicas, including the sender
+}
+
+// clusterUser a user served to another replica, with the time it was stored in the cache of the owner
+type clusterUser struct {
+	Username   string                `json:"username"`
+	User       *model.GithubUserInfo `json:"user,omitempty"`
+	StoredAt   time.Time             `json:"stored_at"`  // zero when the user is not cached
+	ExpiresAt  time.Time             `json:"expires_at"` // zero when the user never expires
+	Error      string                `json:"error,omitempty"`
+	StatusCode int                   `json:"status_code,omitempty"` // status code of the error
+}
+
+// clusterUsers users served to another replica
+type clusterUsers struct {
+	Users []*clusterUser `json:"users"`
+}
+
+// clusterPeerError error of a user fetched by the replica owning it, with the status code reported by the owner
+type clusterPeerError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error comply with error interface
+func (e *clusterPeerError) Error() string {
+	return e.Message
+}
+
+// clusterStatus members of the cluster reported by /status
+type clusterStatus struct {
+	Self    string   `json:"self"`
+	Members []string `json:"members"` // alive replicas owning usernames, including this one
+	Down    []string `json:"down"`    // replicas known but not answering the heartbeats
+}
+
+// normalizeClusterURL return the URL of a replica without the trailing slash
+func normalizeClusterURL(rawURL string) string {
+	return strings.TrimRight(strings.TrimSpace(rawURL), "/")
+}
+
+// newCluster return the cluster of this replica and the configured peers, which own their usernames until their heartbeats fail
+func newCluster(self string, peers []string) *cluster {
+	self = normalizeClusterURL(self)
+	c := &cluster{
+		self:  self,
+		peers: make(map[string]*clusterPeer),
+		ring: consistent.New([]consistent.Member{ConsistentHashMember(self)}, consistent.Config{
+			Hasher:            &ConsistentHasher{},
+			PartitionCount:    CLUSTER_RING_PARTITIONS,
+			Load:              1.25,
+			ReplicationFactor: 20, // Virtual nodes
+		}),
+	}
+	c.ctx, c.stop = context.WithCancel(context.Background())
+	for _, eachPeer := range peers {
+		peerURL := normalizeClusterURL(eachPeer)
+		if peerURL == "" || peerURL == self || c.peers[peerURL] != nil {
+			continue
+		}
+		c.peers[peerURL] = &clusterPeer{alive: true}
+		c.ring.Add(ConsistentHashMember(peerURL))
+	}
+	return c
+}
+
+// owner return the URL of the replica owning the username, usernames are placed case insensitively as github logins
+func (c *cluster) owner(username string) string {
+	return c.ring.LocateKey([]byte(strings.ToLower(username))).String()
+}
+
+// members return the URL of the alive replicas including this one, sorted
+func (c *cluster) members() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	members := []string{c.self}
+	for eachURL, eachPeer := range c.peers {
+		if eachPeer.alive {
+			members = append(members, eachURL)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// peerURLs return the URL of the peers known, alive or not, sorted
+func (c *cluster) peerURLs() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	peerURLs := make([]string, 0, len(c.peers))
+	for eachURL := range c.peers {
+		peerURLs = append(peerURLs, eachURL)
+	}
+	sort.Strings(peerURLs)
+	return peerURLs
+}
+
+// markAlive record a successful heartbeat of the peer, a peer unknown or down is added to the ring, return whether it was added
+func (c *cluster) markAlive(peerURL string) bool {
+	peerURL = normalizeClusterURL(peerURL)
+	if peerURL == "" || peerURL == c.self {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	peer := c.peers[peerURL]
+	if peer == nil {
+		peer = &clusterPeer{}
+		c.peers[peerURL] = peer
+	}
+	peer.failures = 0
+	if peer.alive {
+		return false
+	}
+	peer.alive = true
+	c.ring.Add(ConsistentHashMember(peerURL))
+	return true
+}
+
+// markFailed record a failed heartbeat of the peer, the peer is removed from the ring after CLUSTER_PEER_FAILURE_THRESHOLD failures in a row,
+// return whether it was removed
+func (c *cluster) markFailed(peerURL string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	peer := c.peers[peerURL]
+	if peer == nil {
+		return false
+	}
+	peer.failures++
+	if !peer.alive || peer.failures < CLUSTER_PEER_FAILURE_THRESHOLD {
+		return false
+	}
+	peer.alive = false
+	c.ring.Remove(peerURL)
+	return true
+}
+
+// learn add the replicas unknown yet, they join the ring once they answer a heartbeat
+func (c *cluster) learn(peerURLs []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, eachURL := range peerURLs {
+		peerURL := normalizeClusterURL(eachURL)
+		if peerURL == "" || peerURL == c.self || c.peers[peerURL] != nil {
+			continue
+		}
+		c.peers[peerURL] = &clusterPeer{}
+	}
+}
+
+// status return the members of the cluster
+func (c *cluster) status() *clusterStatus {
+	status := &clusterStatus{
+		Self:    c.self,
+		Members: c.members(),
+		Down:    make([]string, 0),
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for eachURL, eachPeer := range c.peers {
+		if !eachPeer.alive {
+			status.Down = append(status.Down, eachURL)
+		}
+	}
+	sort.Strings(status.Down)
+	return status
+}
+
+// newClusterClient return the client calling the other replicas, each call start a client span and send the traceparent header
+func (s *Server) newClusterClient() *http.Client {
+	return &http.Client{
+		Timeout: CLUSTER_PEER_TIMEOUT,
+		Transport: otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithTracerProvider(s.tracerProvider),
+			otelhttp.WithPropagators(tracePropagator),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return "cluster " + r.Method
+			}),
+		),
+	}
+}
+
+// newClusterRequest return a request to another replica, authenticated with the cluster token
+func (s *Server) newClusterRequest(ctx context.Context, method string, target string, body []byte) (*http.Request, error) {
+	bodyReader := io.Reader(http.NoBody)
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.config.clusterToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.clusterToken)
+	}
+	if requestID := requestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(REQUEST_ID_HEADER, requestID)
+	}
+	return req, nil
+}
+
+// authenticateCluster check whether the request of another replica carries the cluster token, otherwise write 401 and return false,
+// every call is rejected when no cluster token is configured
+func (s *Server) authenticateCluster(w http.ResponseWriter, r *http.Request) bool {
+	if s.config.clusterToken != "" && bearerTokenValid(r, s.config.clusterToken) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="cluster"`)
+	writeProblem(w, r, http.StatusUnauthorized, "cluster token is missing or invalid", nil)
+	return false
+}
+
+// fetchFromOwners fetch the users owned by the other replicas from their owner into the results, return the usernames left to fetch from upstream:
+// the ones owned by this replica and the ones whose owner failed
+func (s *Server) fetchFromOwners(client *http.Client, usernames []string, userFetchResults map[string]*userFetchResult) []string {
+	ctx := githubClientContext(client)
+	localUsernames := make([]string, 0)
+	peerUsernames := make(map[string][]string)
+	for _, eachUsername := range usernames {
+		owner := s.cluster.owner(eachUsername)
+		if owner == s.cluster.self {
+			localUsernames = append(localUsernames, eachUsername)
+		} else {
+			peerUsernames[owner] = append(peerUsernames[owner], eachUsername)
+		}
+	}
+
+	for eachPeer, eachUsernames := range peerUsernames {
+		peerResults, err := s.forwardUserInfos(ctx, eachPeer, eachUsernames, false)
+		if err != nil {
+			s.logger.warn("encounter err when fetching users from their owner, fetching them from github", logFields{"request_id": requestIDFromContext(ctx), "peer": eachPeer, "usernames": len(eachUsernames), "err": err})
+			localUsernames = append(localUsernames, eachUsernames...)
+			continue
+		}
+		for eachUsername, eachResult := range peerResults {
+			userFetchResults[eachUsername] = eachResult
+		}
+	}
+	return localUsernames
+}
+
+// forwardUserInfos fetch the users from the replica owning them, the users are cached with the time they were stored by the owner so they expire together,
+// with refresh the owner drop its cached users and fetch them again from upstream
+func (s *Server) forwardUserInfos(ctx context.Context, peerURL string, usernames []string, refresh bool) (map[string]*userFetchResult, error) {
+	usersURL := peerURL + CLUSTER_USERS_PATH + "?usernames=" + url.QueryEscape(strings.Join(usernames, ","))
+	if refresh {
+		usersURL += "&refresh=true"
+	}
+	req, err := s.newClusterRequest(ctx, http.MethodGet, usersURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.newClusterClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cluster peer responded with status %d", resp.StatusCode)
+	}
+	resultObj := &clusterUsers{}
+	err = json.NewDecoder(resp.Body).Decode(resultObj)
+	if err != nil {
+		return nil, err
+	}
+
+	userFetchResults := make(map[string]*userFetchResult)
+	for _, eachUser := range resultObj.Users {
+		userFetchResult := &userFetchResult{
+			userInfo: eachUser.User,
+		}
+		if eachUser.Error != "" {
+			userFetchResult.err = &clusterPeerError{
+				StatusCode: eachUser.StatusCode,
+				Message:    eachUser.Error,
+			}
+		} else if eachUser.User != nil && !eachUser.StoredAt.IsZero() {
+			s.githubUserInfoCache.SetEntry(eachUser.Username, &ServerCacheEntry[model.GithubUserInfo]{
+				Value:     eachUser.User,
+				StoredAt:  eachUser.StoredAt,
+				ExpiresAt: eachUser.ExpiresAt,
+			})
+		}
+		userFetchResults[eachUser.Username] = userFetchResult
+	}
+	for _, eachUsername := range usernames {
+		if userFetchResults[eachUsername] == nil {
+			userFetchResults[eachUsername] = &userFetchResult{
+				err: &clusterPeerError{
+					StatusCode: http.StatusBadGateway,
+					Message:    fmt.Sprintf("cluster peer %s did not return the user", peerURL),
+				},
+			}
+		}
+	}
+	return userFetchResults, nil
+}
+
+// clusterUsers handling GET /internal/cluster/users?usernames=[&refresh=true], the users requested by another replica are served from the cache or fetched
+// from upstream (always with refresh), never forwarded again
+func (s *Server) clusterUsers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	if !s.authenticateCluster(w, r) {
+		return
+	}
+	// The peers forward plain logins, the organizations have been expanded by the replica receiving the request
+	usernames := make([]string, 0)
+	for _, eachUsername := range strings.Split(r.FormValue("usernames"), ",") {
+		login := strings.TrimSpace(eachUsername)
+		if login == "" {
+			continue
+		}
+		if strings.HasPrefix(login, ORGANIZATION_USERNAME_PREFIX) {
+			writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("organization %q is not accepted from a cluster peer", login), nil)
+			return
+		}
+		usernames = append(usernames, login)
+	}
+	if len(usernames) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, "usernames is required", nil)
+		return
+	}
+	if invalidErrors := invalidUsernameErrors(usernames); len(invalidErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, "invalid usernames", invalidErrors)
+		return
+	}
+
+	if r.FormValue("refresh") == "true" {
+		for _, eachUsername := range usernames {
+			s.githubUserInfoCache.Delete(eachUsername)
+		}
+	}
+
+	resultObj := &clusterUsers{
+		Users: make([]*clusterUser, 0, len(usernames)),
+	}
+	for eachUsername, eachResult := range s.retrieveUserInfosFrom(s.newGithubClient(r.Context()), usernames, false) {
+		user := &clusterUser{
+			Username: eachUsername,
+			User:     eachResult.userInfo,
+		}
+		if eachResult.err != nil {
+			user.Error = eachResult.err.Error()
+			user.StatusCode = upstreamErrorStatus(eachResult.err)
+		} else if entry := s.githubUserInfoCache.GetEntry(eachUsername); entry != nil {
+			user.StoredAt = entry.StoredAt
+			user.ExpiresAt = entry.ExpiresAt
+		}
+		resultObj.Users = append(resultObj.Users, user)
+	}
+	sort.Slice(resultObj.Users, func(i, j int) bool {
+		return resultObj.Users[i].Username < resultObj.Users[j].Username
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSONResponse(w, r, resultObj)
+}
+
+// clusterHeartbeat handling POST /internal/cluster/heartbeat, the sending replica is alive and the members it knows are learnt,
+// respond with the members known by this replica
+func (s *Server) clusterHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if !s.authenticateCluster(w, r) {
+		return
+	}
+	heartbeat := &clusterMembers{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(heartbeat)
+	if err != nil || normalizeClusterURL(heartbeat.Member) == "" {
+		writeProblem(w, r, http.StatusBadRequest, "invalid heartbeat, expected the member sending it and the members it knows", nil)
+		return
+	}
+	if s.cluster.markAlive(heartbeat.Member) {
+		s.logger.info("cluster peer is up", logFields{"peer": normalizeClusterURL(heartbeat.Member)})
+	}
+	s.cluster.learn(heartbeat.Members)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSONResponse(w, r, &clusterMembers{Member: s.cluster.self, Members: s.cluster.members()})
+}
+
+// sendHeartbeat send a heartbeat to the peer, return the members it knows
+func (s *Server) sendHeartbeat(peerURL string, heartbeat *clusterMembers) (*clusterMembers, error) {
+	body, err := json.Marshal(heartbeat)
+	if err != nil {
+		return nil, err
+	}
+	req, err := s.newClusterRequest(s.cluster.ctx, http.MethodPost, peerURL+CLUSTER_HEARTBEAT_PATH, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.newClusterClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cluster peer responded with status %d", resp.StatusCode)
+	}
+	members := &clusterMembers{}
+	err = json.NewDecoder(resp.Body).Decode(members)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// sendHeartbeats send a heartbeat to every known peer concurrently, a peer answering is alive and the members it knows are learnt,
+// a peer failing CLUSTER_PEER_FAILURE_THRESHOLD heartbeats in a row is removed from the ring
+func (s *Server) sendHeartbeats() {
+	heartbeat := &clusterMembers{Member: s.cluster.self, Members: s.cluster.members()}
+	wg := &sync.WaitGroup{}
+	for _, eachPeer := range s.cluster.peerURLs() {
+		wg.Add(1)
+		go func(peerURL string) {
+			defer wg.Done()
+			members, err := s.sendHeartbeat(peerURL, heartbeat)
+			if err != nil {
+				if s.cluster.markFailed(peerURL) {
+					s.logger.warn("cluster peer is down, its users are owned by the other replicas", logFields{"peer": peerURL, "err": err})
+				}
+				return
+			}
+			if s.cluster.markAlive(peerURL) {
+				s.logger.info("cluster peer is up", logFields{"peer": peerURL})
+			}
+			s.cluster.learn(members.Members)
+		}(eachPeer)
+	}
+	wg.Wait()
+}
+
+// sendHeartbeatsPeriodically send the heartbeats every cluster heartbeat interval, starting now, until the heartbeats are stopped
+func (s *Server) sendHeartbeatsPeriodically() {
+	ticker := time.NewTicker(s.config.clusterHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		s.sendHeartbeats()
+		select {
+		case <-ticker.C:
+		case <-s.cluster.ctx.Done():
+			return
+		}
+	}
+}
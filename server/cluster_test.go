@@ -0,0 +1,450 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// clusterTestReplica a replica of a test cluster served over HTTP
+type clusterTestReplica struct {
+	server     *Server
+	httpServer *httptest.Server
+	url        string
+}
+
+// newClusterTestReplicas start a replica per peer list, peers[i] being the indexes of the replicas known by replica i at startup,
+// the replicas are closed at the end of the test
+func newClusterTestReplicas(t *testing.T, githubAPIURL string, clusterToken string, peers [][]int) []*clusterTestReplica {
+	replicas := make([]*clusterTestReplica, len(peers))
+	for i := range replicas {
+		httpServer := httptest.NewUnstartedServer(nil)
+		replicas[i] = &clusterTestReplica{
+			httpServer: httpServer,
+			url:        "http://" + httpServer.Listener.Addr().String(),
+		}
+	}
+	for i, eachReplica := range replicas {
+		peerURLs := make([]string, 0)
+		for _, eachPeer := range peers[i] {
+			peerURLs = append(peerURLs, replicas[eachPeer].url+"/")
+		}
+		config := NewServerConfig("", 8777, githubAPIURL, "users").WithClusterSelfURL(eachReplica.url).WithClusterPeers(peerURLs).WithClusterToken(clusterToken)
+		eachReplica.server = NewServer(config)
+		eachReplica.server.logger = newLogger(&bytes.Buffer{}, LOG_LEVEL_INFO)
+		eachReplica.server.registerHandlers()
+		eachReplica.httpServer.Config.Handler = eachReplica.server.serverMux
+		eachReplica.httpServer.Start()
+		t.Cleanup(eachReplica.httpServer.Close)
+	}
+	return replicas
+}
+
+func TestClusterRing(t *testing.T) {
+	c := newCluster("http://a/", []string{"http://b", "http://a", " http://c/ ", "http://b/"})
+	if members := strings.Join(c.members(), ","); members != "http://a,http://b,http://c" {
+		t.Fatalf("expected members http://a,http://b,http://c, got %v", members)
+	}
+
+	// Every replica place the usernames on the same member whatever the order it learnt the members in
+	other := newCluster("http://c", []string{"http://b"})
+	other.markAlive("http://a")
+	owners := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		username := fmt.Sprintf("user%d", i)
+		owner := c.owner(username)
+		if otherOwner := other.owner(username); otherOwner != owner {
+			t.Fatalf("expected %v owned by %v on every replica, got %v", username, owner, otherOwner)
+		}
+		if c.owner(strings.ToUpper(username)) != owner {
+			t.Errorf("expected %v placed case insensitively", username)
+		}
+		owners[owner]++
+	}
+	if len(owners) != 3 {
+		t.Errorf("expected the usernames spread over 3 members, got %v", owners)
+	}
+
+	// A peer is removed from the ring after failed heartbeats in a row and added back once it answers
+	for i := 1; i < CLUSTER_PEER_FAILURE_THRESHOLD; i++ {
+		if c.markFailed("http://b") {
+			t.Fatalf("expected http://b removed after %v failures, got after %v", CLUSTER_PEER_FAILURE_THRESHOLD, i)
+		}
+	}
+	if !c.markFailed("http://b") {
+		t.Fatalf("expected http://b removed after %v failures", CLUSTER_PEER_FAILURE_THRESHOLD)
+	}
+	for i := 0; i < 300; i++ {
+		if owner := c.owner(fmt.Sprintf("user%d", i)); owner == "http://b" {
+			t.Fatalf("expected no username owned by http://b once down")
+		}
+	}
+	if status := c.status(); strings.Join(status.Members, ",") != "http://a,http://c" || strings.Join(status.Down, ",") != "http://b" {
+		t.Errorf("expected http://b down, got %+v", status)
+	}
+	if !c.markAlive("http://b/") || c.markAlive("http://b") {
+		t.Errorf("expected http://b added back once")
+	}
+
+	// Members learnt join the ring once they answer a heartbeat
+	c.learn([]string{"http://d", "http://a"})
+	if strings.Join(c.peerURLs(), ",") != "http://b,http://c,http://d" || len(c.members()) != 3 {
+		t.Errorf("expected http://d known but not a member, got peers %v and members %v", c.peerURLs(), c.members())
+	}
+}
+
+func TestClusterFetchUsersOnce(t *testing.T) {
+	callsLock := sync.Mutex{}
+	calls := make(map[string]int)
+	githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		callsLock.Lock()
+		calls[login]++
+		callsLock.Unlock()
+		switch login {
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			jsonString, _ := json.Marshal(model.GithubUserInfo{Login: login, Followers: 10, PublicRepos: 5})
+			w.Write(jsonString)
+		}
+	}))
+	defer githubAPITestServer.Close()
+	replicas := newClusterTestReplicas(t, githubAPITestServer.URL, "secret", [][]int{{1, 2}, {0, 2}, {0, 1}})
+
+	usernames := []string{"missing", "broken"}
+	for i := 0; i < 12; i++ {
+		usernames = append(usernames, fmt.Sprintf("user%d", i))
+	}
+	for _, eachReplica := range replicas {
+		responseRecorder := httptest.NewRecorder()
+		eachReplica.server.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/v1/retrieveUsers?usernames="+strings.Join(usernames, ","), nil))
+		resultObj := &model.ResultRetrieveUsers{}
+		json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+		if responseRecorder.Code != http.StatusOK || len(resultObj.Users) != 12 {
+			t.Fatalf("expected 12 users from %v, got status %v and %s", eachReplica.url, responseRecorder.Code, responseRecorder.Body.String())
+		}
+		if resultObj.Users[0].AvgFollowersPerPublicRepo != 2 {
+			t.Errorf("expected the average followers computed by the owner, got %+v", resultObj.Users[0])
+		}
+		if len(resultObj.Errors) != 2 || !strings.Contains(resultObj.Errors[0].Message, `username "missing" not found`) || !strings.Contains(resultObj.Errors[1].Message, "status 500") {
+			t.Errorf("expected missing not found and broken failing upstream on %v, got %+v", eachReplica.url, resultObj.Errors)
+		}
+	}
+
+	// Each user is fetched from github by its owner only, the failures are not cached
+	for _, eachUsername := range usernames {
+		expectedCalls := 1
+		if eachUsername == "broken" {
+			expectedCalls = 3
+		}
+		callsLock.Lock()
+		usernameCalls := calls[eachUsername]
+		callsLock.Unlock()
+		if usernameCalls != expectedCalls {
+			t.Errorf("expected %v fetched %v times from github, got %v", eachUsername, expectedCalls, usernameCalls)
+		}
+	}
+
+	// The status code of the errors reported by the owner is kept
+	for _, eachReplica := range replicas {
+		if eachReplica.server.cluster.owner("broken") == eachReplica.url {
+			continue
+		}
+		userFetchResult := eachReplica.server.retrieveUserInfos(http.DefaultClient, []string{"broken"})["broken"]
+		var peerErr *clusterPeerError
+		if statusCode := upstreamErrorStatus(userFetchResult.err); !errors.As(userFetchResult.err, &peerErr) || statusCode != http.StatusBadGateway {
+			t.Errorf("expected broken failing with status %v on %v, got %v", http.StatusBadGateway, eachReplica.url, statusCode)
+		}
+	}
+
+	// The users fetched from their owner are cached with the time they were stored by the owner
+	for _, eachReplica := range replicas {
+		for _, eachOwner := range replicas {
+			if eachOwner == eachReplica {
+				continue
+			}
+			for _, eachUsername := range usernames[2:] {
+				if eachReplica.server.cluster.owner(eachUsername) != eachOwner.url {
+					continue
+				}
+				entry, ownerEntry := eachReplica.server.githubUserInfoCache.GetEntry(eachUsername), eachOwner.server.githubUserInfoCache.GetEntry(eachUsername)
+				if entry == nil || ownerEntry == nil || !entry.StoredAt.Equal(ownerEntry.StoredAt) || !entry.ExpiresAt.Equal(ownerEntry.ExpiresAt) {
+					t.Errorf("expected %v cached as on its owner %v, got %+v and %+v", eachUsername, eachOwner.url, entry, ownerEntry)
+				}
+			}
+		}
+	}
+}
+
+func TestClusterOwnerDown(t *testing.T) {
+	var calls int32
+	githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		login := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		jsonString, _ := json.Marshal(model.GithubUserInfo{Login: login})
+		w.Write(jsonString)
+	}))
+	defer githubAPITestServer.Close()
+	replicas := newClusterTestReplicas(t, githubAPITestServer.URL, "secret", [][]int{{1}, {0}})
+	replicas[1].httpServer.Close()
+
+	// The users owned by the replica down are fetched from github
+	usernames := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		usernames = append(usernames, fmt.Sprintf("user%d", i))
+	}
+	responseRecorder := httptest.NewRecorder()
+	replicas[0].server.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/v1/retrieveUsers?usernames="+strings.Join(usernames, ","), nil))
+	resultObj := &model.ResultRetrieveUsers{}
+	json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+	if responseRecorder.Code != http.StatusOK || len(resultObj.Users) != 10 || atomic.LoadInt32(&calls) != 10 {
+		t.Errorf("expected 10 users fetched from github, got status %v, %v users and %v calls", responseRecorder.Code, len(resultObj.Users), atomic.LoadInt32(&calls))
+	}
+
+	// The replica down is removed from the ring by the heartbeats
+	for i := 0; i < CLUSTER_PEER_FAILURE_THRESHOLD; i++ {
+		replicas[0].server.sendHeartbeats()
+	}
+	if status := replicas[0].server.cluster.status(); len(status.Members) != 1 || len(status.Down) != 1 {
+		t.Errorf("expected the replica down removed from the ring, got %+v", status)
+	}
+}
+
+func TestClusterPeerTimesNotTrusted(t *testing.T) {
+	// The peer claims its users were stored in the future and never expire
+	storedAt := time.Now().Add(time.Hour)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users := make([]*clusterUser, 0)
+		for _, eachUsername := range strings.Split(r.URL.Query().Get("usernames"), ",") {
+			users = append(users, &clusterUser{Username: eachUsername, User: &model.GithubUserInfo{Login: eachUsername}, StoredAt: storedAt})
+		}
+		json.NewEncoder(w).Encode(&clusterUsers{Users: users})
+	}))
+	defer peer.Close()
+	config := NewServerConfig("", 8777, "http://localhost", "users").WithClusterSelfURL("http://self").WithClusterPeers([]string{peer.URL}).WithClusterToken("secret")
+	s := NewServer(config)
+
+	username := ""
+	for i := 0; username == ""; i++ {
+		if eachUsername := fmt.Sprintf("user%d", i); s.cluster.owner(eachUsername) == peer.URL {
+			username = eachUsername
+		}
+	}
+	before := time.Now()
+	userFetchResult := s.retrieveUserInfos(http.DefaultClient, []string{username})[username]
+	entry := s.githubUserInfoCache.GetEntry(username)
+	if userFetchResult.err != nil || entry == nil {
+		t.Fatalf("expected %v fetched from the peer and cached, got %v and %+v", username, userFetchResult.err, entry)
+	}
+	if entry.StoredAt.Before(before) || entry.StoredAt.After(time.Now()) || entry.ExpiresAt.After(time.Now().Add(s.config.cacheTTL)) {
+		t.Errorf("expected %v stored now and expiring within the ttl, got %+v", username, entry)
+	}
+}
+
+func TestClusterAdminRefresh(t *testing.T) {
+	var calls int32
+	githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		jsonString, _ := json.Marshal(model.GithubUserInfo{Login: login, Followers: int(atomic.AddInt32(&calls, 1))})
+		w.Write(jsonString)
+	}))
+	defer githubAPITestServer.Close()
+	replicas := newClusterTestReplicas(t, githubAPITestServer.URL, "secret", [][]int{{1}, {0}})
+	replicas[0].server.config.WithAdminToken("admin")
+
+	username := ""
+	for i := 0; username == ""; i++ {
+		if eachUsername := fmt.Sprintf("user%d", i); replicas[0].server.cluster.owner(eachUsername) == replicas[1].url {
+			username = eachUsername
+		}
+	}
+	if userInfo, err := replicas[0].server.retrieveUserInfo(http.DefaultClient, username); err != nil || userInfo.Followers != 1 {
+		t.Fatalf("expected %v fetched by its owner, got %+v %v", username, userInfo, err)
+	}
+
+	// The user is refreshed on its owner so no replica keep the previous profile
+	req := httptest.NewRequest(http.MethodPost, "/admin/refresh/"+username, nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	responseRecorder := httptest.NewRecorder()
+	replicas[0].server.serverMux.ServeHTTP(responseRecorder, req)
+	userInfo := &model.GithubUserInfo{}
+	json.Unmarshal(responseRecorder.Body.Bytes(), userInfo)
+	if responseRecorder.Code != http.StatusOK || userInfo.Followers != 2 {
+		t.Fatalf("expected the refreshed profile, got status %v and %s", responseRecorder.Code, responseRecorder.Body.String())
+	}
+	for i, eachReplica := range replicas {
+		if cached := eachReplica.server.githubUserInfoCache.Get(username); cached == nil || cached.Followers != 2 {
+			t.Errorf("expected the refreshed profile cached on replica %v, got %+v", i, cached)
+		}
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("expected 2 calls to github, got %v", calls)
+	}
+}
+
+func TestClusterHeartbeats(t *testing.T) {
+	// Replica 0 know replica 1, replica 1 know nobody and replica 2 know replica 1
+	replicas := newClusterTestReplicas(t, "http://localhost", "secret", [][]int{{1}, {}, {1}})
+	servers := []*Server{replicas[0].server, replicas[1].server, replicas[2].server}
+
+	servers[0].sendHeartbeats()
+	if members := servers[1].cluster.members(); len(members) != 2 {
+		t.Errorf("expected replica 1 to add replica 0 sending it a heartbeat, got %v", members)
+	}
+	servers[2].sendHeartbeats()
+	if members := servers[2].cluster.members(); len(members) != 2 || len(servers[2].cluster.peerURLs()) != 2 {
+		t.Errorf("expected replica 2 to learn replica 0 from replica 1 without adding it yet, got %v", members)
+	}
+	servers[2].sendHeartbeats()
+	servers[0].sendHeartbeats()
+	for i, eachServer := range servers {
+		if members := strings.Join(eachServer.cluster.members(), ","); len(eachServer.cluster.members()) != 3 {
+			t.Errorf("expected replica %v to know the 3 replicas, got %v", i, members)
+		}
+	}
+
+	// The members are reported by /status
+	responseRecorder := httptest.NewRecorder()
+	servers[1].serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	status := &serverStatus{}
+	json.Unmarshal(responseRecorder.Body.Bytes(), status)
+	if status.Cluster == nil || status.Cluster.Self != replicas[1].url || len(status.Cluster.Members) != 3 {
+		t.Errorf("expected the cluster members in the status, got %s", responseRecorder.Body.String())
+	}
+}
+
+func TestClusterAuthentication(t *testing.T) {
+	replicas := newClusterTestReplicas(t, "http://localhost", "secret", [][]int{{}})
+	tests := map[string]struct {
+		Method             string
+		Target             string
+		Body               string
+		Authorization      string
+		ExpectedStatusCode int
+	}{
+		"Test users without token": {
+			Method:             http.MethodGet,
+			Target:             CLUSTER_USERS_PATH + "?usernames=a",
+			ExpectedStatusCode: http.StatusUnauthorized,
+		},
+		"Test heartbeat with invalid token": {
+			Method:             http.MethodPost,
+			Target:             CLUSTER_HEARTBEAT_PATH,
+			Body:               `{"member":"http://b","members":["http://b"]}`,
+			Authorization:      "Bearer other",
+			ExpectedStatusCode: http.StatusUnauthorized,
+		},
+		"Test heartbeat": {
+			Method:             http.MethodPost,
+			Target:             CLUSTER_HEARTBEAT_PATH,
+			Body:               `{"member":"http://b","members":["http://b"]}`,
+			Authorization:      "Bearer secret",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		"Test heartbeat without member": {
+			Method:             http.MethodPost,
+			Target:             CLUSTER_HEARTBEAT_PATH,
+			Body:               `{"members":["http://b"]}`,
+			Authorization:      "Bearer secret",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test users with invalid username": {
+			Method:             http.MethodGet,
+			Target:             CLUSTER_USERS_PATH + "?usernames=a_b",
+			Authorization:      "Bearer secret",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test users with organization": {
+			Method:             http.MethodGet,
+			Target:             CLUSTER_USERS_PATH + "?usernames=a,org:apache",
+			Authorization:      "Bearer secret",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test users without usernames": {
+			Method:             http.MethodGet,
+			Target:             CLUSTER_USERS_PATH + "?usernames=%20,",
+			Authorization:      "Bearer secret",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test heartbeat with GET": {
+			Method:             http.MethodGet,
+			Target:             CLUSTER_HEARTBEAT_PATH,
+			Authorization:      "Bearer secret",
+			ExpectedStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := httptest.NewRequest(test.Method, test.Target, strings.NewReader(test.Body))
+			if test.Authorization != "" {
+				req.Header.Set("Authorization", test.Authorization)
+			}
+			responseRecorder := httptest.NewRecorder()
+			replicas[0].server.serverMux.ServeHTTP(responseRecorder, req)
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Errorf("expected status %v, got %v %s", test.ExpectedStatusCode, responseRecorder.Code, responseRecorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestClusterUsersTrimmed(t *testing.T) {
+	replicas := newClusterTestReplicas(t, "http://localhost", "secret", [][]int{{}})
+	s := replicas[0].server
+	s.githubUserInfoCache.Set("a", &model.GithubUserInfo{Login: "a"})
+	s.githubUserInfoCache.Set("b", &model.GithubUserInfo{Login: "b"})
+
+	req := httptest.NewRequest(http.MethodGet, CLUSTER_USERS_PATH+"?usernames=%20a%20,,b", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	responseRecorder := httptest.NewRecorder()
+	s.serverMux.ServeHTTP(responseRecorder, req)
+	resultObj := &clusterUsers{}
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+	if err != nil {
+		t.Fatalf("expected no error when parsing the users, got %v", err)
+	}
+	usernames := make([]string, 0)
+	for _, eachUser := range resultObj.Users {
+		if eachUser.User == nil || eachUser.Error != "" {
+			t.Errorf("expected %q served from the cache, got %+v", eachUser.Username, eachUser)
+		}
+		usernames = append(usernames, eachUser.Username)
+	}
+	if strings.Join(usernames, ",") != "a,b" {
+		t.Errorf("expected users a,b, got %q", usernames)
+	}
+}
+
+func TestClusterWithoutToken(t *testing.T) {
+	replicas := newClusterTestReplicas(t, "http://localhost", "", [][]int{{}})
+	req := httptest.NewRequest(http.MethodPost, CLUSTER_HEARTBEAT_PATH, strings.NewReader(`{"member":"http://b","members":["http://b"]}`))
+	responseRecorder := httptest.NewRecorder()
+	replicas[0].server.serverMux.ServeHTTP(responseRecorder, req)
+	if responseRecorder.Code != http.StatusUnauthorized || len(replicas[0].server.cluster.peerURLs()) != 0 {
+		t.Errorf("expected the heartbeat rejected without cluster token, got status %v and peers %v", responseRecorder.Code, replicas[0].server.cluster.peerURLs())
+	}
+}
+
+func TestClusterRoutesNotClustered(t *testing.T) {
+	s := NewServer(NewServerConfig("", 8777, "http://localhost", "users"))
+	s.registerHandlers()
+	responseRecorder := httptest.NewRecorder()
+	s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, CLUSTER_USERS_PATH+"?usernames=a", nil))
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Errorf("expected the cluster routes not registered, got status %v", responseRecorder.Code)
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// CONTENT_ENCODING_GZIP gzip content coding
+	CONTENT_ENCODING_GZIP = "gzip"
+	// CONTENT_ENCODING_DEFLATE deflate content coding
+	CONTENT_ENCODING_DEFLATE = "deflate"
+	// COMPRESSION_MIN_SIZE responses smaller than this size are written uncompressed, unless they are flushed before
+	COMPRESSION_MIN_SIZE = 1024
+)
+
+// contentEncodings supported content codings, the first one wins when the client accepts both with the same quality
+var contentEncodings = []string{CONTENT_ENCODING_GZIP, CONTENT_ENCODING_DEFLATE}
+
+// compressorWriter a compressor writing to the response, reused between responses
+type compressorWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressorPools pool of compressors of each content coding
+var compressorPools = map[string]*sync.Pool{
+	CONTENT_ENCODING_GZIP: {New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}},
+	CONTENT_ENCODING_DEFLATE: {New: func() interface{} {
+		compressor, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return compressor
+	}},
+}
+
+// negotiateContentEncoding select the content coding from the Accept-Encoding header, empty when the response must not be compressed
+func negotiateContentEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, eachCoding := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(eachCoding, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		quality := 1.0
+		for _, eachParam := range params[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(eachParam), "=")
+			if found && strings.TrimSpace(name) == "q" {
+				parsedQuality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					quality = parsedQuality
+				}
+			}
+		}
+		qualities[coding] = quality
+	}
+
+	// Pick the supported coding with the highest quality, * applies to the codings not listed
+	bestEncoding := ""
+	bestQuality := 0.0
+	for _, eachEncoding := range contentEncodings {
+		quality, found := qualities[eachEncoding]
+		if !found {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			bestEncoding = eachEncoding
+			bestQuality = quality
+		}
+	}
+	return bestEncoding
+}
+
+// compressionHandler compress the responses of the handler with the content coding negotiated from the Accept-Encoding header
+func compressionHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateContentEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		compressedWriter := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer compressedWriter.Close()
+		handler.ServeHTTP(compressedWriter, r)
+	})
+}
+
+// compressResponseWriter response writer compressing the body, the first bytes are buffered to leave small responses uncompressed
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	statusCode  int
+	wroteHeader bool
+	passThrough bool
+	buffer      []byte
+	compressor  compressorWriter
+}
+
+// WriteHeader comply with http.ResponseWriter interface, the status is written once it is decided whether the body is compressed
+func (w *compressResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = statusCode
+
+	// Responses without body or already encoded are not compressed
+	if statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+		w.passThrough = true
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+// Write comply with http.ResponseWriter interface
+func (w *compressResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.compressor != nil {
+		return w.compressor.Write(data)
+	}
+
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) >= COMPRESSION_MIN_SIZE {
+		err := w.startCompression()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flush comply with http.Flusher interface, a flushed response is compressed whatever its size so streams are compressed
+func (w *compressResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passThrough {
+		if w.compressor == nil {
+			w.startCompression()
+		}
+		w.compressor.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap return the underlying response writer
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// startCompression write the headers of the compressed response and the buffered bytes through the compressor
+func (w *compressResponseWriter) startCompression() error {
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		// Sniff the uncompressed bytes, net/http would sniff the compressed ones
+		header.Set("Content-Type", http.DetectContentType(w.buffer))
+	}
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	w.compressor = compressorPools[w.encoding].Get().(compressorWriter)
+	w.compressor.Reset(w.ResponseWriter)
+	_, err := w.compressor.Write(w.buffer)
+	w.buffer = nil
+	return err
+}
+
+// Close write the buffered bytes of a small response uncompressed, or finish the compressed stream
+func (w *compressResponseWriter) Close() error {
+	if !w.wroteHeader || w.passThrough {
+		return nil
+	}
+	if w.compressor == nil {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		_, err := w.ResponseWriter.Write(w.buffer)
+		w.buffer = nil
+		return err
+	}
+
+	err := w.compressor.Close()
+	compressorPools[w.encoding].Put(w.compressor)
+	w.compressor = nil
+	return err
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateContentEncoding(t *testing.T) {
+	tests := map[string]struct {
+		AcceptEncoding string
+		Expected       string
+	}{
+		"No Accept-Encoding header": {
+			Expected: "",
+		},
+		"Gzip": {
+			AcceptEncoding: "gzip",
+			Expected:       CONTENT_ENCODING_GZIP,
+		},
+		"Gzip wins ties": {
+			AcceptEncoding: "deflate, gzip, br",
+			Expected:       CONTENT_ENCODING_GZIP,
+		},
+		"Quality values": {
+			AcceptEncoding: "gzip;q=0.5, deflate;q=0.8",
+			Expected:       CONTENT_ENCODING_DEFLATE,
+		},
+		"Gzip refused": {
+			AcceptEncoding: "gzip;q=0, deflate",
+			Expected:       CONTENT_ENCODING_DEFLATE,
+		},
+		"Wildcard": {
+			AcceptEncoding: "*",
+			Expected:       CONTENT_ENCODING_GZIP,
+		},
+		"Wildcard except gzip": {
+			AcceptEncoding: "*;q=0.5, gzip;q=0",
+			Expected:       CONTENT_ENCODING_DEFLATE,
+		},
+		"Identity only": {
+			AcceptEncoding: "identity",
+			Expected:       "",
+		},
+		"Unsupported coding": {
+			AcceptEncoding: "br",
+			Expected:       "",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result := negotiateContentEncoding(test.AcceptEncoding)
+			if result != test.Expected {
+				t.Errorf("expected %q, got %q", test.Expected, result)
+			}
+		})
+	}
+}
+
+func TestCompressionHandler(t *testing.T) {
+	largeBody := strings.Repeat(`{"login":"a","name":"a"}`, 100)
+	tests := map[string]struct {
+		Method                  string
+		AcceptEncoding          string
+		Body                    string
+		Flush                   bool
+		StatusCode              int
+		ExpectedContentEncoding string
+	}{
+		"Test gzip": {
+			AcceptEncoding:          "gzip, deflate",
+			Body:                    largeBody,
+			ExpectedContentEncoding: CONTENT_ENCODING_GZIP,
+		},
+		"Test deflate": {
+			AcceptEncoding:          "deflate",
+			Body:                    largeBody,
+			ExpectedContentEncoding: CONTENT_ENCODING_DEFLATE,
+		},
+		"Test no Accept-Encoding": {
+			Body: largeBody,
+		},
+		"Test small body is not compressed": {
+			AcceptEncoding: "gzip",
+			Body:           `{"login":"a"}`,
+		},
+		"Test flushed small body is compressed": {
+			AcceptEncoding:          "gzip",
+			Body:                    `{"login":"a"}`,
+			Flush:                   true,
+			ExpectedContentEncoding: CONTENT_ENCODING_GZIP,
+		},
+		"Test HEAD is not compressed": {
+			Method:         http.MethodHead,
+			AcceptEncoding: "gzip",
+		},
+		"Test not modified is not compressed": {
+			AcceptEncoding: "gzip",
+			StatusCode:     http.StatusNotModified,
+		},
+		"Test error status is compressed": {
+			AcceptEncoding:          "gzip",
+			Body:                    largeBody,
+			StatusCode:              http.StatusBadGateway,
+			ExpectedContentEncoding: CONTENT_ENCODING_GZIP,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			statusCode := test.StatusCode
+			if statusCode == 0 {
+				statusCode = http.StatusOK
+			}
+			handler := compressionHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(statusCode)
+				if test.Flush {
+					w.(http.Flusher).Flush()
+				}
+				// Write in chunks so the body crosses the compression threshold in the middle of a write
+				for i := 0; i < len(test.Body); i += 100 {
+					end := i + 100
+					if end > len(test.Body) {
+						end = len(test.Body)
+					}
+					w.Write([]byte(test.Body[i:end]))
+				}
+			}))
+
+			method := test.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+			request := httptest.NewRequest(method, "/retrieveUsers", nil)
+			if test.AcceptEncoding != "" {
+				request.Header.Set("Accept-Encoding", test.AcceptEncoding)
+			}
+			responseRecorder := httptest.NewRecorder()
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != statusCode {
+				t.Errorf("expected status %v, got %v", statusCode, responseRecorder.Code)
+			}
+			if vary := responseRecorder.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("expected Vary Accept-Encoding, got %q", vary)
+			}
+			if contentType := responseRecorder.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("expected content type application/json, got %q", contentType)
+			}
+			contentEncoding := responseRecorder.Header().Get("Content-Encoding")
+			if contentEncoding != test.ExpectedContentEncoding {
+				t.Fatalf("expected content encoding %q, got %q", test.ExpectedContentEncoding, contentEncoding)
+			}
+
+			var bodyReader io.Reader = responseRecorder.Body
+			switch contentEncoding {
+			case CONTENT_ENCODING_GZIP:
+				gzipReader, err := gzip.NewReader(responseRecorder.Body)
+				if err != nil {
+					t.Fatalf("expected no error when reading gzip body, got %v", err)
+				}
+				bodyReader = gzipReader
+			case CONTENT_ENCODING_DEFLATE:
+				bodyReader = flate.NewReader(responseRecorder.Body)
+			}
+			body, err := io.ReadAll(bodyReader)
+			if err != nil {
+				t.Fatalf("expected no error when reading body, got %v", err)
+			}
+			if string(body) != test.Body {
+				t.Errorf("expected body %q, got %q", test.Body, body)
+			}
+		})
+	}
+}
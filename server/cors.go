@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// corsExposedHeaders response headers of the API readable by browser clients besides the CORS-safelisted ones
+var corsExposedHeaders = []string{"ETag", "Last-Modified", "Location", "Retry-After", "Deprecation", "Sunset", "Link", REQUEST_ID_HEADER}
+
+// corsOriginAllowed check whether the origin is allowed by the configuration, * allows any origin
+func (s *Server) corsOriginAllowed(origin string) bool {
+	for _, eachOrigin := range s.config.corsAllowedOrigins {
+		if eachOrigin == "*" || strings.EqualFold(eachOrigin, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMethodAllowed check whether the method is allowed by the configuration
+func (s *Server) corsMethodAllowed(method string) bool {
+	for _, eachMethod := range s.config.corsAllowedMethods {
+		if eachMethod == method {
+			return true
+		}
+	}
+	return false
+}
+
+// corsHeadersAllowed check whether every header of the comma separated list is allowed by the configuration, * allows any header
+func (s *Server) corsHeadersAllowed(headers string) bool {
+	for _, eachHeader := range strings.Split(headers, ",") {
+		eachHeader = strings.TrimSpace(eachHeader)
+		if eachHeader == "" {
+			continue
+		}
+		allowed := false
+		for _, eachAllowedHeader := range s.config.corsAllowedHeaders {
+			if eachAllowedHeader == "*" || strings.EqualFold(eachAllowedHeader, eachHeader) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// setCORSOrigin set the Access-Control-Allow-Origin header, the origin is echoed when credentials are allowed because * is not accepted by browsers with credentials
+func (s *Server) setCORSOrigin(w http.ResponseWriter, origin string) {
+	w.Header().Add("Vary", "Origin")
+	if s.corsOriginAllowed("*") && !s.config.corsAllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if s.config.corsAllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// corsHandler apply the CORS policy of the configuration: answer the preflight requests and add the CORS headers to the responses of allowed origins,
+// requests are served unchanged when no origin is configured
+func (s *Server) corsHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if len(s.config.corsAllowedOrigins) == 0 || origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && requestMethod != "" {
+			// Preflight request
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			requestHeaders := r.Header.Get("Access-Control-Request-Headers")
+			if !s.corsOriginAllowed(origin) || !s.corsMethodAllowed(requestMethod) || !s.corsHeadersAllowed(requestHeaders) {
+				w.Header().Add("Vary", "Origin")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			s.setCORSOrigin(w, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(s.config.corsAllowedMethods, ", "))
+			if requestHeaders != "" {
+				// The allowed headers are checked above, echo them so * works with credentials too
+				w.Header().Set("Access-Control-Allow-Headers", requestHeaders)
+			}
+			if s.config.corsMaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(s.config.corsMaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if s.corsOriginAllowed(origin) {
+			s.setCORSOrigin(w, origin)
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		} else {
+			w.Header().Add("Vary", "Origin")
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
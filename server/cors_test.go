@@ -0,0 +1,182 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	tests := map[string]struct {
+		AllowedOrigins           []string
+		AllowCredentials         bool
+		Method                   string
+		Path                     string
+		Origin                   string
+		RequestMethod            string
+		RequestHeaders           string
+		ExpectedStatusCode       int
+		ExpectedAllowOrigin      string
+		ExpectedAllowCredentials string
+		ExpectedAllowHeaders     string
+		ExpectedMaxAge           string
+	}{
+		"Test preflight on REST route": {
+			AllowedOrigins:       []string{"https://dashboard.example.com"},
+			Method:               http.MethodOptions,
+			Path:                 "/v1/retrieveUsers",
+			Origin:               "https://dashboard.example.com",
+			RequestMethod:        http.MethodGet,
+			RequestHeaders:       "If-None-Match",
+			ExpectedStatusCode:   http.StatusNoContent,
+			ExpectedAllowOrigin:  "https://dashboard.example.com",
+			ExpectedAllowHeaders: "If-None-Match",
+			ExpectedMaxAge:       "600",
+		},
+		"Test preflight on graphql": {
+			AllowedOrigins:       []string{"https://dashboard.example.com"},
+			Method:               http.MethodOptions,
+			Path:                 "/graphql/query",
+			Origin:               "https://dashboard.example.com",
+			RequestMethod:        http.MethodPost,
+			RequestHeaders:       "content-type",
+			ExpectedStatusCode:   http.StatusNoContent,
+			ExpectedAllowOrigin:  "https://dashboard.example.com",
+			ExpectedAllowHeaders: "content-type",
+			ExpectedMaxAge:       "600",
+		},
+		"Test preflight on deprecated route": {
+			AllowedOrigins:      []string{"*"},
+			Method:              http.MethodOptions,
+			Path:                "/retrieveUsers",
+			Origin:              "https://other.example.com",
+			RequestMethod:       http.MethodGet,
+			ExpectedStatusCode:  http.StatusNoContent,
+			ExpectedAllowOrigin: "*",
+			ExpectedMaxAge:      "600",
+		},
+		"Test preflight with credentials echo the origin": {
+			AllowedOrigins:           []string{"*"},
+			AllowCredentials:         true,
+			Method:                   http.MethodOptions,
+			Path:                     "/v1/retrieveUsers",
+			Origin:                   "https://other.example.com",
+			RequestMethod:            http.MethodGet,
+			ExpectedStatusCode:       http.StatusNoContent,
+			ExpectedAllowOrigin:      "https://other.example.com",
+			ExpectedAllowCredentials: "true",
+			ExpectedMaxAge:           "600",
+		},
+		"Test preflight from other origin": {
+			AllowedOrigins:     []string{"https://dashboard.example.com"},
+			Method:             http.MethodOptions,
+			Path:               "/v1/retrieveUsers",
+			Origin:             "https://evil.example.com",
+			RequestMethod:      http.MethodGet,
+			ExpectedStatusCode: http.StatusForbidden,
+		},
+		"Test preflight with method not allowed": {
+			AllowedOrigins:     []string{"https://dashboard.example.com"},
+			Method:             http.MethodOptions,
+			Path:               "/v1/retrieveUsers",
+			Origin:             "https://dashboard.example.com",
+			RequestMethod:      http.MethodDelete,
+			ExpectedStatusCode: http.StatusForbidden,
+		},
+		"Test preflight with header not allowed": {
+			AllowedOrigins:     []string{"https://dashboard.example.com"},
+			Method:             http.MethodOptions,
+			Path:               "/v1/retrieveUsers",
+			Origin:             "https://dashboard.example.com",
+			RequestMethod:      http.MethodGet,
+			RequestHeaders:     "X-Custom",
+			ExpectedStatusCode: http.StatusForbidden,
+		},
+		"Test preflight when CORS is disabled": {
+			Method:             http.MethodOptions,
+			Path:               "/v1/retrieveUsers",
+			Origin:             "https://dashboard.example.com",
+			RequestMethod:      http.MethodGet,
+			ExpectedStatusCode: http.StatusMethodNotAllowed,
+		},
+		"Test request from allowed origin": {
+			AllowedOrigins:      []string{"https://dashboard.example.com"},
+			Method:              http.MethodGet,
+			Path:                "/v1/retrieveUsers?usernames=a",
+			Origin:              "https://dashboard.example.com",
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedAllowOrigin: "https://dashboard.example.com",
+		},
+		"Test request from other origin": {
+			AllowedOrigins:     []string{"https://dashboard.example.com"},
+			Method:             http.MethodGet,
+			Path:               "/v1/retrieveUsers?usernames=a",
+			Origin:             "https://evil.example.com",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		"Test request without origin": {
+			AllowedOrigins:     []string{"*"},
+			Method:             http.MethodGet,
+			Path:               "/v1/retrieveUsers?usernames=a",
+			ExpectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newCacheTestServer()
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users").
+				WithCORSAllowedOrigins(test.AllowedOrigins).
+				WithCORSAllowCredentials(test.AllowCredentials).
+				WithCORSMaxAge(10 * time.Minute)
+			s := NewServer(config)
+			s.registerHandlers()
+			request := httptest.NewRequest(test.Method, test.Path, nil)
+			if test.Origin != "" {
+				request.Header.Set("Origin", test.Origin)
+			}
+			if test.RequestMethod != "" {
+				request.Header.Set("Access-Control-Request-Method", test.RequestMethod)
+			}
+			if test.RequestHeaders != "" {
+				request.Header.Set("Access-Control-Request-Headers", test.RequestHeaders)
+			}
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Fatalf("expected status %v, got %v", test.ExpectedStatusCode, responseRecorder.Code)
+			}
+			header := responseRecorder.Header()
+			if allowOrigin := header.Get("Access-Control-Allow-Origin"); allowOrigin != test.ExpectedAllowOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", test.ExpectedAllowOrigin, allowOrigin)
+			}
+			if allowCredentials := header.Get("Access-Control-Allow-Credentials"); allowCredentials != test.ExpectedAllowCredentials {
+				t.Errorf("expected Access-Control-Allow-Credentials %q, got %q", test.ExpectedAllowCredentials, allowCredentials)
+			}
+			if allowHeaders := header.Get("Access-Control-Allow-Headers"); allowHeaders != test.ExpectedAllowHeaders {
+				t.Errorf("expected Access-Control-Allow-Headers %q, got %q", test.ExpectedAllowHeaders, allowHeaders)
+			}
+			if maxAge := header.Get("Access-Control-Max-Age"); maxAge != test.ExpectedMaxAge {
+				t.Errorf("expected Access-Control-Max-Age %q, got %q", test.ExpectedMaxAge, maxAge)
+			}
+
+			preflight := test.Method == http.MethodOptions
+			if allowMethods := header.Get("Access-Control-Allow-Methods"); (allowMethods != "") != (preflight && test.ExpectedAllowOrigin != "") {
+				t.Errorf("unexpected Access-Control-Allow-Methods %q", allowMethods)
+			}
+			if exposeHeaders := header.Get("Access-Control-Expose-Headers"); (exposeHeaders != "") != (!preflight && test.ExpectedAllowOrigin != "") {
+				t.Errorf("unexpected Access-Control-Expose-Headers %q", exposeHeaders)
+			} else if exposeHeaders != "" && !strings.Contains(exposeHeaders, "ETag") {
+				t.Errorf("expected ETag to be exposed, got %q", exposeHeaders)
+			}
+			if test.Origin != "" && len(test.AllowedOrigins) > 0 && !strings.Contains(strings.Join(header.Values("Vary"), ","), "Origin") {
+				t.Errorf("expected Vary Origin, got %v", header.Values("Vary"))
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// JSON_INDENT indentation of pretty JSON
+	JSON_INDENT = "    "
+)
+
+// jsonPretty check whether the request opts in to indented JSON with pretty=true
+func jsonPretty(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.FormValue("pretty"))
+	return pretty
+}
+
+// writeEncodedJSON write the object as JSON response with the status code and content type, the JSON is encoded straight to the response
+func writeEncodedJSON(w http.ResponseWriter, r *http.Request, statusCode int, contentType string, resultObj interface{}, pretty bool) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	err := encodeJSON(w, resultObj, pretty)
+	if err != nil {
+		// The status is already written, the client receives a truncated body
+		loggerFromContext(r.Context()).error("can not encode JSON response", logFields{"request_id": requestIDFromContext(r.Context()), "err": err})
+	}
+}
+
+// encodeJSON write the JSON encoding of the value followed by a newline, indented when pretty
+func encodeJSON(w io.Writer, value interface{}, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", JSON_INDENT)
+	}
+	return encoder.Encode(value)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRetrieveUsersPretty(t *testing.T) {
+	tests := map[string]struct {
+		Query          string
+		ExpectedPretty bool
+	}{
+		"Test compact by default": {
+			Query:          "usernames=a",
+			ExpectedPretty: false,
+		},
+		"Test pretty": {
+			Query:          "usernames=a&pretty=true",
+			ExpectedPretty: true,
+		},
+		"Test json-compact ignores pretty": {
+			Query:          "usernames=a&format=json-compact&pretty=true",
+			ExpectedPretty: false,
+		},
+		"Test pretty problem": {
+			Query:          "usernames=bad_name!&pretty=true",
+			ExpectedPretty: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				jsonString, _ := json.Marshal(model.GithubUserInfo{Login: "a", Name: "a"})
+				w.Write(jsonString)
+			}))
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+			responseRecorder := httptest.NewRecorder()
+			s.retrieveUsers(responseRecorder, httptest.NewRequest(http.MethodGet, "/retrieveUsers?"+test.Query, nil))
+
+			body := responseRecorder.Body.String()
+			if pretty := strings.Contains(body, "\n"+JSON_INDENT); pretty != test.ExpectedPretty {
+				t.Errorf("expected pretty %v, got %s", test.ExpectedPretty, body)
+			}
+			if !json.Valid(responseRecorder.Body.Bytes()) {
+				t.Errorf("expected valid JSON, got %s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	// USER_FIELD_REPO_STATS sparse field selecting the repository statistics, it implies includeRepoStats=true
+	USER_FIELD_REPO_STATS = "repo_stats"
+)
+
+// userFields the fields of GithubUserInfo that can be selected with the fields query parameter, named after the JSON fields
+var userFields = selectableUserFields()
+
+// sparseFieldsFormats response formats supporting the fields query parameter
+var sparseFieldsFormats = map[string]bool{
+	RESPONSE_FORMAT_JSON:         true,
+	RESPONSE_FORMAT_JSON_COMPACT: true,
+	RESPONSE_FORMAT_CSV:          true,
+}
+
+// sparseResultRetrieveUsers result with only the selected fields of each user
+type sparseResultRetrieveUsers struct {
+	Users  []map[string]json.RawMessage `json:"users"`
+	Errors []*model.ResultError         `json:"errors"`
+}
+
+// selectableUserFields return the JSON field names of GithubUserInfo (except the upstream message) in declaration order
+func selectableUserFields() []string {
+	fields := make([]string, 0)
+	userInfoType := reflect.TypeOf(model.GithubUserInfo{})
+	for i := 0; i < userInfoType.NumField(); i++ {
+		name, _, _ := strings.Cut(userInfoType.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "message" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// parseUserFields parse the comma separated fields query parameter (nil when every field is returned), unknown fields are rejected
+func parseUserFields(fieldsValue string, format string) ([]string, error) {
+	if strings.TrimSpace(fieldsValue) == "" {
+		return nil, nil
+	}
+	if !sparseFieldsFormats[format] {
+		return nil, &responseFormatError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("fields is not supported with format %q, expected one of %s, %s or %s", format, RESPONSE_FORMAT_JSON, RESPONSE_FORMAT_JSON_COMPACT, RESPONSE_FORMAT_CSV),
+		}
+	}
+
+	fields := make([]string, 0)
+	selectedFields := make(map[string]bool)
+	for _, eachField := range strings.Split(fieldsValue, ",") {
+		field := strings.TrimSpace(eachField)
+		if field == "" || selectedFields[field] {
+			continue
+		}
+		if !isUserField(field) {
+			return nil, &responseFormatError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("unknown field %q, expected one of %s", field, strings.Join(userFields, ", ")),
+			}
+		}
+		selectedFields[field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// isUserField check whether the field can be selected
+func isUserField(field string) bool {
+	for _, eachField := range userFields {
+		if eachField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUserField check whether the field is returned with the selected fields (every field is returned when fields is nil)
+func hasUserField(fields []string, field string) bool {
+	if fields == nil {
+		return true
+	}
+	for _, eachField := range fields {
+		if eachField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseResult return the result with only the selected fields of each user
+func sparseResult(resultObj *model.ResultRetrieveUsers, fields []string) (*sparseResultRetrieveUsers, error) {
+	sparseResultObj := &sparseResultRetrieveUsers{
+		Users:  make([]map[string]json.RawMessage, 0, len(resultObj.Users)),
+		Errors: resultObj.Errors,
+	}
+	for _, eachUser := range resultObj.Users {
+		userData, err := json.Marshal(eachUser)
+		if err != nil {
+			return nil, err
+		}
+		userFieldValues := make(map[string]json.RawMessage)
+		err = json.Unmarshal(userData, &userFieldValues)
+		if err != nil {
+			return nil, err
+		}
+
+		sparseUser := make(map[string]json.RawMessage, len(fields))
+		for _, eachField := range fields {
+			if value, found := userFieldValues[eachField]; found {
+				sparseUser[eachField] = value
+			}
+		}
+		sparseResultObj.Users = append(sparseResultObj.Users, sparseUser)
+	}
+	return sparseResultObj, nil
+}
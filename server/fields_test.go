@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseUserFields(t *testing.T) {
+	tests := map[string]struct {
+		Fields        string
+		Format        string
+		Expected      []string
+		ExpectedError bool
+	}{
+		"Test no fields": {
+			Format:   RESPONSE_FORMAT_YAML,
+			Expected: nil,
+		},
+		"Test fields are trimmed and distinct": {
+			Fields:   " login ,followers,login,",
+			Format:   RESPONSE_FORMAT_JSON,
+			Expected: []string{"login", "followers"},
+		},
+		"Test repository statistics field": {
+			Fields:   "login,repo_stats",
+			Format:   RESPONSE_FORMAT_CSV,
+			Expected: []string{"login", "repo_stats"},
+		},
+		"Test unknown field": {
+			Fields:        "login,password",
+			Format:        RESPONSE_FORMAT_JSON,
+			ExpectedError: true,
+		},
+		"Test upstream message is not selectable": {
+			Fields:        "message",
+			Format:        RESPONSE_FORMAT_JSON,
+			ExpectedError: true,
+		},
+		"Test unsupported format": {
+			Fields:        "login",
+			Format:        RESPONSE_FORMAT_XML,
+			ExpectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := parseUserFields(test.Fields, test.Format)
+			if test.ExpectedError {
+				if err == nil {
+					t.Errorf("expected error, got %v", result)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if strings.Join(result, ",") != strings.Join(test.Expected, ",") || (result == nil) != (test.Expected == nil) {
+				t.Errorf("expected %v, got %v", test.Expected, result)
+			}
+		})
+	}
+}
+
+func TestRetrieveUsersSparseFields(t *testing.T) {
+	tests := map[string]struct {
+		Query              string
+		ExpectedStatusCode int
+		ExpectedFields     []string
+	}{
+		"Test JSON fields": {
+			Query:              "fields=login,followers",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFields:     []string{"followers", "login"},
+		},
+		"Test compact JSON fields": {
+			Query:              "fields=public_repos&format=json-compact",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFields:     []string{"public_repos"},
+		},
+		"Test CSV fields": {
+			Query:              "fields=followers,login&format=csv",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFields:     []string{"login", "followers"},
+		},
+		"Test unknown field": {
+			Query:              "fields=login,unknown",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test fields with YAML": {
+			Query:              "fields=login&format=yaml",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test fields with streaming": {
+			Query:              "fields=login&stream=true",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+			responseRecorder := httptest.NewRecorder()
+			target := fmt.Sprintf("/retrieveUsers?usernames=b,a,notfound&%v", test.Query)
+			s.retrieveUsers(responseRecorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Fatalf("expected status %v, got %v", test.ExpectedStatusCode, responseRecorder.Code)
+			}
+			if test.ExpectedFields == nil {
+				return
+			}
+
+			userFieldNames := make([][]string, 0)
+			if strings.HasPrefix(responseRecorder.Header().Get("Content-Type"), "text/csv") {
+				section := strings.SplitN(responseRecorder.Body.String(), "\n\n", 2)[0]
+				records, err := csv.NewReader(strings.NewReader(section)).ReadAll()
+				if err != nil {
+					t.Fatalf("expected no error when parsing response, got %v", err)
+				}
+				for _, eachRecord := range records[1:] {
+					if len(eachRecord) != len(records[0]) {
+						t.Errorf("expected %v values, got %v", len(records[0]), eachRecord)
+					}
+					userFieldNames = append(userFieldNames, records[0])
+				}
+			} else {
+				resultObj := &sparseResultRetrieveUsers{}
+				err := json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+				if err != nil {
+					t.Fatalf("expected no error when parsing response, got %v", err)
+				}
+				if len(resultObj.Errors) != 1 {
+					t.Errorf("expected an error for notfound, got %v", resultObj.Errors)
+				}
+				for _, eachUser := range resultObj.Users {
+					names := make([]string, 0, len(eachUser))
+					for eachName := range eachUser {
+						names = append(names, eachName)
+					}
+					sort.Strings(names)
+					userFieldNames = append(userFieldNames, names)
+				}
+			}
+
+			if len(userFieldNames) != 2 {
+				t.Fatalf("expected 2 users, got %v", len(userFieldNames))
+			}
+			for _, eachNames := range userFieldNames {
+				if strings.Join(eachNames, ",") != strings.Join(test.ExpectedFields, ",") {
+					t.Errorf("expected fields %v, got %v", test.ExpectedFields, eachNames)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// FOLLOW_RELATION_FOLLOWERS users following the user
+	FOLLOW_RELATION_FOLLOWERS = "followers"
+	// FOLLOW_RELATION_FOLLOWING users followed by the user
+	FOLLOW_RELATION_FOLLOWING = "following"
+)
+
+// retrieveUserConnection handling retrieving followers or following of a user by the path /users/{login}/{followers|following}
+func (s *Server) retrieveUserConnection(w http.ResponseWriter, r *http.Request) {
+	client := s.newGithubClient(r.Context())
+
+	resultObj := &model.ResultRetrieveUserConnection{
+		Errors: make([]*model.ResultError, 0),
+	}
+
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/users/"), "/"), "/")
+	if len(pathParts) != 2 || len(pathParts[0]) == 0 || (pathParts[1] != FOLLOW_RELATION_FOLLOWERS && pathParts[1] != FOLLOW_RELATION_FOLLOWING) {
+		resultObj.Errors = append(resultObj.Errors, &model.ResultError{
+			Message: fmt.Sprintf("invalid path %q, expected /users/{login}/followers or /users/{login}/following", r.URL.Path),
+		})
+	} else {
+		first, offset, err := parsePageArgs(r.FormValue("first"), r.FormValue("after"))
+		if err != nil {
+			resultObj.Errors = append(resultObj.Errors, &model.ResultError{
+				Message: err.Error(),
+			})
+		} else {
+			connection, connectionErrors := s.userConnection(client, pathParts[0], pathParts[1], first, offset)
+			resultObj.Connection = connection
+			resultObj.Errors = append(resultObj.Errors, connectionErrors...)
+		}
+	}
+
+	writeJSONResponse(w, r, resultObj)
+}
+
+// userConnection return a page of the followers or following of the user, each user is enriched through the user cache
+func (s *Server) userConnection(client *http.Client, login string, relation string, first int, offset int) (*model.GithubUserConnection, []*model.ResultError) {
+	connectionErrors := make([]*model.ResultError, 0)
+
+	userInfo, err := s.retrieveUserInfo(client, login)
+	if err != nil {
+		return nil, append(connectionErrors, &model.ResultError{
+			Message: fmt.Sprintf("encounter err for username %q: %v", login, err),
+		})
+	}
+	if userInfo.Message == GITHUB_API_MESSAGE_USER_NOT_FOUND {
+		return nil, append(connectionErrors, &model.ResultError{
+			Message: fmt.Sprintf("username %q not found", login),
+		})
+	}
+
+	followList, err := s.retrieveFollowList(client, login, relation)
+	if err != nil {
+		return nil, append(connectionErrors, followListError(login, relation, err))
+	}
+
+	connection := &model.GithubUserConnection{
+		TotalCount: userInfo.Followers,
+		Nodes:      make([]*model.GithubUserInfo, 0),
+		PageInfo:   &model.PageInfo{},
+	}
+	if relation == FOLLOW_RELATION_FOLLOWING {
+		connection.TotalCount = userInfo.Following
+	}
+
+	end := offset + first
+	if end > len(followList.Logins) {
+		end = len(followList.Logins)
+	}
+	// The users of the page are fetched together, so the cache misses go upstream in batches
+	pageLogins := make([]string, 0)
+	if offset < end {
+		pageLogins = followList.Logins[offset:end]
+	}
+	userFetchResults := s.retrieveUserInfos(client, pageLogins)
+	for _, eachLogin := range pageLogins {
+		nodeInfo, err := userFetchResults[eachLogin].userInfo, userFetchResults[eachLogin].err
+		if err != nil {
+			connectionErrors = append(connectionErrors, &model.ResultError{
+				Message: fmt.Sprintf("encounter err for username %q: %v", eachLogin, err),
+			})
+		} else if nodeInfo.Message == GITHUB_API_MESSAGE_USER_NOT_FOUND {
+			connectionErrors = append(connectionErrors, &model.ResultError{
+				Message: fmt.Sprintf("username %q not found", eachLogin),
+			})
+		} else {
+			connection.Nodes = append(connection.Nodes, nodeInfo)
+		}
+	}
+
+	if end > offset {
+		endCursor := encodeCursor(end)
+		connection.PageInfo.EndCursor = &endCursor
+	}
+	connection.PageInfo.HasNextPage = end < len(followList.Logins)
+
+	return connection, connectionErrors
+}
+
+// retrieveFollowList return the logins of the followers or following of the user (from cache or from API calls), bounded by followMaxPages
+func (s *Server) retrieveFollowList(client *http.Client, login string, relation string) (*model.GithubFollowList, error) {
+	cacheKey := relation + ":" + login
+	followList := tracedCacheGet(githubClientContext(client), s.tracer, CACHE_FOLLOW_LIST, s.githubFollowListCache, cacheKey)
+	if followList != nil {
+		return followList, nil
+	}
+
+	followList = &model.GithubFollowList{
+		Login:  login,
+		Logins: make([]string, 0),
+	}
+	nextURL := fmt.Sprintf("%s/%s/%s/%s?per_page=100", s.config.githubAPIURL, s.config.githubAPIUser, url.PathEscape(login), relation)
+	for page := 0; nextURL != "" && page < s.config.followMaxPages; page++ {
+		pageUsers := make([]*model.GithubUserInfo, 0)
+		next, err := s.githubGet(client, nextURL, &pageUsers)
+		if err != nil {
+			return nil, err
+		}
+		for _, eachUser := range pageUsers {
+			followList.Logins = append(followList.Logins, eachUser.Login)
+		}
+		nextURL = next
+	}
+	followList.Truncated = nextURL != ""
+
+	s.githubFollowListCache.Set(cacheKey, followList)
+	return followList, nil
+}
+
+// relationships handling computing mutual follows, common followers and common following between the logins
+func (s *Server) relationships(w http.ResponseWriter, r *http.Request) {
+	client := s.newGithubClient(r.Context())
+
+	logins := make([]string, 0)
+	processedLoginMap := make(map[string]bool)
+	for _, eachLogin := range strings.Split(r.FormValue("logins"), ",") {
+		eachLogin = strings.TrimSpace(eachLogin)
+		if len(eachLogin) == 0 || processedLoginMap[eachLogin] {
+			continue
+		}
+		processedLoginMap[eachLogin] = true
+		logins = append(logins, eachLogin)
+	}
+
+	resultObj := &model.ResultRelationships{
+		Logins:          logins,
+		MutualFollows:   make([]*model.MutualFollow, 0),
+		CommonFollowers: make([]string, 0),
+		CommonFollowing: make([]string, 0),
+		Errors:          make([]*model.ResultError, 0),
+	}
+
+	if len(logins) < 2 {
+		resultObj.Errors = append(resultObj.Errors, &model.ResultError{
+			Message: "at least 2 logins are required",
+		})
+		writeJSONResponse(w, r, resultObj)
+		return
+	}
+
+	// Collect the follow lists of every login
+	followers := make(map[string]map[string]bool)
+	following := make(map[string]map[string]bool)
+	followerLists := make([][]string, 0, len(logins))
+	followingLists := make([][]string, 0, len(logins))
+	for _, eachLogin := range logins {
+		for _, relation := range []string{FOLLOW_RELATION_FOLLOWERS, FOLLOW_RELATION_FOLLOWING} {
+			followList, err := s.retrieveFollowList(client, eachLogin, relation)
+			if err != nil {
+				resultObj.Errors = append(resultObj.Errors, followListError(eachLogin, relation, err))
+				continue
+			}
+			resultObj.Truncated = resultObj.Truncated || followList.Truncated
+
+			loginSet := make(map[string]bool)
+			for _, eachFollowLogin := range followList.Logins {
+				loginSet[eachFollowLogin] = true
+			}
+			if relation == FOLLOW_RELATION_FOLLOWERS {
+				followers[eachLogin] = loginSet
+				followerLists = append(followerLists, followList.Logins)
+			} else {
+				following[eachLogin] = loginSet
+				followingLists = append(followingLists, followList.Logins)
+			}
+		}
+	}
+
+	if len(resultObj.Errors) == 0 {
+		// A follows B when B is in the following list of A or A is in the followers list of B
+		follows := func(a string, b string) bool {
+			return following[a][b] || followers[b][a]
+		}
+		for i := 0; i < len(logins); i++ {
+			for j := i + 1; j < len(logins); j++ {
+				if follows(logins[i], logins[j]) && follows(logins[j], logins[i]) {
+					resultObj.MutualFollows = append(resultObj.MutualFollows, &model.MutualFollow{
+						Login:      logins[i],
+						OtherLogin: logins[j],
+					})
+				}
+			}
+		}
+
+		resultObj.CommonFollowers = intersectLogins(followerLists)
+		resultObj.CommonFollowing = intersectLogins(followingLists)
+	}
+
+	writeJSONResponse(w, r, resultObj)
+}
+
+// intersectLogins return the logins present in every list, sorted alphabetically
+func intersectLogins(lists [][]string) []string {
+	result := make([]string, 0)
+	if len(lists) == 0 {
+		return result
+	}
+
+	counts := make(map[string]int)
+	for _, eachList := range lists {
+		seen := make(map[string]bool)
+		for _, eachLogin := range eachList {
+			if !seen[eachLogin] {
+				seen[eachLogin] = true
+				counts[eachLogin]++
+			}
+		}
+	}
+	for eachLogin, count := range counts {
+		if count == len(lists) {
+			result = append(result, eachLogin)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// followListError convert error when retrieving a follow list into result error
+func followListError(login string, relation string, err error) *model.ResultError {
+	if isGithubNotFound(err) {
+		return &model.ResultError{
+			Message: fmt.Sprintf("username %q not found", login),
+		}
+	}
+	return &model.ResultError{
+		Message: fmt.Sprintf("encounter err when retrieving %s of username %q: %v", relation, login, err),
+	}
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newFollowTestServer return a github API test server where a and b follow each other and c follows both
+func newFollowTestServer(t *testing.T) *httptest.Server {
+	followLists := map[string][]string{
+		"/users/a/followers": {"b", "c", "d"},
+		"/users/a/following": {"b"},
+		"/users/b/followers": {"a", "c"},
+		"/users/b/following": {"a"},
+		"/users/c/followers": {},
+		"/users/c/following": {"a", "b"},
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var responseData interface{}
+		if followList, found := followLists[r.URL.Path]; found {
+			users := make([]model.GithubUserInfo, 0)
+			for _, eachLogin := range followList {
+				users = append(users, model.GithubUserInfo{Login: eachLogin})
+			}
+			responseData = users
+		} else if strings.Count(r.URL.Path, "/") == 2 && r.URL.Path != "/users/notfound" {
+			username := strings.TrimPrefix(r.URL.Path, "/users/")
+			responseData = model.GithubUserInfo{Login: username, Name: username, Followers: 3, Following: 1}
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			responseData = struct {
+				Message string `json:"message"`
+			}{
+				Message: "Not Found",
+			}
+		}
+
+		jsonString, _ := json.Marshal(responseData)
+		w.Write([]byte(jsonString))
+	}))
+}
+
+func TestRetrieveUserConnection(t *testing.T) {
+	tests := map[string]struct {
+		Path               string
+		First              string
+		After              string
+		ExpectedLogins     []string
+		ExpectedTotalCount int
+		ExpectedNextPage   bool
+		ExpectedError      bool
+	}{
+		"Test first page of followers": {
+			Path:               "/users/a/followers",
+			First:              "2",
+			ExpectedLogins:     []string{"b", "c"},
+			ExpectedTotalCount: 3,
+			ExpectedNextPage:   true,
+		},
+		"Test second page of followers": {
+			Path:               "/users/a/followers",
+			First:              "2",
+			After:              encodeCursor(2),
+			ExpectedLogins:     []string{"d"},
+			ExpectedTotalCount: 3,
+			ExpectedNextPage:   false,
+		},
+		"Test following": {
+			Path:               "/users/c/following",
+			ExpectedLogins:     []string{"a", "b"},
+			ExpectedTotalCount: 1,
+			ExpectedNextPage:   false,
+		},
+		"Test cursor past the last page": {
+			Path:               "/users/a/followers",
+			First:              "2",
+			After:              encodeCursor(10),
+			ExpectedLogins:     []string{},
+			ExpectedTotalCount: 3,
+			ExpectedNextPage:   false,
+		},
+		"Test user not found": {
+			Path:          "/users/notfound/followers",
+			ExpectedError: true,
+		},
+		"Test invalid relation": {
+			Path:          "/users/a/stars",
+			ExpectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newFollowTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			query := url.Values{}
+			if test.First != "" {
+				query.Set("first", test.First)
+			}
+			if test.After != "" {
+				query.Set("after", test.After)
+			}
+			request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s?%s", test.Path, query.Encode()), nil)
+			s.retrieveUserConnection(responseRecorder, request)
+
+			response := responseRecorder.Result()
+			defer response.Body.Close()
+			responseData, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("expected no error when read data from response body, got err = %v", err)
+			}
+
+			jsonResponseData := &model.ResultRetrieveUserConnection{}
+			err = json.Unmarshal([]byte(responseData), &jsonResponseData)
+			if err != nil {
+				t.Fatalf("expected no error when unmarshal response data, got err = %v", err)
+			}
+
+			if test.ExpectedError {
+				if len(jsonResponseData.Errors) == 0 || jsonResponseData.Connection != nil {
+					t.Errorf("expected an error and no connection, got %v", jsonResponseData)
+				}
+				return
+			}
+
+			if len(jsonResponseData.Errors) > 0 {
+				t.Fatalf("expected no error from response, got errors = %v", jsonResponseData.Errors)
+			}
+			connection := jsonResponseData.Connection
+			logins := make([]string, 0)
+			for _, eachNode := range connection.Nodes {
+				logins = append(logins, eachNode.Login)
+				if eachNode.Name != eachNode.Login {
+					t.Errorf("expected node %v to be enriched with the profile, got %v", eachNode.Login, eachNode)
+				}
+			}
+			if !reflect.DeepEqual(logins, test.ExpectedLogins) {
+				t.Errorf("expected logins %v, got %v", test.ExpectedLogins, logins)
+			}
+			if connection.TotalCount != test.ExpectedTotalCount {
+				t.Errorf("expected total count %v, got %v", test.ExpectedTotalCount, connection.TotalCount)
+			}
+			if connection.PageInfo.HasNextPage != test.ExpectedNextPage {
+				t.Errorf("expected has next page %v, got %v", test.ExpectedNextPage, connection.PageInfo.HasNextPage)
+			}
+		})
+	}
+}
+
+func TestRetrieveUserConnectionInBatch(t *testing.T) {
+	var graphqlCalls int32
+	githubGraphQLTestServer := newGraphQLTestServer(t, &graphqlCalls)
+	defer githubGraphQLTestServer.Close()
+	githubAPITestServer := newFollowTestServer(t)
+	defer githubAPITestServer.Close()
+
+	config := NewServerConfig("", 8777, githubAPITestServer.URL, "users").
+		WithGithubToken("token").
+		WithGithubUpstream(GITHUB_UPSTREAM_GRAPHQL).
+		WithGithubGraphQLURL(githubGraphQLTestServer.URL + "/graphql")
+	s := NewServer(config)
+	responseRecorder := httptest.NewRecorder()
+	s.retrieveUserConnection(responseRecorder, httptest.NewRequest(http.MethodGet, "/users/a/followers", nil))
+
+	jsonResponseData := &model.ResultRetrieveUserConnection{}
+	json.Unmarshal(responseRecorder.Body.Bytes(), jsonResponseData)
+	if jsonResponseData.Connection == nil || len(jsonResponseData.Connection.Nodes) != 3 {
+		t.Fatalf("expected 3 followers, got %s", responseRecorder.Body.String())
+	}
+	// One call for the user, one for the page of followers
+	if graphqlCalls != 2 {
+		t.Errorf("expected the followers fetched in one GraphQL call, got %v calls", graphqlCalls)
+	}
+}
+
+func TestRelationships(t *testing.T) {
+	tests := map[string]struct {
+		Logins                  string
+		ExpectedMutualFollows   []model.MutualFollow
+		ExpectedCommonFollowers []string
+		ExpectedCommonFollowing []string
+		ExpectedError           bool
+	}{
+		"Test two logins following each other": {
+			Logins:                  "a,b",
+			ExpectedMutualFollows:   []model.MutualFollow{{Login: "a", OtherLogin: "b"}},
+			ExpectedCommonFollowers: []string{"c"},
+			ExpectedCommonFollowing: []string{},
+		},
+		"Test three logins": {
+			Logins:                  "a,b,c",
+			ExpectedMutualFollows:   []model.MutualFollow{{Login: "a", OtherLogin: "b"}},
+			ExpectedCommonFollowers: []string{},
+			ExpectedCommonFollowing: []string{},
+		},
+		"Test not enough logins": {
+			Logins:        "a,,a",
+			ExpectedError: true,
+		},
+		"Test login not found": {
+			Logins:        "a,notfound",
+			ExpectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newFollowTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/relationships?logins=%v", test.Logins), nil)
+			s.relationships(responseRecorder, request)
+
+			response := responseRecorder.Result()
+			defer response.Body.Close()
+			responseData, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("expected no error when read data from response body, got err = %v", err)
+			}
+
+			jsonResponseData := &model.ResultRelationships{}
+			err = json.Unmarshal([]byte(responseData), &jsonResponseData)
+			if err != nil {
+				t.Fatalf("expected no error when unmarshal response data, got err = %v", err)
+			}
+
+			if test.ExpectedError {
+				if len(jsonResponseData.Errors) == 0 {
+					t.Errorf("expected an error from response, got no errors")
+				}
+				return
+			}
+
+			if len(jsonResponseData.Errors) > 0 {
+				t.Fatalf("expected no error from response, got errors = %v", jsonResponseData.Errors)
+			}
+			mutualFollows := make([]model.MutualFollow, 0)
+			for _, eachMutualFollow := range jsonResponseData.MutualFollows {
+				mutualFollows = append(mutualFollows, *eachMutualFollow)
+			}
+			if !reflect.DeepEqual(mutualFollows, test.ExpectedMutualFollows) {
+				t.Errorf("expected mutual follows %v, got %v", test.ExpectedMutualFollows, mutualFollows)
+			}
+			if !reflect.DeepEqual(jsonResponseData.CommonFollowers, test.ExpectedCommonFollowers) {
+				t.Errorf("expected common followers %v, got %v", test.ExpectedCommonFollowers, jsonResponseData.CommonFollowers)
+			}
+			if !reflect.DeepEqual(jsonResponseData.CommonFollowing, test.ExpectedCommonFollowing) {
+				t.Errorf("expected common following %v, got %v", test.ExpectedCommonFollowing, jsonResponseData.CommonFollowing)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// githubAPIError error returned when github API respond with an unexpected status code
+type githubAPIError struct {
+	StatusCode int
+	URL        string
+}
+
+// Error comply with error interface
+func (e *githubAPIError) Error() string {
+	return fmt.Sprintf("github API responded with status %d for %s", e.StatusCode, e.URL)
+}
+
+// isGithubNotFound check whether the error is caused by github API responding with 404 Not Found
+func isGithubNotFound(err error) bool {
+	var apiErr *githubAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// newGithubRequest return a GET request to github API with the headers recommended by github (and the token if configured)
+func (s *Server) newGithubRequest(apiURL string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/vnd.github+json")
+	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
+	if s.config.githubToken != "" {
+		req.Header.Add("Authorization", "Bearer "+s.config.githubToken)
+	}
+	return req, nil
+}
+
+// doGithubRequest send the request to github API unless its rate limit bucket is exhausted, the rate limit headers of the response are recorded
+func (s *Server) doGithubRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	if req.Context() == context.Background() {
+		// Trace the call as a child of the request the client is created for
+		req = req.WithContext(githubClientContext(client))
+	}
+	resource := s.rateLimitResource(req.URL)
+	err := s.githubRateLimiter.check(resource)
+	if err != nil {
+		s.observeGithubRequest(resource, "rate_limited", 0)
+		trace.SpanFromContext(req.Context()).AddEvent("github rate limit exhausted", trace.WithAttributes(attribute.String("github.resource", resource)))
+		return nil, err
+	}
+
+	requestLogFromContext(req.Context()).countUpstreamCall()
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		s.observeGithubRequest(resource, "error", time.Since(start))
+		s.upstreamHealth.record(err)
+		return nil, err
+	}
+	s.observeGithubRequest(resource, strconv.Itoa(resp.StatusCode), time.Since(start))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		s.upstreamHealth.record(&githubAPIError{StatusCode: resp.StatusCode, URL: req.URL.String()})
+	} else {
+		s.upstreamHealth.record(nil)
+	}
+	s.githubRateLimiter.update(resp.Header)
+
+	// Github respond with 403 or 429 when the bucket is exhausted
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		err = s.githubRateLimiter.check(resource)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// githubGet call github API and decode the JSON response into target, return the URL of the next page (if any)
+func (s *Server) githubGet(client *http.Client, apiURL string, target interface{}) (string, error) {
+	req, err := s.newGithubRequest(apiURL)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.doGithubRequest(client, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &githubAPIError{
+			StatusCode: resp.StatusCode,
+			URL:        apiURL,
+		}
+	}
+
+	err = json.Unmarshal(responseData, target)
+	if err != nil {
+		return "", err
+	}
+
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// nextPageURL parse the Link header returned by github API and return the URL with rel="next" (empty if there is none)
+func nextPageURL(linkHeader string) string {
+	// Link header has the format: <https://...?page=2>; rel="next", <https://...?page=5>; rel="last"
+	for _, eachLink := range strings.Split(linkHeader, ",") {
+		parts := strings.Split(eachLink, ";")
+		if len(parts) < 2 {
+			continue
+		}
+
+		linkURL := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+		for _, eachParam := range parts[1:] {
+			if strings.TrimSpace(eachParam) == `rel="next"` {
+				return linkURL
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package server
+
+import "testing"
+
+func TestNextPageURL(t *testing.T) {
+	tests := map[string]struct {
+		LinkHeader string
+		Expected   string
+	}{
+		"Empty link header": {
+			LinkHeader: "",
+			Expected:   "",
+		},
+		"Link header with next and last": {
+			LinkHeader: `<https://api.github.com/user/1/repos?page=2>; rel="next", <https://api.github.com/user/1/repos?page=5>; rel="last"`,
+			Expected:   "https://api.github.com/user/1/repos?page=2",
+		},
+		"Link header on the last page": {
+			LinkHeader: `<https://api.github.com/user/1/repos?page=1>; rel="first", <https://api.github.com/user/1/repos?page=4>; rel="prev"`,
+			Expected:   "",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result := nextPageURL(test.LinkHeader)
+			if result != test.Expected {
+				t.Errorf("expected %q, got %q", test.Expected, result)
+			}
+		})
+	}
+}
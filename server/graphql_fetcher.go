@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"strings"
+)
+
+const (
+	// GITHUB_GRAPHQL_ERROR_NOT_FOUND type of the GraphQL error returned when the user does not exist
+	GITHUB_GRAPHQL_ERROR_NOT_FOUND = "NOT_FOUND"
+)
+
+// githubGraphQLOwnerFields fields selected for each user or organization, mapped into model.GithubUserInfo like the REST API which serve both from /users/
+const githubGraphQLOwnerFields = `fragment ownerFields on RepositoryOwner {
+  __typename
+  login
+  repositories(privacy: PUBLIC, ownerAffiliations: OWNER) { totalCount }
+  ... on User {
+    name
+    company
+    followers { totalCount }
+    following { totalCount }
+  }
+  ... on Organization {
+    name
+  }
+}`
+
+// githubGraphQLUser user or organization as returned by the GraphQL v4 API, organizations have no company, followers nor following
+type githubGraphQLUser struct {
+	TypeName  string  `json:"__typename"`
+	Login     string  `json:"login"`
+	Name      *string `json:"name"`
+	Company   *string `json:"company"`
+	Followers struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"followers"`
+	Following struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"following"`
+	Repositories struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"repositories"`
+}
+
+// githubGraphQLError error as returned by the GraphQL v4 API
+type githubGraphQLError struct {
+	Type    string        `json:"type"`
+	Path    []interface{} `json:"path"`
+	Message string        `json:"message"`
+}
+
+// githubGraphQLResponse response of the GraphQL v4 API
+type githubGraphQLResponse struct {
+	Data   map[string]*githubGraphQLUser `json:"data"`
+	Errors []*githubGraphQLError         `json:"errors"`
+}
+
+// graphqlUserFetcher fetch users in batches with the GraphQL v4 API, one aliased repositoryOwner(login:) selection per user so organizations are found too
+type graphqlUserFetcher struct {
+	server *Server
+}
+
+// fetchUsers comply with githubUserFetcher interface
+func (f *graphqlUserFetcher) fetchUsers(client *http.Client, usernames []string) map[string]*userFetchResult {
+	userFetchResults := make(map[string]*userFetchResult)
+	for start := 0; start < len(usernames); start += f.batchSize() {
+		end := start + f.batchSize()
+		if end > len(usernames) {
+			end = len(usernames)
+		}
+		for eachUsername, eachResult := range f.fetchBatch(client, usernames[start:end]) {
+			userFetchResults[eachUsername] = eachResult
+		}
+	}
+	return userFetchResults
+}
+
+// batchSize comply with githubUserFetcher interface
+func (f *graphqlUserFetcher) batchSize() int {
+	return f.server.config.githubGraphQLBatchSize
+}
+
+// fetchBatch fetch the users with a single GraphQL request
+func (f *graphqlUserFetcher) fetchBatch(client *http.Client, usernames []string) map[string]*userFetchResult {
+	userFetchResults := make(map[string]*userFetchResult)
+	failAll := func(err error) map[string]*userFetchResult {
+		for _, eachUsername := range usernames {
+			userFetchResults[eachUsername] = &userFetchResult{
+				err: err,
+			}
+		}
+		return userFetchResults
+	}
+
+	// Build the query, logins are passed as variables: query($l0: String!) { u0: repositoryOwner(login: $l0) { ...ownerFields } }
+	variableDefinitions := make([]string, 0, len(usernames))
+	selections := make([]string, 0, len(usernames))
+	variables := make(map[string]interface{})
+	for i, eachUsername := range usernames {
+		variableDefinitions = append(variableDefinitions, fmt.Sprintf("$l%d: String!", i))
+		selections = append(selections, fmt.Sprintf("  u%d: repositoryOwner(login: $l%d) { ...ownerFields }", i, i))
+		variables[fmt.Sprintf("l%d", i)] = eachUsername
+	}
+	query := fmt.Sprintf("query(%s) {\n%s\n}\n%s", strings.Join(variableDefinitions, ", "), strings.Join(selections, "\n"), githubGraphQLOwnerFields)
+
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return failAll(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, f.server.config.githubGraphQLURL, bytes.NewReader(requestBody))
+	if err != nil {
+		return failAll(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+f.server.config.githubToken)
+
+	resp, err := f.server.doGithubRequest(client, req)
+	if err != nil {
+		return failAll(err)
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return failAll(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return failAll(&githubAPIError{
+			StatusCode: resp.StatusCode,
+			URL:        f.server.config.githubGraphQLURL,
+		})
+	}
+
+	graphqlResponse := &githubGraphQLResponse{}
+	err = json.Unmarshal(responseData, graphqlResponse)
+	if err != nil {
+		return failAll(err)
+	}
+
+	// Index the errors by the alias they belong to
+	aliasErrors := make(map[string]*githubGraphQLError)
+	for _, eachError := range graphqlResponse.Errors {
+		if len(eachError.Path) > 0 {
+			if alias, ok := eachError.Path[0].(string); ok {
+				aliasErrors[alias] = eachError
+			}
+		}
+	}
+
+	for i, eachUsername := range usernames {
+		alias := fmt.Sprintf("u%d", i)
+		user, selected := graphqlResponse.Data[alias]
+		aliasError, found := aliasErrors[alias]
+		if user != nil {
+			userFetchResults[eachUsername] = &userFetchResult{
+				userInfo: user.toGithubUserInfo(),
+			}
+		} else if (found && aliasError.Type == GITHUB_GRAPHQL_ERROR_NOT_FOUND) || (selected && !found) {
+			// repositoryOwner is null without error when no user nor organization has the login,
+			// same shape as the REST API so not found users are handled (and cached) the same way
+			userFetchResults[eachUsername] = &userFetchResult{
+				userInfo: &model.GithubUserInfo{
+					Message: GITHUB_API_MESSAGE_USER_NOT_FOUND,
+				},
+			}
+		} else if found {
+			userFetchResults[eachUsername] = &userFetchResult{
+				err: errors.New(aliasError.Message),
+			}
+		} else if len(graphqlResponse.Errors) > 0 {
+			userFetchResults[eachUsername] = &userFetchResult{
+				err: errors.New(graphqlResponse.Errors[0].Message),
+			}
+		} else {
+			userFetchResults[eachUsername] = &userFetchResult{
+				err: errors.New("github GraphQL API returned no data"),
+			}
+		}
+	}
+	return userFetchResults
+}
+
+// toGithubUserInfo map the GraphQL user or organization into model.GithubUserInfo
+func (u *githubGraphQLUser) toGithubUserInfo() *model.GithubUserInfo {
+	userInfo := &model.GithubUserInfo{
+		Login:       u.Login,
+		Followers:   u.Followers.TotalCount,
+		Following:   u.Following.TotalCount,
+		PublicRepos: u.Repositories.TotalCount,
+	}
+	if u.Name != nil {
+		userInfo.Name = *u.Name
+	}
+	if u.Company != nil {
+		userInfo.Company = *u.Company
+	}
+	return userInfo
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newGraphQLTestServer return a github GraphQL API test server, logins containing "notfound" do not exist, logins starting with "org" are organizations
+// and logins containing "gone" fail with the NOT_FOUND error of a user(login:) selection
+func newGraphQLTestServer(t *testing.T, requestCount *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requestCount, 1)
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "bearer token" {
+			t.Errorf("expected authenticated POST request, got %v with Authorization %q", r.Method, r.Header.Get("Authorization"))
+		}
+
+		requestBody := struct {
+			Query     string            `json:"query"`
+			Variables map[string]string `json:"variables"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&requestBody)
+
+		data := make(map[string]interface{})
+		errors := make([]interface{}, 0)
+		for i := 0; i < len(requestBody.Variables); i++ {
+			alias := fmt.Sprintf("u%d", i)
+			login := requestBody.Variables[fmt.Sprintf("l%d", i)]
+			if !strings.Contains(requestBody.Query, alias+": repositoryOwner(login: $l") {
+				t.Errorf("expected aliased selection for %v, got query %v", alias, requestBody.Query)
+			}
+			if strings.Contains(login, "notfound") {
+				data[alias] = nil
+			} else if strings.Contains(login, "gone") {
+				data[alias] = nil
+				errors = append(errors, map[string]interface{}{
+					"type":    "NOT_FOUND",
+					"path":    []string{alias},
+					"message": fmt.Sprintf("Could not resolve to a User with the login of '%s'.", login),
+				})
+			} else if strings.HasPrefix(login, "org") {
+				data[alias] = map[string]interface{}{
+					"__typename":   "Organization",
+					"login":        login,
+					"name":         login,
+					"repositories": map[string]int{"totalCount": 8},
+				}
+			} else {
+				data[alias] = map[string]interface{}{
+					"__typename":   "User",
+					"login":        login,
+					"name":         login,
+					"company":      nil,
+					"followers":    map[string]int{"totalCount": 10},
+					"following":    map[string]int{"totalCount": 1},
+					"repositories": map[string]int{"totalCount": 4},
+				}
+			}
+		}
+
+		jsonString, _ := json.Marshal(map[string]interface{}{
+			"data":   data,
+			"errors": errors,
+		})
+		w.Write([]byte(jsonString))
+	}))
+}
+
+func TestRetrieveUsersWithGraphQLUpstream(t *testing.T) {
+	tests := map[string]struct {
+		Usernames             string
+		GithubToken           string
+		ExpectedUsernames     string
+		ExpectedErrorsCount   int
+		ExpectedGraphQLCalls  int32
+		ExpectedRESTCallsMore bool
+	}{
+		"Test users fetched in batches": {
+			Usernames:            "a,b,c,notfound",
+			GithubToken:          "token",
+			ExpectedUsernames:    "a,b,c",
+			ExpectedErrorsCount:  1,
+			ExpectedGraphQLCalls: 2,
+		},
+		"Test organizations fetched like users": {
+			Usernames:            "a,orgb,gone,notfound",
+			GithubToken:          "token",
+			ExpectedUsernames:    "a,orgb",
+			ExpectedErrorsCount:  2,
+			ExpectedGraphQLCalls: 2,
+		},
+		"Test fall back to REST without token": {
+			Usernames:             "a,b",
+			GithubToken:           "",
+			ExpectedUsernames:     "a,b",
+			ExpectedErrorsCount:   0,
+			ExpectedGraphQLCalls:  0,
+			ExpectedRESTCallsMore: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var graphqlCalls int32
+			githubGraphQLTestServer := newGraphQLTestServer(t, &graphqlCalls)
+			defer githubGraphQLTestServer.Close()
+
+			var restCalls int32
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&restCalls, 1)
+				username := strings.TrimPrefix(r.URL.Path, "/users/")
+				jsonString, _ := json.Marshal(model.GithubUserInfo{Login: username, Name: username})
+				w.Write([]byte(jsonString))
+			}))
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users").
+				WithGithubToken(test.GithubToken).
+				WithGithubUpstream(GITHUB_UPSTREAM_GRAPHQL).
+				WithGithubGraphQLURL(githubGraphQLTestServer.URL + "/graphql")
+			config.githubGraphQLBatchSize = 2
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			target := fmt.Sprintf("/retrieveUsers?usernames=%v", test.Usernames)
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+			s.retrieveUsers(responseRecorder, request)
+
+			response := responseRecorder.Result()
+			defer response.Body.Close()
+			responseData, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("expected no error when read data from response body, got err = %v", err)
+			}
+
+			jsonResponseData := &model.ResultRetrieveUsers{}
+			err = json.Unmarshal([]byte(responseData), &jsonResponseData)
+			if err != nil {
+				t.Fatalf("expected no error when unmarshal response data, got err = %v", err)
+			}
+
+			if len(jsonResponseData.Errors) != test.ExpectedErrorsCount {
+				t.Errorf("expected %v error(s), got %v", test.ExpectedErrorsCount, jsonResponseData.Errors)
+			}
+			expectedUsernames := strings.Split(test.ExpectedUsernames, ",")
+			if len(jsonResponseData.Users) != len(expectedUsernames) {
+				t.Fatalf("expected %v user record(s), got = %v", len(expectedUsernames), len(jsonResponseData.Users))
+			}
+			for i, eachExpectedUsername := range expectedUsernames {
+				if jsonResponseData.Users[i].Login != eachExpectedUsername {
+					t.Errorf("expected record for username %v at index %d, got %v", eachExpectedUsername, i, jsonResponseData.Users[i].Login)
+				}
+			}
+			if graphqlCalls != test.ExpectedGraphQLCalls {
+				t.Errorf("expected %v GraphQL call(s), got %v", test.ExpectedGraphQLCalls, graphqlCalls)
+			}
+			if test.ExpectedRESTCallsMore != (restCalls > 0) {
+				t.Errorf("expected REST calls = %v, got %v call(s)", test.ExpectedRESTCallsMore, restCalls)
+			}
+
+			// Users fetched through GraphQL are mapped into model.GithubUserInfo
+			if test.GithubToken != "" {
+				userInfo := s.githubUserInfoCache.Get("a")
+				if userInfo == nil || userInfo.Followers != 10 || userInfo.PublicRepos != 4 || userInfo.AvgFollowersPerPublicRepo != 2.5 {
+					t.Errorf("expected cached user a with followers = 10, public_repos = 4 and avg = 2.5, got %v", userInfo)
+				}
+			}
+			if strings.Contains(test.Usernames, "orgb") {
+				orgInfo := s.githubUserInfoCache.Get("orgb")
+				if orgInfo == nil || orgInfo.Login != "orgb" || orgInfo.Name != "orgb" || orgInfo.PublicRepos != 8 || orgInfo.Followers != 0 {
+					t.Errorf("expected cached organization orgb with public_repos = 8, got %v", orgInfo)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// HEALTH_STATUS_OK the check passes
+	HEALTH_STATUS_OK = "ok"
+	// HEALTH_STATUS_FAIL the check fails, the server is not ready
+	HEALTH_STATUS_FAIL = "fail"
+
+	// GITHUB_CIRCUIT_FAILURE_THRESHOLD consecutive failed github API calls opening the circuit, github is then probed by the readiness check
+	GITHUB_CIRCUIT_FAILURE_THRESHOLD = 5
+	// GITHUB_PROBE_TIMEOUT timeout of the github probe of the readiness check
+	GITHUB_PROBE_TIMEOUT = 2 * time.Second
+)
+
+// upstreamHealth track the outcome of the github API calls, the circuit is open after GITHUB_CIRCUIT_FAILURE_THRESHOLD consecutive failures
+// (no response or 5xx) and closed again by the next successful call
+type upstreamHealth struct {
+	lock                *sync.RWMutex
+	consecutiveFailures int
+	lastSuccess         time.Time
+	lastFailure         time.Time
+	lastError           string
+}
+
+// newUpstreamHealth return new upstream health with a closed circuit
+func newUpstreamHealth() *upstreamHealth {
+	return &upstreamHealth{
+		lock: &sync.RWMutex{},
+	}
+}
+
+// record record the outcome of a github API call, err is the transport error or the server error status
+func (h *upstreamHealth) record(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err == nil {
+		h.consecutiveFailures = 0
+		h.lastSuccess = time.Now()
+		return
+	}
+	h.consecutiveFailures++
+	h.lastFailure = time.Now()
+	h.lastError = err.Error()
+}
+
+// circuitOpen check whether the last calls failed consecutively
+func (h *upstreamHealth) circuitOpen() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.consecutiveFailures >= GITHUB_CIRCUIT_FAILURE_THRESHOLD
+}
+
+// githubStatus github section of the status
+type githubStatus struct {
+	Upstream            string                      `json:"upstream"`
+	Circuit             string                      `json:"circuit"`
+	ConsecutiveFailures int                         `json:"consecutive_failures"`
+	LastSuccess         *time.Time                  `json:"last_success,omitempty"`
+	LastFailure         *time.Time                  `json:"last_failure,omitempty"`
+	LastError           string                      `json:"last_error,omitempty"`
+	RateLimits          map[string]*rateLimitStatus `json:"rate_limits"`
+}
+
+// status return the github section of the status
+func (h *upstreamHealth) status() *githubStatus {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	status := &githubStatus{
+		Circuit:             "closed",
+		ConsecutiveFailures: h.consecutiveFailures,
+		LastError:           h.lastError,
+		RateLimits:          make(map[string]*rateLimitStatus),
+	}
+	if h.consecutiveFailures >= GITHUB_CIRCUIT_FAILURE_THRESHOLD {
+		status.Circuit = "open"
+	}
+	if !h.lastSuccess.IsZero() {
+		lastSuccess := h.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+	if !h.lastFailure.IsZero() {
+		lastFailure := h.lastFailure
+		status.LastFailure = &lastFailure
+	}
+	return status
+}
+
+// rateLimitStatus remaining quota of a github rate limit bucket
+type rateLimitStatus struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// healthCheck outcome of a readiness check
+type healthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// healthResult body of /healthz and /readyz
+type healthResult struct {
+	Status string                  `json:"status"`
+	Checks map[string]*healthCheck `json:"checks,omitempty"`
+}
+
+// cacheStatus size and lookups of a cache
+type cacheStatus struct {
+	Entries *int   `json:"entries,omitempty"` // omitted when the backend does not count its entries
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// serverStatus body of /status
+type serverStatus struct {
+	Version       string                  `json:"version"`
+	GoVersion     string                  `json:"go_version"`
+	StartedAt     time.Time               `json:"started_at"`
+	UptimeSeconds int64                   `json:"uptime_seconds"`
+	Ready         bool                    `json:"ready"`
+	Caches        map[string]*cacheStatus `json:"caches"`
+	Github        *githubStatus           `json:"github"`
+	Cluster       *clusterStatus          `json:"cluster,omitempty"` // members of the cluster, when the server is clustered
+}
+
+// healthz handling GET /healthz, the process is alive as long as it answers
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSONResponse(w, r, &healthResult{Status: HEALTH_STATUS_OK})
+}
+
+// readyz handling GET /readyz, respond 200 when every check passes and 503 otherwise
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	result := s.readiness(r.Context())
+	statusCode := http.StatusOK
+	if result.Status != HEALTH_STATUS_OK {
+		statusCode = http.StatusServiceUnavailable
+	}
+	writeJSONResponseWithStatus(w, r, statusCode, result)
+}
+
+// readiness run the readiness checks: the listener accepts connections, github is reachable or its circuit is closed, the rate limit buckets in use are not exhausted
+func (s *Server) readiness(ctx context.Context) *healthResult {
+	result := &healthResult{
+		Status: HEALTH_STATUS_OK,
+		Checks: map[string]*healthCheck{
+			"listener":   s.checkListener(),
+			"github":     s.checkGithub(ctx),
+			"rate_limit": s.checkRateLimit(),
+		},
+	}
+	for _, eachCheck := range result.Checks {
+		if eachCheck.Status != HEALTH_STATUS_OK {
+			result.Status = HEALTH_STATUS_FAIL
+		}
+	}
+	return result
+}
+
+// checkListener check whether the server is listening
+func (s *Server) checkListener() *healthCheck {
+	if !s.listening.Load() {
+		return &healthCheck{Status: HEALTH_STATUS_FAIL, Detail: "server is not listening"}
+	}
+	return &healthCheck{Status: HEALTH_STATUS_OK}
+}
+
+// checkGithub check the github circuit, when it is open github is probed with the rate limit API (not counted in the quota) and a response closes the circuit
+func (s *Server) checkGithub(ctx context.Context) *healthCheck {
+	if !s.upstreamHealth.circuitOpen() {
+		return &healthCheck{Status: HEALTH_STATUS_OK, Detail: "circuit closed"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, GITHUB_PROBE_TIMEOUT)
+	defer cancel()
+	req, err := s.newGithubRequest(s.config.githubAPIURL + "/rate_limit")
+	if err != nil {
+		return &healthCheck{Status: HEALTH_STATUS_FAIL, Detail: err.Error()}
+	}
+	resp, err := s.newGithubClient(ctx).Do(req.WithContext(ctx))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			err = &githubAPIError{StatusCode: resp.StatusCode, URL: req.URL.String()}
+		}
+	}
+	s.upstreamHealth.record(err)
+	if err != nil {
+		return &healthCheck{Status: HEALTH_STATUS_FAIL, Detail: "circuit open, github is not reachable: " + err.Error()}
+	}
+	return &healthCheck{Status: HEALTH_STATUS_OK, Detail: "github is reachable"}
+}
+
+// checkRateLimit check whether the rate limit buckets used to fetch users are exhausted
+func (s *Server) checkRateLimit() *healthCheck {
+	resources := []string{RATE_LIMIT_RESOURCE_CORE}
+	if _, ok := s.githubUserFetcher.(*graphqlUserFetcher); ok {
+		resources = append(resources, RATE_LIMIT_RESOURCE_GRAPHQL)
+	}
+	for _, eachResource := range resources {
+		if err := s.githubRateLimiter.check(eachResource); err != nil {
+			return &healthCheck{Status: HEALTH_STATUS_FAIL, Detail: err.Error()}
+		}
+	}
+	return &healthCheck{Status: HEALTH_STATUS_OK}
+}
+
+// status handling GET /status, summary of the version, uptime, caches and github quota
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	status := &serverStatus{
+		Version:       s.config.version,
+		GoVersion:     runtime.Version(),
+		StartedAt:     s.startedAt.UTC(),
+		UptimeSeconds: int64(time.Since(s.startedAt).Seconds()),
+		Ready:         s.checkListener().Status == HEALTH_STATUS_OK && !s.upstreamHealth.circuitOpen() && s.checkRateLimit().Status == HEALTH_STATUS_OK,
+		Caches:        make(map[string]*cacheStatus),
+		Github:        s.upstreamHealth.status(),
+	}
+	for _, eachCache := range s.cacheMetrics() {
+		cacheStatus := &cacheStatus{}
+		for _, eachPartition := range eachCache.Stats() {
+			if eachPartition.Sized {
+				entries := eachPartition.Size
+				if cacheStatus.Entries != nil {
+					entries += *cacheStatus.Entries
+				}
+				cacheStatus.Entries = &entries
+			}
+			cacheStatus.Hits += eachPartition.Hits
+			cacheStatus.Misses += eachPartition.Misses
+		}
+		status.Caches[eachCache.Name] = cacheStatus
+	}
+	if s.cluster != nil {
+		status.Cluster = s.cluster.status()
+	}
+	status.Github.Upstream = GITHUB_UPSTREAM_REST
+	if _, ok := s.githubUserFetcher.(*graphqlUserFetcher); ok {
+		status.Github.Upstream = GITHUB_UPSTREAM_GRAPHQL
+	}
+	for _, eachResource := range []string{RATE_LIMIT_RESOURCE_CORE, RATE_LIMIT_RESOURCE_SEARCH, RATE_LIMIT_RESOURCE_GRAPHQL} {
+		if bucket, found := s.githubRateLimiter.bucket(eachResource); found {
+			status.Github.RateLimits[eachResource] = &rateLimitStatus{
+				Limit:     bucket.Limit,
+				Remaining: bucket.Remaining,
+				Reset:     bucket.Reset.UTC(),
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSONResponse(w, r, status)
+}
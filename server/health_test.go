@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	s := NewServer(NewServerConfig("", 8777, "http://localhost", "users"))
+	s.registerHandlers()
+	responseRecorder := httptest.NewRecorder()
+	s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	result := &healthResult{}
+	json.Unmarshal(responseRecorder.Body.Bytes(), result)
+	if result.Status != HEALTH_STATUS_OK {
+		t.Errorf("expected status %v, got %v", HEALTH_STATUS_OK, result.Status)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := map[string]struct {
+		Listening          bool
+		UpstreamFailures   int
+		GithubStatusCode   int
+		RateLimitExhausted bool
+		ExpectedStatusCode int
+		ExpectedFailed     string
+		ExpectedCircuit    string
+	}{
+		"Test ready": {
+			Listening:          true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCircuit:    "closed",
+		},
+		"Test not listening": {
+			ExpectedStatusCode: http.StatusServiceUnavailable,
+			ExpectedFailed:     "listener",
+			ExpectedCircuit:    "closed",
+		},
+		"Test failures below the threshold": {
+			Listening:          true,
+			UpstreamFailures:   GITHUB_CIRCUIT_FAILURE_THRESHOLD - 1,
+			GithubStatusCode:   http.StatusBadGateway,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCircuit:    "closed",
+		},
+		"Test circuit open and github reachable": {
+			Listening:          true,
+			UpstreamFailures:   GITHUB_CIRCUIT_FAILURE_THRESHOLD,
+			GithubStatusCode:   http.StatusOK,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCircuit:    "closed",
+		},
+		"Test circuit open and github failing": {
+			Listening:          true,
+			UpstreamFailures:   GITHUB_CIRCUIT_FAILURE_THRESHOLD,
+			GithubStatusCode:   http.StatusBadGateway,
+			ExpectedStatusCode: http.StatusServiceUnavailable,
+			ExpectedFailed:     "github",
+			ExpectedCircuit:    "open",
+		},
+		"Test rate limit exhausted": {
+			Listening:          true,
+			RateLimitExhausted: true,
+			ExpectedStatusCode: http.StatusServiceUnavailable,
+			ExpectedFailed:     "rate_limit",
+			ExpectedCircuit:    "closed",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/rate_limit" {
+					t.Errorf("expected the rate limit API to be probed, got %v", r.URL.Path)
+				}
+				w.WriteHeader(test.GithubStatusCode)
+				w.Write([]byte(`{"resources":{}}`))
+			}))
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+			s.registerHandlers()
+			s.listening.Store(test.Listening)
+			for i := 0; i < test.UpstreamFailures; i++ {
+				s.upstreamHealth.record(errors.New("connection refused"))
+			}
+			if test.RateLimitExhausted {
+				header := http.Header{}
+				header.Set("X-RateLimit-Limit", "60")
+				header.Set("X-RateLimit-Remaining", "0")
+				header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+				s.githubRateLimiter.update(header)
+			}
+
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Fatalf("expected status %v, got %v: %s", test.ExpectedStatusCode, responseRecorder.Code, responseRecorder.Body.String())
+			}
+			result := &healthResult{}
+			json.Unmarshal(responseRecorder.Body.Bytes(), result)
+			for eachName, eachCheck := range result.Checks {
+				if failed := eachCheck.Status != HEALTH_STATUS_OK; failed != (eachName == test.ExpectedFailed) {
+					t.Errorf("unexpected check %v: %v %v", eachName, eachCheck.Status, eachCheck.Detail)
+				}
+			}
+			if circuit := s.upstreamHealth.status().Circuit; circuit != test.ExpectedCircuit {
+				t.Errorf("expected circuit %v, got %v", test.ExpectedCircuit, circuit)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1792368000")
+		w.Header().Set("X-RateLimit-Resource", "core")
+		jsonString, _ := json.Marshal(model.GithubUserInfo{Login: "a"})
+		w.Write(jsonString)
+	}))
+	defer githubAPITestServer.Close()
+
+	s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users").WithVersion("1.2.3"))
+	s.registerHandlers()
+	s.serverMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/retrieveUsers?usernames=a", nil))
+	responseRecorder := httptest.NewRecorder()
+	s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	status := &serverStatus{}
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), status)
+	if err != nil {
+		t.Fatalf("expected status JSON, got %v", err)
+	}
+	if status.Version != "1.2.3" {
+		t.Errorf("expected version 1.2.3, got %v", status.Version)
+	}
+	if status.StartedAt.IsZero() || status.UptimeSeconds < 0 {
+		t.Errorf("expected started at and uptime, got %v %v", status.StartedAt, status.UptimeSeconds)
+	}
+	if userInfoCache := status.Caches[CACHE_USER_INFO]; userInfoCache == nil || userInfoCache.Entries == nil || *userInfoCache.Entries != 1 || userInfoCache.Misses != 1 {
+		t.Errorf("expected 1 cached user after 1 miss, got %+v", userInfoCache)
+	}
+	if len(status.Caches) != 4 {
+		t.Errorf("expected 4 caches, got %v", len(status.Caches))
+	}
+	if coreRateLimit := status.Github.RateLimits[RATE_LIMIT_RESOURCE_CORE]; coreRateLimit == nil || coreRateLimit.Remaining != 4999 || coreRateLimit.Limit != 5000 {
+		t.Errorf("expected core quota 4999/5000, got %+v", coreRateLimit)
+	}
+	if status.Github.Upstream != GITHUB_UPSTREAM_REST || status.Github.Circuit != "closed" || status.Github.LastSuccess == nil {
+		t.Errorf("expected a closed circuit after a successful call, got %+v", status.Github)
+	}
+}
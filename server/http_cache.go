@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cacheFreshnessContextKey key of the cache freshness collected while a GraphQL query calls the REST handlers
+	cacheFreshnessContextKey contextKey = "cacheFreshness"
+)
+
+// cacheFreshness freshness of a response built from cache entries: the newest entry, the earliest expiry and whether the response can be cached at all
+type cacheFreshness struct {
+	lock         *sync.Mutex
+	lastModified time.Time
+	expiresAt    time.Time
+	uncacheable  bool
+}
+
+// newCacheFreshness return the freshness of a response not built from any cache entry yet
+func newCacheFreshness() *cacheFreshness {
+	return &cacheFreshness{
+		lock: &sync.Mutex{},
+	}
+}
+
+// addEntry include a cache entry in the response
+func (f *cacheFreshness) addEntry(storedAt time.Time, expiresAt time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if storedAt.After(f.lastModified) {
+		f.lastModified = storedAt
+	}
+	if !expiresAt.IsZero() && (f.expiresAt.IsZero() || expiresAt.Before(f.expiresAt)) {
+		f.expiresAt = expiresAt
+	}
+}
+
+// merge include the entries of the other freshness in the response
+func (f *cacheFreshness) merge(other *cacheFreshness) {
+	other.lock.Lock()
+	lastModified, expiresAt, uncacheable := other.lastModified, other.expiresAt, other.uncacheable
+	other.lock.Unlock()
+
+	f.addEntry(lastModified, expiresAt)
+	if uncacheable {
+		f.setUncacheable()
+	}
+}
+
+// setUncacheable mark the response as not cacheable, it is still revalidated with its ETag
+func (f *cacheFreshness) setUncacheable() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.uncacheable = true
+}
+
+// cacheControl return the Cache-Control header value: max-age is the remaining time before the earliest entry expires
+func (f *cacheFreshness) cacheControl(now time.Time) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.uncacheable || f.expiresAt.IsZero() {
+		return "no-cache"
+	}
+	maxAge := int(f.expiresAt.Sub(now) / time.Second)
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", maxAge)
+}
+
+// userCacheFreshness return the freshness of a response including the cached users, a response with errors is not cacheable
+func (s *Server) userCacheFreshness(usernames []string, hasErrors bool) *cacheFreshness {
+	freshness := newCacheFreshness()
+	for _, eachUsername := range usernames {
+		entry := s.githubUserInfoCache.GetEntry(eachUsername)
+		if entry != nil {
+			freshness.addEntry(entry.StoredAt, entry.ExpiresAt)
+		}
+	}
+	if hasErrors {
+		freshness.setUncacheable()
+	}
+	return freshness
+}
+
+// recordCacheFreshness report the freshness of the response to the GraphQL query calling the handler, if any
+func recordCacheFreshness(ctx context.Context, freshness *cacheFreshness) {
+	if queryFreshness, ok := ctx.Value(cacheFreshnessContextKey).(*cacheFreshness); ok {
+		queryFreshness.merge(freshness)
+	}
+}
+
+// entityTag return a weak ETag of the representation, weak because compression changes the bytes but not the representation
+func entityTag(hash []byte) string {
+	return fmt.Sprintf(`W/"%s"`, hex.EncodeToString(hash[:16]))
+}
+
+// resultEntityTag return the ETag of the result rendered in the format with the selected fields
+func resultEntityTag(format string, fields []string, pretty bool, resultObj interface{}) (string, error) {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%s\n%s\n%t\n", format, strings.Join(fields, ","), pretty)
+	err := encodeJSON(hasher, resultObj, false)
+	if err != nil {
+		return "", err
+	}
+	return entityTag(hasher.Sum(nil)), nil
+}
+
+// etagMatches check whether the If-None-Match header matches the ETag, with the weak comparison
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, eachTag := range strings.Split(ifNoneMatch, ",") {
+		eachTag = strings.TrimSpace(eachTag)
+		if eachTag == "*" || strings.TrimPrefix(eachTag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCacheHeaders set the ETag, Last-Modified and Cache-Control headers, and write 304 Not Modified when If-None-Match matches the ETag (return true)
+func writeCacheHeaders(w http.ResponseWriter, r *http.Request, etag string, freshness *cacheFreshness) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", freshness.cacheControl(time.Now()))
+	freshness.lock.Lock()
+	lastModified := freshness.lastModified
+	freshness.lock.Unlock()
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// graphQLCacheHandler add the cache headers to the successful responses of GraphQL GET queries and answer If-None-Match with 304,
+// the freshness is collected from the REST handlers called by the resolvers
+func graphQLCacheHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		freshness := newCacheFreshness()
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, r.WithContext(context.WithValue(r.Context(), cacheFreshnessContextKey, freshness)))
+
+		for eachName, eachValues := range responseRecorder.Header() {
+			w.Header()[eachName] = eachValues
+		}
+		if responseRecorder.Code == http.StatusOK {
+			// A query that partially failed is not cacheable
+			var graphQLResponse struct {
+				Errors json.RawMessage `json:"errors"`
+			}
+			json.Unmarshal(responseRecorder.Body.Bytes(), &graphQLResponse)
+			if len(graphQLResponse.Errors) > 0 && string(graphQLResponse.Errors) != "null" {
+				freshness.setUncacheable()
+			}
+
+			hash := sha256.Sum256(responseRecorder.Body.Bytes())
+			if writeCacheHeaders(w, r, entityTag(hash[:]), freshness) {
+				return
+			}
+		}
+		w.WriteHeader(responseRecorder.Code)
+		w.Write(responseRecorder.Body.Bytes())
+	})
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCacheTestServer return a mock github API serving user a and b, and not found for the other users
+func newCacheTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if login != "a" && login != "b" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+			return
+		}
+		jsonString, _ := json.Marshal(model.GithubUserInfo{Login: login, Name: login})
+		w.Write(jsonString)
+	}))
+}
+
+// maxAge return the max-age of the Cache-Control header, -1 when there is none
+func maxAge(cacheControl string) int {
+	_, value, found := strings.Cut(cacheControl, "max-age=")
+	if !found {
+		return -1
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return seconds
+}
+
+func TestRetrieveUsersCacheHeaders(t *testing.T) {
+	tests := map[string]struct {
+		Query              string
+		RevalidateQuery    string
+		IfNoneMatch        func(etag string) string
+		ExpectedStatusCode int
+		ExpectedCacheable  bool
+	}{
+		"Test matching ETag": {
+			Query:              "usernames=a,b",
+			IfNoneMatch:        func(etag string) string { return etag },
+			ExpectedStatusCode: http.StatusNotModified,
+			ExpectedCacheable:  true,
+		},
+		"Test matching ETag among others": {
+			Query:              "usernames=a,b",
+			IfNoneMatch:        func(etag string) string { return `W/"other", ` + etag },
+			ExpectedStatusCode: http.StatusNotModified,
+			ExpectedCacheable:  true,
+		},
+		"Test matching strong ETag": {
+			Query:              "usernames=a,b",
+			IfNoneMatch:        func(etag string) string { return strings.TrimPrefix(etag, "W/") },
+			ExpectedStatusCode: http.StatusNotModified,
+			ExpectedCacheable:  true,
+		},
+		"Test wildcard": {
+			Query:              "usernames=a,b",
+			IfNoneMatch:        func(etag string) string { return "*" },
+			ExpectedStatusCode: http.StatusNotModified,
+			ExpectedCacheable:  true,
+		},
+		"Test stale ETag": {
+			Query:              "usernames=a,b",
+			IfNoneMatch:        func(etag string) string { return `W/"other"` },
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCacheable:  true,
+		},
+		"Test other users": {
+			Query:              "usernames=a,b",
+			RevalidateQuery:    "usernames=a",
+			IfNoneMatch:        func(etag string) string { return etag },
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCacheable:  true,
+		},
+		"Test other format": {
+			Query:              "usernames=a,b",
+			RevalidateQuery:    "usernames=a,b&format=csv",
+			IfNoneMatch:        func(etag string) string { return etag },
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCacheable:  true,
+		},
+		"Test pretty": {
+			Query:              "usernames=a,b",
+			RevalidateQuery:    "usernames=a,b&pretty=true",
+			IfNoneMatch:        func(etag string) string { return etag },
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCacheable:  true,
+		},
+		"Test partial result": {
+			Query:              "usernames=a,notfound",
+			IfNoneMatch:        func(etag string) string { return etag },
+			ExpectedStatusCode: http.StatusNotModified,
+			ExpectedCacheable:  false,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newCacheTestServer()
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users").WithCacheTTL(time.Minute))
+			responseRecorder := httptest.NewRecorder()
+			s.retrieveUsers(responseRecorder, httptest.NewRequest(http.MethodGet, "/retrieveUsers?"+test.Query, nil))
+			if responseRecorder.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, responseRecorder.Code)
+			}
+
+			etag := responseRecorder.Header().Get("ETag")
+			if !strings.HasPrefix(etag, `W/"`) {
+				t.Fatalf("expected weak ETag, got %q", etag)
+			}
+			if _, err := http.ParseTime(responseRecorder.Header().Get("Last-Modified")); err != nil {
+				t.Errorf("expected Last-Modified, got %q", responseRecorder.Header().Get("Last-Modified"))
+			}
+			cacheControl := responseRecorder.Header().Get("Cache-Control")
+			if test.ExpectedCacheable {
+				if seconds := maxAge(cacheControl); seconds < 58 || seconds > 60 {
+					t.Errorf("expected max-age of the remaining ttl, got %q", cacheControl)
+				}
+			} else if cacheControl != "no-cache" {
+				t.Errorf("expected no-cache, got %q", cacheControl)
+			}
+
+			revalidateQuery := test.RevalidateQuery
+			if revalidateQuery == "" {
+				revalidateQuery = test.Query
+			}
+			request := httptest.NewRequest(http.MethodGet, "/retrieveUsers?"+revalidateQuery, nil)
+			request.Header.Set("If-None-Match", test.IfNoneMatch(etag))
+			responseRecorder = httptest.NewRecorder()
+			s.retrieveUsers(responseRecorder, request)
+
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Fatalf("expected status %v, got %v", test.ExpectedStatusCode, responseRecorder.Code)
+			}
+			if test.ExpectedStatusCode == http.StatusNotModified {
+				if responseRecorder.Body.Len() != 0 {
+					t.Errorf("expected no body, got %s", responseRecorder.Body.String())
+				}
+				if responseRecorder.Header().Get("ETag") != etag {
+					t.Errorf("expected ETag %v, got %v", etag, responseRecorder.Header().Get("ETag"))
+				}
+			} else if (responseRecorder.Header().Get("ETag") == etag) != (revalidateQuery == test.Query) {
+				t.Errorf("expected the ETag to change only with the representation, got %v then %v", etag, responseRecorder.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestGraphQLCacheHeaders(t *testing.T) {
+	tests := map[string]struct {
+		Query             string
+		ExpectedCacheable bool
+	}{
+		"Test users": {
+			Query:             `{retrieveUsers(usernames:["a","b"]){users{login} errors{message}}}`,
+			ExpectedCacheable: true,
+		},
+		"Test partial users": {
+			Query:             `{retrieveUsers(usernames:["a","notfound"]){users{login} errors{message}}}`,
+			ExpectedCacheable: false,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newCacheTestServer()
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users").WithCacheTTL(time.Minute))
+			s.registerHandlers()
+			target := "/graphql/query?query=" + url.QueryEscape(test.Query)
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, target, nil))
+			if responseRecorder.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v: %s", http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+			}
+
+			etag := responseRecorder.Header().Get("ETag")
+			if etag == "" {
+				t.Fatalf("expected ETag")
+			}
+			cacheControl := responseRecorder.Header().Get("Cache-Control")
+			if cacheable := maxAge(cacheControl) > 0; cacheable != test.ExpectedCacheable {
+				t.Errorf("expected cacheable %v, got %q", test.ExpectedCacheable, cacheControl)
+			}
+			if test.ExpectedCacheable && responseRecorder.Header().Get("Last-Modified") == "" {
+				t.Errorf("expected Last-Modified")
+			}
+
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+			request.Header.Set("If-None-Match", etag)
+			responseRecorder = httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+			if responseRecorder.Code != http.StatusNotModified {
+				t.Errorf("expected status %v, got %v", http.StatusNotModified, responseRecorder.Code)
+			}
+		})
+	}
+}
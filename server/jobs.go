@@ -0,0 +1,429 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// JOB_STATUS_QUEUED job is waiting for a worker
+	JOB_STATUS_QUEUED = "queued"
+	// JOB_STATUS_RUNNING job is being processed by a worker
+	JOB_STATUS_RUNNING = "running"
+	// JOB_STATUS_COMPLETED every username of the job has been processed, the result is available
+	JOB_STATUS_COMPLETED = "completed"
+	// JOB_MAX_REQUEST_BYTES maximum size of the body of POST /jobs, enough for the maximum number of usernames
+	JOB_MAX_REQUEST_BYTES = 1 << 20
+)
+
+// jobRequest body of POST /jobs
+type jobRequest struct {
+	Usernames        []string `json:"usernames"`
+	IncludeRepoStats bool     `json:"include_repo_stats"`
+}
+
+// jobStatus progress of a job as returned by the jobs API
+type jobStatus struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Done       int        `json:"done"`
+	Failed     int        `json:"failed"`
+	Remaining  int        `json:"remaining"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ResultURL  string     `json:"result_url,omitempty"`
+}
+
+// batchJob a username list fetched in the background
+type batchJob struct {
+	id               string
+	usernames        []string // usernames as submitted, the organizations are expanded when the job runs
+	includeRepoStats bool
+	requestID        string // X-Request-ID of the request submitting the job, carried by the errors of the result
+	createdAt        time.Time
+
+	lock       *sync.RWMutex
+	status     string
+	total      int
+	done       int
+	failed     int
+	startedAt  time.Time
+	finishedAt time.Time
+	result     *model.ResultRetrieveUsers
+}
+
+// jobQueue in-process queue of batch jobs, jobs are processed by background workers independently of the client connection
+type jobQueue struct {
+	server    *Server
+	jobs      map[string]*batchJob
+	jobsLock  *sync.RWMutex
+	pending   chan *batchJob
+	startOnce *sync.Once
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	draining    bool            // no job is accepted once the queue is draining, guarded by jobsLock
+	outstanding *sync.WaitGroup // jobs queued or running
+	workers     *sync.WaitGroup // running workers
+}
+
+// newJobQueue return new job queue, the workers are started with the first submitted job
+func newJobQueue(s *Server) *jobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobQueue{
+		server:    s,
+		jobs:      make(map[string]*batchJob),
+		jobsLock:  &sync.RWMutex{},
+		pending:   make(chan *batchJob, s.config.jobQueueSize),
+		startOnce: &sync.Once{},
+		ctx:       ctx,
+		cancel:    cancel,
+
+		outstanding: &sync.WaitGroup{},
+		workers:     &sync.WaitGroup{},
+	}
+}
+
+// submit queue a new job, return error when the queue is full or draining
+func (q *jobQueue) submit(usernames []string, includeRepoStats bool, requestID string) (*batchJob, error) {
+	q.startOnce.Do(func() {
+		for i := 0; i < q.server.config.jobWorkers; i++ {
+			q.workers.Add(1)
+			go q.work()
+		}
+	})
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &batchJob{
+		id:               id,
+		usernames:        usernames,
+		includeRepoStats: includeRepoStats,
+		requestID:        requestID,
+		createdAt:        time.Now(),
+		lock:             &sync.RWMutex{},
+		status:           JOB_STATUS_QUEUED,
+		total:            len(usernames),
+	}
+
+	q.jobsLock.Lock()
+	defer q.jobsLock.Unlock()
+	if q.draining {
+		return nil, errors.New("server is shutting down, retry later")
+	}
+	q.removeExpiredJobs()
+	select {
+	case q.pending <- job:
+		q.jobs[id] = job
+		q.outstanding.Add(1)
+		return job, nil
+	default:
+		return nil, errors.New("job queue is full, retry later")
+	}
+}
+
+// get return the job by its id (nil if not found)
+func (q *jobQueue) get(id string) *batchJob {
+	q.jobsLock.RLock()
+	defer q.jobsLock.RUnlock()
+	return q.jobs[id]
+}
+
+// removeExpiredJobs remove the jobs finished for longer than the retention, jobsLock must be held
+func (q *jobQueue) removeExpiredJobs() {
+	for id, eachJob := range q.jobs {
+		eachJob.lock.RLock()
+		expired := eachJob.status == JOB_STATUS_COMPLETED && time.Since(eachJob.finishedAt) > q.server.config.jobRetention
+		eachJob.lock.RUnlock()
+		if expired {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// work process the queued jobs one at a time until the queue is stopped
+func (q *jobQueue) work() {
+	defer q.workers.Done()
+	for {
+		select {
+		case job := <-q.pending:
+			q.server.runJob(q.ctx, job)
+			q.outstanding.Done()
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+// drain stop accepting jobs and wait for the queued and running jobs to complete, when the context is done first
+// the running jobs are cancelled (they complete with the users fetched so far) and the queued jobs are dropped
+func (q *jobQueue) drain(ctx context.Context) error {
+	q.jobsLock.Lock()
+	q.draining = true
+	q.jobsLock.Unlock()
+
+	completed := make(chan struct{})
+	go func() {
+		q.outstanding.Wait()
+		close(completed)
+	}()
+
+	var err error
+	select {
+	case <-completed:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	q.cancel()
+	q.workers.Wait()
+	return err
+}
+
+// runJob fetch the users of the job sequentially in batches of the upstream size, waiting for the rate limit to reset when it is exhausted
+func (s *Server) runJob(ctx context.Context, job *batchJob) {
+	ctx, span := s.tracer.Start(ctx, "job.run", trace.WithAttributes(attribute.String("job.id", job.id), attribute.String("request.id", job.requestID)))
+	defer span.End()
+	// The work of the job is logged under the request submitting it
+	runStart := time.Now()
+	requestLog := &requestLog{
+		id:     job.requestID,
+		logger: s.logger,
+	}
+	ctx = context.WithValue(ctx, requestLogContextKey{}, requestLog)
+	client := s.newGithubClient(ctx)
+
+	job.lock.Lock()
+	job.status = JOB_STATUS_RUNNING
+	job.startedAt = time.Now()
+	job.lock.Unlock()
+
+	resultObj := &model.ResultRetrieveUsers{
+		Users:  make([]*model.GithubUserInfo, 0),
+		Errors: make([]*model.ResultError, 0),
+	}
+	usernames, expandErrors := s.resolveUsernames(client, job.usernames)
+	resultObj.Errors = append(resultObj.Errors, expandErrors...)
+	if len(usernames) > s.config.jobMaxUsernames {
+		// The job fails without fetching any user rather than fetching a truncated list
+		resultObj.Errors = append(resultObj.Errors, &model.ResultError{
+			Message:    fmt.Sprintf("too many usernames %d once the organizations are expanded, a job accepts at most %d", len(usernames), s.config.jobMaxUsernames),
+			StatusCode: http.StatusBadRequest,
+		})
+		usernames = nil
+	}
+
+	job.lock.Lock()
+	job.total = len(usernames)
+	job.lock.Unlock()
+
+	batchSize := s.githubUserFetcher.batchSize()
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	for start := 0; start < len(usernames) && ctx.Err() == nil; start += batchSize {
+		end := start + batchSize
+		if end > len(usernames) {
+			end = len(usernames)
+		}
+
+		batchUsernames := usernames[start:end]
+		for len(batchUsernames) > 0 && ctx.Err() == nil {
+			userFetchResults := s.retrieveUserInfos(client, batchUsernames)
+			rateLimitedUsernames := make([]string, 0)
+			var rateLimitErr *rateLimitError
+			for _, eachUsername := range batchUsernames {
+				fetchResult := userFetchResults[eachUsername]
+				if errors.As(fetchResult.err, &rateLimitErr) {
+					// Retry once the rate limit bucket has been reset
+					rateLimitedUsernames = append(rateLimitedUsernames, eachUsername)
+					continue
+				}
+
+				userInfo, userErrors := s.processUserFetchResult(client, eachUsername, fetchResult, job.includeRepoStats)
+				resultObj.Errors = append(resultObj.Errors, userErrors...)
+				job.lock.Lock()
+				if userInfo != nil {
+					resultObj.Users = append(resultObj.Users, userInfo)
+					job.done++
+				} else {
+					job.failed++
+				}
+				job.lock.Unlock()
+			}
+
+			batchUsernames = rateLimitedUsernames
+			if rateLimitErr != nil {
+				waitUntil(ctx, rateLimitErr.Reset)
+			}
+		}
+	}
+
+	sortUsers(resultObj.Users)
+	resultObj.Errors = resultErrorsWithRequestID(resultObj.Errors, job.requestID)
+	s.logger.info("job completed", logFields{
+		"job_id":         job.id,
+		"request_id":     job.requestID,
+		"latency_ms":     float64(time.Since(runStart).Microseconds()) / 1000,
+		"usernames":      len(usernames),
+		"errors":         len(resultObj.Errors),
+		"cache_hits":     requestLog.cacheHits.Load(),
+		"cache_misses":   requestLog.cacheMisses.Load(),
+		"upstream_calls": requestLog.upstreamCalls.Load(),
+	})
+
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.status = JOB_STATUS_COMPLETED
+	job.finishedAt = time.Now()
+	job.result = resultObj
+}
+
+// waitUntil block until the time is reached or the context is done
+func waitUntil(ctx context.Context, until time.Time) {
+	timer := time.NewTimer(time.Until(until) + time.Second)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// jobStatus return the progress of the job, the result URL is under the API prefix
+func (job *batchJob) jobStatus(apiPrefix string) *jobStatus {
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	status := &jobStatus{
+		ID:        job.id,
+		Status:    job.status,
+		Total:     job.total,
+		Done:      job.done,
+		Failed:    job.failed,
+		Remaining: job.total - job.done - job.failed,
+		CreatedAt: job.createdAt,
+	}
+	if !job.startedAt.IsZero() {
+		startedAt := job.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !job.finishedAt.IsZero() {
+		finishedAt := job.finishedAt
+		status.FinishedAt = &finishedAt
+		status.ResultURL = fmt.Sprintf("%s/jobs/%s/result", apiPrefix, job.id)
+	}
+	return status
+}
+
+// submitJob handling POST /jobs, the body is a JSON jobRequest or a form with comma separated usernames
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	request := &jobRequest{}
+	r.Body = http.MaxBytesReader(w, r.Body, JOB_MAX_REQUEST_BYTES)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(request)
+		if err != nil {
+			writeProblem(w, r, jobRequestErrorStatus(err), fmt.Sprintf("invalid job request: %v", err), nil)
+			return
+		}
+	} else {
+		err := r.ParseForm()
+		if err != nil {
+			writeProblem(w, r, jobRequestErrorStatus(err), fmt.Sprintf("invalid job request: %v", err), nil)
+			return
+		}
+		if usernamesFormValue := r.FormValue("usernames"); usernamesFormValue != "" {
+			request.Usernames = strings.Split(usernamesFormValue, ",")
+		}
+		request.IncludeRepoStats = r.FormValue("includeRepoStats") == "true"
+	}
+
+	if len(request.Usernames) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, "usernames is required", nil)
+		return
+	}
+	if len(request.Usernames) > s.config.jobMaxUsernames {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("too many usernames %d, a job accepts at most %d", len(request.Usernames), s.config.jobMaxUsernames), nil)
+		return
+	}
+	if invalidErrors := invalidUsernameErrors(request.Usernames); len(invalidErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, "invalid usernames", invalidErrors)
+		return
+	}
+
+	job, err := s.jobQueue.submit(request.Usernames, request.IncludeRepoStats, requestIDFromContext(r.Context()))
+	if err != nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/jobs/%s", requestAPIPrefix(r), job.id))
+	writeJSONResponseWithStatus(w, r, http.StatusAccepted, job.jobStatus(requestAPIPrefix(r)))
+}
+
+// jobRequestErrorStatus return the status code of an unreadable job request: 413 when the body is too large, otherwise 400
+func jobRequestErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// retrieveJob handling GET /jobs/{id} (progress) and GET /jobs/{id}/result (result of a completed job)
+func (s *Server) retrieveJob(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	id, resource, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/"), "/")
+	job := s.jobQueue.get(id)
+	if job == nil || (resource != "" && resource != "result") {
+		writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("job %q not found", id), nil)
+		return
+	}
+
+	status := job.jobStatus(requestAPIPrefix(r))
+	if resource == "" {
+		writeJSONResponse(w, r, status)
+		return
+	}
+	if status.Status != JOB_STATUS_COMPLETED {
+		// Result is not available yet, return the progress instead
+		w.Header().Set("Location", fmt.Sprintf("%s/jobs/%s", requestAPIPrefix(r), job.id))
+		writeJSONResponseWithStatus(w, r, http.StatusAccepted, status)
+		return
+	}
+
+	job.lock.RLock()
+	resultObj := job.result
+	job.lock.RUnlock()
+	writeJSONResponse(w, r, resultObj)
+}
+
+// newJobID return a random job id
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForJob poll the job status until it is completed
+func waitForJob(t *testing.T, s *Server, id string) *jobStatus {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		responseRecorder := httptest.NewRecorder()
+		s.retrieveJob(responseRecorder, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/jobs/%s", id), nil))
+		status := &jobStatus{}
+		err := json.Unmarshal(responseRecorder.Body.Bytes(), status)
+		if err != nil {
+			t.Fatalf("expected no error when parsing job status, got %v", err)
+		}
+		if status.Status == JOB_STATUS_COMPLETED {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %v did not complete in time", id)
+	return nil
+}
+
+func TestSubmitJobValidation(t *testing.T) {
+	tests := map[string]struct {
+		Method             string
+		ContentType        string
+		Body               string
+		ExpectedStatusCode int
+	}{
+		"Test method not allowed": {
+			Method:             http.MethodGet,
+			ExpectedStatusCode: http.StatusMethodNotAllowed,
+		},
+		"Test invalid JSON body": {
+			Method:             http.MethodPost,
+			ContentType:        "application/json",
+			Body:               `{"usernames":`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test no usernames": {
+			Method:             http.MethodPost,
+			ContentType:        "application/json",
+			Body:               `{"usernames":[]}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test too many usernames": {
+			Method:             http.MethodPost,
+			ContentType:        "application/x-www-form-urlencoded",
+			Body:               "usernames=a,b,c,d",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Test organizations expanded when the job runs": {
+			Method:             http.MethodPost,
+			ContentType:        "application/json",
+			Body:               `{"usernames":["d","org:apache"]}`,
+			ExpectedStatusCode: http.StatusAccepted,
+		},
+		"Test duplicated usernames within the limit": {
+			Method:             http.MethodPost,
+			ContentType:        "application/json",
+			Body:               `{"usernames":["a","org:apache"]}`,
+			ExpectedStatusCode: http.StatusAccepted,
+		},
+		"Test request body too large": {
+			Method:             http.MethodPost,
+			ContentType:        "application/json",
+			Body:               `{"usernames":["a"],"include_repo_stats":false` + strings.Repeat(" ", JOB_MAX_REQUEST_BYTES) + `}`,
+			ExpectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		"Test form usernames": {
+			Method:             http.MethodPost,
+			ContentType:        "application/x-www-form-urlencoded",
+			Body:               "usernames=a,b",
+			ExpectedStatusCode: http.StatusAccepted,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			config.jobMaxUsernames = 3
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(test.Method, "/jobs", strings.NewReader(test.Body))
+			if test.ContentType != "" {
+				request.Header.Set("Content-Type", test.ContentType)
+			}
+			s.submitJob(responseRecorder, request)
+
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Errorf("expected status %v, got %v", test.ExpectedStatusCode, responseRecorder.Code)
+			}
+		})
+	}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	githubAPITestServer := newOrganizationTestServer(t)
+	defer githubAPITestServer.Close()
+
+	config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+	s := NewServer(config)
+
+	responseRecorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"usernames":["b","a","notfound","org:apache"]}`))
+	request.Header.Set("Content-Type", "application/json")
+	s.submitJob(responseRecorder, request)
+	if responseRecorder.Code != http.StatusAccepted {
+		t.Fatalf("expected status %v, got %v", http.StatusAccepted, responseRecorder.Code)
+	}
+	submitted := &jobStatus{}
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), submitted)
+	if err != nil {
+		t.Fatalf("expected no error when parsing job status, got %v", err)
+	}
+	if location := responseRecorder.Header().Get("Location"); location != "/jobs/"+submitted.ID {
+		t.Errorf("expected location of the job, got %v", location)
+	}
+
+	status := waitForJob(t, s, submitted.ID)
+	if status.Total != 4 || status.Done != 3 || status.Failed != 1 || status.Remaining != 0 {
+		t.Errorf("unexpected job status %+v", status)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	s.retrieveJob(responseRecorder, httptest.NewRequest(http.MethodGet, status.ResultURL, nil))
+	resultObj := &model.ResultRetrieveUsers{}
+	err = json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+	if err != nil {
+		t.Fatalf("expected no error when parsing job result, got %v", err)
+	}
+	logins := make([]string, 0)
+	for _, eachUser := range resultObj.Users {
+		logins = append(logins, eachUser.Login)
+	}
+	if strings.Join(logins, ",") != "a,b,c" {
+		t.Errorf("expected users a,b,c, got %v", logins)
+	}
+	if len(resultObj.Errors) != 1 || !strings.Contains(resultObj.Errors[0].Message, "notfound") {
+		t.Errorf("expected an error for notfound, got %v", resultObj.Errors)
+	}
+}
+
+func TestJobTooManyUsernamesOnceExpanded(t *testing.T) {
+	githubAPITestServer := newOrganizationTestServer(t)
+	defer githubAPITestServer.Close()
+
+	config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+	config.jobMaxUsernames = 3
+	s := NewServer(config)
+	job, err := s.jobQueue.submit([]string{"d", "org:apache"}, false, "")
+	if err != nil {
+		t.Fatalf("expected no error when submitting job, got %v", err)
+	}
+
+	status := waitForJob(t, s, job.id)
+	if status.Total != 0 || status.Done != 0 || status.Failed != 0 {
+		t.Errorf("expected no user fetched, got %+v", status)
+	}
+	job.lock.RLock()
+	resultObj := job.result
+	job.lock.RUnlock()
+	if len(resultObj.Users) != 0 {
+		t.Errorf("expected no users, got %v", resultObj.Users)
+	}
+	if len(resultObj.Errors) != 1 || !strings.Contains(resultObj.Errors[0].Message, "too many usernames 4 once the organizations are expanded") {
+		t.Errorf("expected the too many usernames error, got %v", resultObj.Errors)
+	}
+}
+
+func TestRetrieveJobNotFound(t *testing.T) {
+	tests := map[string]struct {
+		Path string
+	}{
+		"Test unknown job":          {Path: "/jobs/unknown"},
+		"Test unknown job result":   {Path: "/jobs/unknown/result"},
+		"Test unknown job resource": {Path: "/jobs/unknown/other"},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			s := NewServer(NewServerConfig("", 8777, "http://localhost", "users"))
+			responseRecorder := httptest.NewRecorder()
+			s.retrieveJob(responseRecorder, httptest.NewRequest(http.MethodGet, test.Path, nil))
+			if responseRecorder.Code != http.StatusNotFound {
+				t.Errorf("expected status %v, got %v", http.StatusNotFound, responseRecorder.Code)
+			}
+		})
+	}
+}
+
+func TestJobWaitForRateLimitReset(t *testing.T) {
+	calls := int32(0)
+	githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// First call exhaust the core bucket for a second
+			w.Header().Set("X-RateLimit-Limit", "60")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Unix()+1))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		jsonString, _ := json.Marshal(model.GithubUserInfo{Login: "a", Name: "a"})
+		w.Write(jsonString)
+	}))
+	defer githubAPITestServer.Close()
+
+	s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+	job, err := s.jobQueue.submit([]string{"a"}, false, "")
+	if err != nil {
+		t.Fatalf("expected no error when submitting job, got %v", err)
+	}
+
+	status := waitForJob(t, s, job.id)
+	if status.Done != 1 || status.Failed != 0 {
+		t.Errorf("expected the user to be fetched after the rate limit reset, got %+v", status)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("expected 2 upstream calls, got %v", calls)
+	}
+}
+
+func TestJobQueueDrain(t *testing.T) {
+	tests := map[string]struct {
+		GracePeriod   time.Duration
+		ExpectedError bool
+		ExpectedDone  int
+	}{
+		"Test jobs completed within the grace period": {
+			GracePeriod:  5 * time.Second,
+			ExpectedDone: 3,
+		},
+		"Test jobs cancelled after the grace period": {
+			GracePeriod:   300 * time.Millisecond,
+			ExpectedError: true,
+			ExpectedDone:  1,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(200 * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+				login := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+				jsonString, _ := json.Marshal(model.GithubUserInfo{Login: login, Name: login})
+				w.Write(jsonString)
+			}))
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+			job, err := s.jobQueue.submit([]string{"a", "b", "c"}, false, "")
+			if err != nil {
+				t.Fatalf("expected no error when submitting job, got %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.GracePeriod)
+			defer cancel()
+			err = s.jobQueue.drain(ctx)
+			if (err != nil) != test.ExpectedError {
+				t.Errorf("expected error %v, got %v", test.ExpectedError, err)
+			}
+
+			status := job.jobStatus("")
+			if status.Status != JOB_STATUS_COMPLETED || status.Done != test.ExpectedDone {
+				t.Errorf("expected job completed with %v users, got %+v", test.ExpectedDone, status)
+			}
+			if _, err := s.jobQueue.submit([]string{"a"}, false, ""); err == nil {
+				t.Errorf("expected jobs to be refused once the queue is drained")
+			}
+		})
+	}
+}
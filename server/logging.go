@@ -0,0 +1,341 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// LOG_LEVEL_DEBUG log everything, including the access logs of the metrics, probes and documentation routes
+	LOG_LEVEL_DEBUG = "debug"
+	// LOG_LEVEL_INFO log the access logs and the server lifecycle
+	LOG_LEVEL_INFO = "info"
+	// LOG_LEVEL_WARN log the client errors and the degraded behaviors
+	LOG_LEVEL_WARN = "warn"
+	// LOG_LEVEL_ERROR log the server and upstream failures only
+	LOG_LEVEL_ERROR = "error"
+
+	// REQUEST_ID_HEADER header carrying the request id, propagated from the request or generated, echoed in the response
+	REQUEST_ID_HEADER = "X-Request-ID"
+	// REQUEST_ID_MAX_LENGTH longest request id accepted from the request, longer ids are replaced by a generated one
+	REQUEST_ID_MAX_LENGTH = 128
+)
+
+// logLevelSeverities severity of each log level, records below the configured level are dropped
+var logLevelSeverities = map[string]int{
+	LOG_LEVEL_DEBUG: 0,
+	LOG_LEVEL_INFO:  1,
+	LOG_LEVEL_WARN:  2,
+	LOG_LEVEL_ERROR: 3,
+}
+
+// ParseLogLevel return the log level matching the name (case insensitive)
+func ParseLogLevel(name string) (string, error) {
+	level := strings.ToLower(strings.TrimSpace(name))
+	if _, ok := logLevelSeverities[level]; !ok {
+		return "", fmt.Errorf("invalid log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// logFields fields of a log record
+type logFields map[string]interface{}
+
+// logger write one JSON object per line with the time, the level, the message and the fields (sorted by name)
+type logger struct {
+	lock     sync.Mutex
+	out      io.Writer
+	severity int
+}
+
+// newLogger return a logger writing the records of the level and above to out
+func newLogger(out io.Writer, level string) *logger {
+	return &logger{
+		out:      out,
+		severity: logLevelSeverities[level],
+	}
+}
+
+// defaultLogger logger used when the request is not served by a server (e.g. handlers called directly)
+var defaultLogger = newLogger(os.Stderr, LOG_LEVEL_INFO)
+
+// enabled check whether the records of the level are written
+func (l *logger) enabled(level string) bool {
+	return logLevelSeverities[level] >= l.severity
+}
+
+// log write the record if its level is enabled, the fields that can not be encoded are written as text
+func (l *logger) log(level string, message string, fields logFields) {
+	if !l.enabled(level) {
+		return
+	}
+
+	buffer := &bytes.Buffer{}
+	buffer.WriteString(`{"time":`)
+	writeLogValue(buffer, time.Now().UTC().Format(time.RFC3339Nano))
+	buffer.WriteString(`,"level":`)
+	writeLogValue(buffer, level)
+	buffer.WriteString(`,"msg":`)
+	writeLogValue(buffer, message)
+
+	names := make([]string, 0, len(fields))
+	for eachName := range fields {
+		names = append(names, eachName)
+	}
+	sort.Strings(names)
+	for _, eachName := range names {
+		buffer.WriteString(",")
+		writeLogValue(buffer, eachName)
+		buffer.WriteString(":")
+		writeLogValue(buffer, fields[eachName])
+	}
+	buffer.WriteString("}\n")
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.out.Write(buffer.Bytes())
+}
+
+// writeLogValue write the JSON encoding of the value, errors are written as their message
+func writeLogValue(buffer *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buffer.Write(encoded)
+}
+
+// debug write a debug record
+func (l *logger) debug(message string, fields logFields) {
+	l.log(LOG_LEVEL_DEBUG, message, fields)
+}
+
+// info write an info record
+func (l *logger) info(message string, fields logFields) {
+	l.log(LOG_LEVEL_INFO, message, fields)
+}
+
+// warn write a warn record
+func (l *logger) warn(message string, fields logFields) {
+	l.log(LOG_LEVEL_WARN, message, fields)
+}
+
+// error write an error record
+func (l *logger) error(message string, fields logFields) {
+	l.log(LOG_LEVEL_ERROR, message, fields)
+}
+
+// requestLogContextKey context key of the request log
+type requestLogContextKey struct{}
+
+// requestLog request id and work done while serving a request, reported in the access log, the nested handlers called by GraphQL resolvers report to the same request log
+type requestLog struct {
+	id            string
+	logger        *logger
+	usernames     atomic.Int64
+	cacheHits     atomic.Int64
+	cacheMisses   atomic.Int64
+	upstreamCalls atomic.Int64
+}
+
+// requestLogFromContext return the request log of the context (nil when the request is not served by a server)
+func requestLogFromContext(ctx context.Context) *requestLog {
+	requestLog, _ := ctx.Value(requestLogContextKey{}).(*requestLog)
+	return requestLog
+}
+
+// requestIDFromContext return the request id of the context (empty when the request is not served by a server)
+func requestIDFromContext(ctx context.Context) string {
+	if requestLog := requestLogFromContext(ctx); requestLog != nil {
+		return requestLog.id
+	}
+	return ""
+}
+
+// loggerFromContext return the logger of the server serving the request of the context
+func loggerFromContext(ctx context.Context) *logger {
+	if requestLog := requestLogFromContext(ctx); requestLog != nil {
+		return requestLog.logger
+	}
+	return defaultLogger
+}
+
+// countUsernames count the usernames requested (nil safe)
+func (rl *requestLog) countUsernames(count int) {
+	if rl != nil {
+		rl.usernames.Add(int64(count))
+	}
+}
+
+// countCacheLookup count a cache hit or miss (nil safe)
+func (rl *requestLog) countCacheLookup(hit bool) {
+	if rl == nil {
+		return
+	}
+	if hit {
+		rl.cacheHits.Add(1)
+	} else {
+		rl.cacheMisses.Add(1)
+	}
+}
+
+// countUpstreamCall count a github API call (nil safe)
+func (rl *requestLog) countUpstreamCall() {
+	if rl != nil {
+		rl.upstreamCalls.Add(1)
+	}
+}
+
+// validRequestID check whether the request id received from the client can be propagated: 1 to 128 letters, digits, '-', '_', '.' or ':'
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > REQUEST_ID_MAX_LENGTH {
+		return false
+	}
+	for _, eachChar := range requestID {
+		if !(eachChar >= 'a' && eachChar <= 'z') && !(eachChar >= 'A' && eachChar <= 'Z') && !(eachChar >= '0' && eachChar <= '9') && !strings.ContainsRune("-_.:", eachChar) {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID return a random request id
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		// Still unique enough to correlate the logs of a single server
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// loggedHandler propagate or generate the X-Request-ID of each request of the route, echo it in the response and write an access log once the request is served,
+// the access logs of the metrics, probes, documentation, playground and cluster heartbeat routes are debug records
+func (s *Server) loggedHandler(route string, handler http.Handler) http.Handler {
+	accessLogLevel := LOG_LEVEL_INFO
+	switch route {
+	case "/metrics", "/healthz", "/readyz", "/openapi.json", "/docs", "/graphql/playground", CLUSTER_HEARTBEAT_PATH:
+		accessLogLevel = LOG_LEVEL_DEBUG
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(REQUEST_ID_HEADER)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, requestID)
+		requestLog := &requestLog{
+			id:     requestID,
+			logger: s.logger,
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(attribute.String("request.id", requestID))
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLogContextKey{}, requestLog)))
+
+		statusCode := recorder.statusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		level := accessLogLevel
+		if statusCode >= http.StatusInternalServerError {
+			level = LOG_LEVEL_ERROR
+		}
+		fields := logFields{
+			"request_id":     requestID,
+			"method":         r.Method,
+			"path":           r.URL.Path,
+			"route":          route,
+			"status":         statusCode,
+			"latency_ms":     float64(time.Since(start).Microseconds()) / 1000,
+			"usernames":      requestLog.usernames.Load(),
+			"cache_hits":     requestLog.cacheHits.Load(),
+			"cache_misses":   requestLog.cacheMisses.Load(),
+			"upstream_calls": requestLog.upstreamCalls.Load(),
+		}
+		if span.SpanContext().IsValid() {
+			fields["trace_id"] = span.SpanContext().TraceID().String()
+		}
+		s.logger.log(level, "request served", fields)
+	})
+}
+
+// resultErrorsWithRequestID return a copy of the errors where the errors without request id carry the request id, the errors are not modified since they may be shared
+func resultErrorsWithRequestID(resultErrors []*model.ResultError, requestID string) []*model.ResultError {
+	if requestID == "" {
+		return resultErrors
+	}
+	stampedErrors := make([]*model.ResultError, 0, len(resultErrors))
+	for _, eachError := range resultErrors {
+		if eachError != nil && eachError.RequestID == "" {
+			stampedError := *eachError
+			stampedError.RequestID = requestID
+			eachError = &stampedError
+		}
+		stampedErrors = append(stampedErrors, eachError)
+	}
+	return stampedErrors
+}
+
+// resultWithRequestID return a copy of the result object whose errors carry the request id of the request, other objects are returned unchanged
+func resultWithRequestID(r *http.Request, resultObj interface{}) interface{} {
+	requestID := requestIDFromContext(r.Context())
+	if requestID == "" {
+		return resultObj
+	}
+	switch result := resultObj.(type) {
+	case *model.ResultRetrieveUsers:
+		stampedResult := *result
+		stampedResult.Errors = resultErrorsWithRequestID(result.Errors, requestID)
+		return &stampedResult
+	case *model.ResultRetrieveOrganization:
+		stampedResult := *result
+		stampedResult.Errors = resultErrorsWithRequestID(result.Errors, requestID)
+		return &stampedResult
+	case *model.ResultRetrieveUserConnection:
+		stampedResult := *result
+		stampedResult.Errors = resultErrorsWithRequestID(result.Errors, requestID)
+		return &stampedResult
+	case *model.ResultRelationships:
+		stampedResult := *result
+		stampedResult.Errors = resultErrorsWithRequestID(result.Errors, requestID)
+		return &stampedResult
+	}
+	return resultObj
+}
+
+// presentGraphQLError gqlgen error presenter adding the request id to the extensions of the GraphQL errors
+func presentGraphQLError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if requestID := requestIDFromContext(ctx); requestID != "" {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = make(map[string]interface{})
+		}
+		gqlErr.Extensions["request_id"] = requestID
+	}
+	return gqlErr
+}
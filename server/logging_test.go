@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	tests := map[string]struct {
+		Level           string
+		Write           func(l *logger)
+		ExpectedRecords []string
+	}{
+		"Test fields sorted after message": {
+			Level: LOG_LEVEL_INFO,
+			Write: func(l *logger) {
+				l.info("request served", logFields{"status": 200, "method": "GET", "err": errors.New("failed")})
+			},
+			ExpectedRecords: []string{`"level":"info","msg":"request served","err":"failed","method":"GET","status":200}`},
+		},
+		"Test records below the level are dropped": {
+			Level: LOG_LEVEL_WARN,
+			Write: func(l *logger) {
+				l.debug("debug", nil)
+				l.info("info", nil)
+				l.warn("warn", nil)
+				l.error("error", nil)
+			},
+			ExpectedRecords: []string{`"level":"warn","msg":"warn"}`, `"level":"error","msg":"error"}`},
+		},
+		"Test debug level": {
+			Level: LOG_LEVEL_DEBUG,
+			Write: func(l *logger) {
+				l.debug("debug", logFields{"route": "/metrics"})
+			},
+			ExpectedRecords: []string{`"level":"debug","msg":"debug","route":"/metrics"}`},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			test.Write(newLogger(buffer, test.Level))
+
+			records := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+			if buffer.Len() == 0 {
+				records = nil
+			}
+			if len(records) != len(test.ExpectedRecords) {
+				t.Fatalf("expected %v records, got %q", len(test.ExpectedRecords), buffer.String())
+			}
+			for i, eachRecord := range records {
+				if !json.Valid([]byte(eachRecord)) {
+					t.Errorf("expected JSON record, got %s", eachRecord)
+				}
+				if !strings.HasPrefix(eachRecord, `{"time":"`) || !strings.HasSuffix(eachRecord, test.ExpectedRecords[i]) {
+					t.Errorf("expected record ending with %s, got %s", test.ExpectedRecords[i], eachRecord)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]struct {
+		Name          string
+		ExpectedLevel string
+		ExpectedError bool
+	}{
+		"Test lower case": {Name: "debug", ExpectedLevel: LOG_LEVEL_DEBUG},
+		"Test upper case": {Name: "WARN", ExpectedLevel: LOG_LEVEL_WARN},
+		"Test unknown":    {Name: "verbose", ExpectedError: true},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			level, err := ParseLogLevel(test.Name)
+			if (err != nil) != test.ExpectedError {
+				t.Fatalf("expected error %v, got %v", test.ExpectedError, err)
+			}
+			if level != test.ExpectedLevel {
+				t.Errorf("expected level %q, got %q", test.ExpectedLevel, level)
+			}
+		})
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	tests := map[string]struct {
+		Target              string
+		RequestID           string
+		ExpectedStatusCode  int
+		ExpectedRequestID   string
+		ExpectedErrorsField string
+		ExpectedAccessLog   map[string]interface{}
+	}{
+		"Test propagated request id": {
+			Target:             "/v1/retrieveUsers?usernames=a,b",
+			RequestID:          "client-request.1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedRequestID:  "client-request.1",
+			ExpectedAccessLog: map[string]interface{}{
+				"route":          "/v1/retrieveUsers",
+				"status":         float64(http.StatusOK),
+				"usernames":      float64(2),
+				"cache_misses":   float64(2),
+				"upstream_calls": float64(2),
+			},
+		},
+		"Test generated request id": {
+			Target:             "/v1/retrieveUsers?usernames=a",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		"Test invalid request id is replaced": {
+			Target:             "/v1/retrieveUsers?usernames=a",
+			RequestID:          "invalid id\"",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		"Test request id in partial errors": {
+			Target:              "/v1/retrieveUsers?usernames=a,notfound",
+			RequestID:           "partial",
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedRequestID:   "partial",
+			ExpectedErrorsField: "errors",
+		},
+		"Test request id in problem": {
+			Target:              "/v1/retrieveUsers?usernames=notfound",
+			RequestID:           "problem",
+			ExpectedStatusCode:  http.StatusNotFound,
+			ExpectedRequestID:   "problem",
+			ExpectedErrorsField: "errors",
+			ExpectedAccessLog: map[string]interface{}{
+				"status":         float64(http.StatusNotFound),
+				"usernames":      float64(1),
+				"upstream_calls": float64(1),
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newCacheTestServer()
+			defer githubAPITestServer.Close()
+
+			logBuffer := &bytes.Buffer{}
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+			s.logger = newLogger(logBuffer, LOG_LEVEL_INFO)
+			s.registerHandlers()
+
+			request := httptest.NewRequest(http.MethodGet, test.Target, nil)
+			if test.RequestID != "" {
+				request.Header.Set(REQUEST_ID_HEADER, test.RequestID)
+			}
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Fatalf("expected status %v, got %v", test.ExpectedStatusCode, responseRecorder.Code)
+			}
+
+			requestID := responseRecorder.Header().Get(REQUEST_ID_HEADER)
+			if test.ExpectedRequestID != "" && requestID != test.ExpectedRequestID {
+				t.Errorf("expected request id %q, got %q", test.ExpectedRequestID, requestID)
+			}
+			if !validRequestID(requestID) || (test.ExpectedRequestID == "" && requestID == test.RequestID) {
+				t.Errorf("expected a generated request id, got %q", requestID)
+			}
+
+			if test.ExpectedErrorsField != "" {
+				body := make(map[string]json.RawMessage)
+				json.Unmarshal(responseRecorder.Body.Bytes(), &body)
+				resultErrors := make([]map[string]string, 0)
+				json.Unmarshal(body[test.ExpectedErrorsField], &resultErrors)
+				if len(resultErrors) == 0 {
+					t.Fatalf("expected errors, got %s", responseRecorder.Body.String())
+				}
+				for _, eachError := range resultErrors {
+					if eachError["request_id"] != requestID {
+						t.Errorf("expected error with request id %q, got %v", requestID, eachError)
+					}
+				}
+			}
+
+			// One access log record for the request
+			accessLogs := make([]map[string]interface{}, 0)
+			scanner := bufio.NewScanner(logBuffer)
+			for scanner.Scan() {
+				record := make(map[string]interface{})
+				if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+					t.Fatalf("expected JSON log record, got %s", scanner.Text())
+				}
+				if record["msg"] == "request served" {
+					accessLogs = append(accessLogs, record)
+				}
+			}
+			if len(accessLogs) != 1 {
+				t.Fatalf("expected 1 access log, got %v", accessLogs)
+			}
+			if accessLogs[0]["request_id"] != requestID || accessLogs[0]["method"] != http.MethodGet {
+				t.Errorf("expected access log of request %q, got %v", requestID, accessLogs[0])
+			}
+			for eachField, eachValue := range test.ExpectedAccessLog {
+				if accessLogs[0][eachField] != eachValue {
+					t.Errorf("expected access log %v %v, got %v", eachField, eachValue, accessLogs[0][eachField])
+				}
+			}
+		})
+	}
+}
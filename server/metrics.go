@@ -0,0 +1,361 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+const (
+	// METRICS_CONTENT_TYPE content type of the Prometheus text exposition format
+	METRICS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// latencyBuckets upper bounds in seconds of the latency histograms
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricLabels label values of a series, in the order of the label names of its metric
+type metricLabels []string
+
+// key return the key of the series in its metric
+func (l metricLabels) key() string {
+	return strings.Join(l, "\xff")
+}
+
+// counterVec counter metric with one series per label values
+type counterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	lock       *sync.Mutex
+	series     map[string]*counterSeries
+}
+
+// counterSeries a series of a counter metric
+type counterSeries struct {
+	labels metricLabels
+	value  float64
+}
+
+// newCounterVec return a new counter metric
+func newCounterVec(name string, help string, labelNames ...string) *counterVec {
+	return &counterVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		lock:       &sync.Mutex{},
+		series:     make(map[string]*counterSeries),
+	}
+}
+
+// inc increment the series of the label values
+func (c *counterVec) inc(labels ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := metricLabels(labels).key()
+	series, found := c.series[key]
+	if !found {
+		series = &counterSeries{labels: labels}
+		c.series[key] = series
+	}
+	series.value++
+}
+
+// write write the metric in the text exposition format
+func (c *counterVec) write(w io.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	writeMetricHeader(w, c.name, c.help, "counter")
+	for _, eachKey := range sortedSeriesKeys(c.series) {
+		series := c.series[eachKey]
+		writeSample(w, c.name, c.labelNames, series.labels, series.value)
+	}
+}
+
+// histogramVec histogram metric with one series per label values
+type histogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	lock       *sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+// histogramSeries a series of a histogram metric, counts are per bucket (not cumulative)
+type histogramSeries struct {
+	labels metricLabels
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// newHistogramVec return a new histogram metric
+func newHistogramVec(name string, help string, buckets []float64, labelNames ...string) *histogramVec {
+	return &histogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    buckets,
+		lock:       &sync.Mutex{},
+		series:     make(map[string]*histogramSeries),
+	}
+}
+
+// observe add the value to the series of the label values
+func (h *histogramVec) observe(value float64, labels ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	key := metricLabels(labels).key()
+	series, found := h.series[key]
+	if !found {
+		series = &histogramSeries{labels: labels, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, eachBucket := range h.buckets {
+		if value <= eachBucket {
+			series.counts[i]++
+			break
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+// write write the metric in the text exposition format, buckets are cumulative
+func (h *histogramVec) write(w io.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	writeMetricHeader(w, h.name, h.help, "histogram")
+	bucketLabelNames := append(append([]string{}, h.labelNames...), "le")
+	for _, eachKey := range sortedSeriesKeys(h.series) {
+		series := h.series[eachKey]
+		cumulativeCount := uint64(0)
+		for i, eachBucket := range h.buckets {
+			cumulativeCount += series.counts[i]
+			writeSample(w, h.name+"_bucket", bucketLabelNames, append(append(metricLabels{}, series.labels...), formatMetricValue(eachBucket)), float64(cumulativeCount))
+		}
+		writeSample(w, h.name+"_bucket", bucketLabelNames, append(append(metricLabels{}, series.labels...), "+Inf"), float64(series.count))
+		writeSample(w, h.name+"_sum", h.labelNames, series.labels, series.sum)
+		writeSample(w, h.name+"_count", h.labelNames, series.labels, float64(series.count))
+	}
+}
+
+// sortedSeriesKeys return the keys of the series sorted, so the output is stable between scrapes
+func sortedSeriesKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for eachKey := range series {
+		keys = append(keys, eachKey)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeMetricHeader write the HELP and TYPE lines of a metric
+func writeMetricHeader(w io.Writer, name string, help string, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// writeSample write a sample line, label values are escaped
+func writeSample(w io.Writer, name string, labelNames []string, labels metricLabels, value float64) {
+	io.WriteString(w, name)
+	if len(labelNames) > 0 {
+		escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+		pairs := make([]string, 0, len(labelNames))
+		for i, eachName := range labelNames {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, eachName, escaper.Replace(labels[i])))
+		}
+		fmt.Fprintf(w, "{%s}", strings.Join(pairs, ","))
+	}
+	fmt.Fprintf(w, " %s\n", formatMetricValue(value))
+}
+
+// formatMetricValue format a sample value or a bucket bound
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// serverMetrics metrics of the server exposed on /metrics
+type serverMetrics struct {
+	httpRequests          *counterVec
+	httpRequestDuration   *histogramVec
+	githubRequests        *counterVec
+	githubRequestDuration *histogramVec
+	graphQLOperations     *counterVec
+}
+
+// newServerMetrics return the metrics of a new server
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		httpRequests:          newCounterVec("http_requests_total", "HTTP requests served by route, method and status.", "route", "method", "status"),
+		httpRequestDuration:   newHistogramVec("http_request_duration_seconds", "Duration of the HTTP requests served by route.", latencyBuckets, "route"),
+		githubRequests:        newCounterVec("github_requests_total", "Github API calls by rate limit resource and status, rate_limited when the call is not sent because the bucket is exhausted.", "resource", "status"),
+		githubRequestDuration: newHistogramVec("github_request_duration_seconds", "Latency of the github API calls by rate limit resource.", latencyBuckets, "resource"),
+		graphQLOperations:     newCounterVec("graphql_operations_total", "GraphQL operations by type and name.", "type", "name"),
+	}
+}
+
+// statusRecorder response writer recording the status of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// WriteHeader comply with http.ResponseWriter interface
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write comply with http.ResponseWriter interface
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush comply with http.Flusher interface so streams can be flushed through the recorder
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap return the underlying response writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// instrumentedHandler count the requests served by the handler of the route and observe their duration
+func (s *Server) instrumentedHandler(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+
+		statusCode := recorder.statusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		s.metrics.httpRequests.inc(route, r.Method, strconv.Itoa(statusCode))
+		s.metrics.httpRequestDuration.observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// observeGithubRequest count a github API call and observe its latency, status is the HTTP status or the reason the call failed
+func (s *Server) observeGithubRequest(resource string, status string, duration time.Duration) {
+	s.metrics.githubRequests.inc(resource, status)
+	if duration > 0 {
+		s.metrics.githubRequestDuration.observe(duration.Seconds(), resource)
+	}
+}
+
+// countGraphQLOperation gqlgen operation middleware counting the GraphQL operations by type and name
+func (s *Server) countGraphQLOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	operationContext := graphql.GetOperationContext(ctx)
+	operationType := "unknown"
+	if operationContext.Operation != nil {
+		operationType = string(operationContext.Operation.Operation)
+	}
+	operationName := operationContext.OperationName
+	if operationName == "" && operationContext.Operation != nil {
+		operationName = operationContext.Operation.Name
+	}
+	if operationName == "" {
+		operationName = "anonymous"
+	}
+	s.metrics.graphQLOperations.inc(operationType, operationName)
+	return next(ctx)
+}
+
+const (
+	// CACHE_USER_INFO name of the user profile cache in the metrics and spans
+	CACHE_USER_INFO = "user_info"
+	// CACHE_ORG_INFO name of the organization cache in the metrics and spans
+	CACHE_ORG_INFO = "org_info"
+	// CACHE_ORG_MEMBERS name of the organization members cache in the metrics and spans
+	CACHE_ORG_MEMBERS = "org_members"
+	// CACHE_FOLLOW_LIST name of the followers/following cache in the metrics and spans
+	CACHE_FOLLOW_LIST = "follow_list"
+)
+
+// cacheMetric a cache of the server exposed in the metrics
+type cacheMetric struct {
+	Name  string
+	Stats func() []ServerCachePartitionStats
+}
+
+// cacheMetrics return the caches of the server exposed in the metrics
+func (s *Server) cacheMetrics() []cacheMetric {
+	return []cacheMetric{
+		{Name: CACHE_USER_INFO, Stats: s.githubUserInfoCache.Stats},
+		{Name: CACHE_ORG_INFO, Stats: s.githubOrgInfoCache.Stats},
+		{Name: CACHE_ORG_MEMBERS, Stats: s.githubOrgMembersCache.Stats},
+		{Name: CACHE_FOLLOW_LIST, Stats: s.githubFollowListCache.Stats},
+	}
+}
+
+// writeCacheMetrics write the hits, misses, backend errors and size of each cache partition
+func (s *Server) writeCacheMetrics(w io.Writer) {
+	labelNames := []string{"cache", "partition"}
+	caches := s.cacheMetrics()
+	metrics := []struct {
+		Name  string
+		Help  string
+		Type  string
+		Value func(stats ServerCachePartitionStats) float64
+		Skip  func(stats ServerCachePartitionStats) bool // partitions without sample, nil when every partition has one
+	}{
+		{Name: "cache_hits_total", Help: "Cache lookups finding a fresh value by cache and partition.", Type: "counter", Value: func(stats ServerCachePartitionStats) float64 { return float64(stats.Hits) }},
+		{Name: "cache_misses_total", Help: "Cache lookups finding no fresh value by cache and partition.", Type: "counter", Value: func(stats ServerCachePartitionStats) float64 { return float64(stats.Misses) }},
+		{Name: "cache_backend_errors_total", Help: "Cache backend operations failing by cache and partition.", Type: "counter", Value: func(stats ServerCachePartitionStats) float64 { return float64(stats.Errors) }},
+		{Name: "cache_entries", Help: "Entries stored (including the expired ones not overwritten yet) by cache and partition.", Type: "gauge", Value: func(stats ServerCachePartitionStats) float64 { return float64(stats.Size) }, Skip: func(stats ServerCachePartitionStats) bool { return !stats.Sized }},
+	}
+
+	partitionStats := make([][]ServerCachePartitionStats, len(caches))
+	for i, eachCache := range caches {
+		partitionStats[i] = eachCache.Stats()
+	}
+	for _, eachMetric := range metrics {
+		writeMetricHeader(w, eachMetric.Name, eachMetric.Help, eachMetric.Type)
+		for i, eachCache := range caches {
+			for _, eachStats := range partitionStats[i] {
+				if eachMetric.Skip != nil && eachMetric.Skip(eachStats) {
+					continue
+				}
+				writeSample(w, eachMetric.Name, labelNames, metricLabels{eachCache.Name, eachStats.ID}, eachMetric.Value(eachStats))
+			}
+		}
+	}
+}
+
+// serveMetrics handling serving the metrics in the Prometheus text exposition format
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	w.Header().Set("Content-Type", METRICS_CONTENT_TYPE)
+	w.WriteHeader(http.StatusOK)
+
+	bufferedWriter := bufio.NewWriter(w)
+	s.metrics.httpRequests.write(bufferedWriter)
+	s.metrics.httpRequestDuration.write(bufferedWriter)
+	s.metrics.githubRequests.write(bufferedWriter)
+	s.metrics.githubRequestDuration.write(bufferedWriter)
+	s.metrics.graphQLOperations.write(bufferedWriter)
+	s.writeCacheMetrics(bufferedWriter)
+	bufferedWriter.Flush()
+}
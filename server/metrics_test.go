@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	counter := newCounterVec("requests_total", "Requests.", "route", "status")
+	counter.inc("/b", "200")
+	counter.inc("/a", "200")
+	counter.inc("/a", "200")
+	counter.inc("/\"quoted\"\\", "500")
+	histogram := newHistogramVec("duration_seconds", "Duration.", []float64{0.1, 1}, "route")
+	histogram.observe(0.05, "/a")
+	histogram.observe(0.5, "/a")
+	histogram.observe(5, "/a")
+
+	tests := map[string]struct {
+		Write    func(buffer *bytes.Buffer)
+		Expected string
+	}{
+		"Test counter": {
+			Write: func(buffer *bytes.Buffer) { counter.write(buffer) },
+			Expected: `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/\"quoted\"\\",status="500"} 1
+requests_total{route="/a",status="200"} 2
+requests_total{route="/b",status="200"} 1
+`,
+		},
+		"Test histogram": {
+			Write: func(buffer *bytes.Buffer) { histogram.write(buffer) },
+			Expected: `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/a",le="0.1"} 1
+duration_seconds_bucket{route="/a",le="1"} 2
+duration_seconds_bucket{route="/a",le="+Inf"} 3
+duration_seconds_sum{route="/a"} 5.55
+duration_seconds_count{route="/a"} 3
+`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			test.Write(buffer)
+			if buffer.String() != test.Expected {
+				t.Errorf("expected\n%s\ngot\n%s", test.Expected, buffer.String())
+			}
+		})
+	}
+}
+
+// metricSum return the sum of the samples of the metric whose labels contain the label filter
+func metricSum(t *testing.T, metrics string, name string, labelFilter string) float64 {
+	sum := 0.0
+	scanner := bufio.NewScanner(strings.NewReader(metrics))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, name+"{") || !strings.Contains(line, labelFilter) {
+			continue
+		}
+		value, err := strconv.ParseFloat(line[strings.LastIndex(line, " ")+1:], 64)
+		if err != nil {
+			t.Fatalf("expected numeric sample, got %q", line)
+		}
+		sum += value
+	}
+	return sum
+}
+
+func TestServeMetrics(t *testing.T) {
+	githubAPITestServer := newCacheTestServer()
+	defer githubAPITestServer.Close()
+
+	s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+	s.registerHandlers()
+	for _, eachTarget := range []string{"/v1/retrieveUsers?usernames=a", "/v1/retrieveUsers?usernames=a", "/v1/retrieveUsers?usernames=notfound"} {
+		s.serverMux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, eachTarget, nil))
+	}
+	graphQLRequest := httptest.NewRequest(http.MethodPost, "/graphql/query", strings.NewReader(`{"query":"query Users {retrieveUsers(usernames:[\"a\"]){users{login}}}"}`))
+	graphQLRequest.Header.Set("Content-Type", "application/json")
+	s.serverMux.ServeHTTP(httptest.NewRecorder(), graphQLRequest)
+
+	responseRecorder := httptest.NewRecorder()
+	s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if contentType := responseRecorder.Header().Get("Content-Type"); contentType != METRICS_CONTENT_TYPE {
+		t.Errorf("expected content type %v, got %v", METRICS_CONTENT_TYPE, contentType)
+	}
+
+	metrics := responseRecorder.Body.String()
+	tests := map[string]struct {
+		Name        string
+		LabelFilter string
+		Expected    float64
+	}{
+		"Test HTTP requests": {
+			Name:        "http_requests_total",
+			LabelFilter: `route="/v1/retrieveUsers",method="GET",status="200"`,
+			Expected:    2,
+		},
+		"Test HTTP not found": {
+			Name:        "http_requests_total",
+			LabelFilter: `route="/v1/retrieveUsers",method="GET",status="404"`,
+			Expected:    1,
+		},
+		"Test HTTP duration": {
+			Name:        "http_request_duration_seconds_count",
+			LabelFilter: `route="/v1/retrieveUsers"`,
+			Expected:    3,
+		},
+		"Test github requests": {
+			Name:        "github_requests_total",
+			LabelFilter: `resource="core",status="200"`,
+			Expected:    1,
+		},
+		"Test github not found": {
+			Name:        "github_requests_total",
+			LabelFilter: `resource="core",status="404"`,
+			Expected:    1,
+		},
+		"Test github latency": {
+			Name:        "github_request_duration_seconds_count",
+			LabelFilter: `resource="core"`,
+			Expected:    2,
+		},
+		"Test graphql operations": {
+			Name:        "graphql_operations_total",
+			LabelFilter: `type="query",name="Users"`,
+			Expected:    1,
+		},
+		"Test cache hits": {
+			Name:        "cache_hits_total",
+			LabelFilter: `cache="user_info"`,
+			Expected:    2,
+		},
+		"Test cache misses": {
+			Name:        "cache_misses_total",
+			LabelFilter: `cache="user_info"`,
+			Expected:    2,
+		},
+		"Test cache entries": {
+			// Not found users are cached too
+			Name:        "cache_entries",
+			LabelFilter: `cache="user_info"`,
+			Expected:    2,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if result := metricSum(t, metrics, test.Name, test.LabelFilter); result != test.Expected {
+				t.Errorf("expected %v{%v} %v, got %v", test.Name, test.LabelFilter, test.Expected, result)
+			}
+		})
+	}
+
+	// Every partition of every cache is exposed
+	if partitions := strings.Count(metrics, "cache_entries{"); partitions != 4*s.config.defaultCachePartitions {
+		t.Errorf("expected %v cache partitions, got %v", 4*s.config.defaultCachePartitions, partitions)
+	}
+}
@@ -0,0 +1,441 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// OPENAPI_VERSION version of the OpenAPI specification the document conforms to
+	OPENAPI_VERSION = "3.0.3"
+	// API_VERSION version of the REST API described by the document
+	API_VERSION = "1.0.0"
+	// SWAGGER_UI_VERSION version of swagger-ui-dist loaded by the docs UI
+	SWAGGER_UI_VERSION = "5.17.14"
+)
+
+// openAPIDocument root of an OpenAPI 3 document
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       *openAPIInfo                            `json:"info"`
+	Servers    []*openAPIServer                        `json:"servers"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components *openAPIComponents                      `json:"components"`
+}
+
+// openAPIInfo metadata of the API
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// openAPIServer base URL of the paths
+type openAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// openAPIComponents reusable schemas referenced by the operations
+type openAPIComponents struct {
+	Schemas openAPISchemas `json:"schemas"`
+}
+
+// openAPIOperation an operation (HTTP method) on a path
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+// openAPIParameter a path or query parameter of an operation
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Style       string         `json:"style,omitempty"`
+	Explode     *bool          `json:"explode,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+// openAPIRequestBody body of an operation by content type
+type openAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+// openAPIResponse a response of an operation by content type
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIMediaType schema of a content type
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+// openAPISchema subset of the OpenAPI schema object used by the document
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Default              interface{}               `json:"default,omitempty"`
+	Minimum              *int                      `json:"minimum,omitempty"`
+	Maximum              *int                      `json:"maximum,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// openAPISchemas component schemas by name
+type openAPISchemas map[string]*openAPISchema
+
+// schemaOf return the schema of the Go type as encoded by encoding/json, named structs are added to the components and referenced
+func (schemas openAPISchemas) schemaOf(t reflect.Type) *openAPISchema {
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemas.schemaOf(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: schemas.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: schemas.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, found := schemas[name]; !found {
+			// Register before walking the fields so recursive types terminate
+			schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+			schemas[name] = schema
+			schemas.addProperties(schema, t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	default:
+		return &openAPISchema{}
+	}
+}
+
+// addProperties add the JSON fields of the struct to the object schema, fields without omitempty are required
+func (schemas openAPISchemas) addProperties(schema *openAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" {
+			schemas.addProperties(schema, field.Type)
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = schemas.schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// openAPIDocument return the OpenAPI document describing the REST routes of the version 1
+func (s *Server) openAPIDocument() *openAPIDocument {
+	schemas := make(openAPISchemas)
+	jsonContent := func(value interface{}) map[string]*openAPIMediaType {
+		return map[string]*openAPIMediaType{
+			"application/json": {Schema: schemas.schemaOf(reflect.TypeOf(value))},
+		}
+	}
+	problem := func(description string) *openAPIResponse {
+		return &openAPIResponse{
+			Description: description,
+			Content: map[string]*openAPIMediaType{
+				PROBLEM_CONTENT_TYPE: {Schema: schemas.schemaOf(reflect.TypeOf(problemDetails{}))},
+			},
+		}
+	}
+	minPageSize, maxPageSize := 1, MAX_PAGE_SIZE
+	pageParameters := []*openAPIParameter{
+		queryParameter("first", "Number of users in the page", &openAPISchema{Type: "integer", Minimum: &minPageSize, Maximum: &maxPageSize, Default: DEFAULT_PAGE_SIZE}),
+		queryParameter("after", "end_cursor of the previous page", &openAPISchema{Type: "string"}),
+	}
+
+	usersResultSchema := schemas.schemaOf(reflect.TypeOf(model.ResultRetrieveUsers{}))
+	streamFormats := []string{STREAM_MODE_SSE}
+	userFieldsSchema := &openAPISchema{Type: "string", Enum: userFields}
+
+	paths := map[string]map[string]*openAPIOperation{
+		"/retrieveUsers": {
+			"get": {
+				OperationID: "retrieveUsers",
+				Summary:     "Retrieve github users by their logins",
+				Description: "Users are sorted by name. Partial results are returned with 200: the usernames that are not found or fail to be fetched are reported in errors while the other users are still returned. " +
+					"When no user can be returned the whole request fails with a problem (the errors extension list the failed usernames): 404 when every username is not found, 502 when github fails and 503 (with Retry-After) when the rate limit is exhausted. " +
+					"Streaming responses always start with 200. " +
+					"Responses carry an ETag, Last-Modified and a Cache-Control max-age of the remaining cache TTL of the users (no-cache when some usernames failed), If-None-Match is answered with 304.",
+				Parameters: []*openAPIParameter{
+					commaSeparatedQueryParameter("usernames", fmt.Sprintf("Comma separated logins, %sNAME is expanded into the public members of the organization", ORGANIZATION_USERNAME_PREFIX), &openAPISchema{Type: "string"}),
+					queryParameter("includeRepoStats", "Compute repository statistics of each user", &openAPISchema{Type: "boolean", Default: false}),
+					queryParameter("format", "Response format, the Accept header is used when not set", &openAPISchema{Type: "string", Enum: append(supportedResponseFormats(), streamFormats...)}),
+					queryParameter("stream", "Stream each user or error as soon as it is resolved (NDJSON, or Server-Sent Events with format=sse)", &openAPISchema{Type: "boolean", Default: false}),
+					commaSeparatedQueryParameter("fields", "Comma separated user fields to return (json, json-compact and csv only)", userFieldsSchema),
+				},
+				Responses: map[string]*openAPIResponse{
+					"200": {
+						Description: "Users and errors, only the selected fields of each user are returned when fields is set",
+						Content: map[string]*openAPIMediaType{
+							"application/json":     {Schema: usersResultSchema},
+							"application/yaml":     {Schema: usersResultSchema},
+							"application/xml":      {Schema: usersResultSchema},
+							"text/csv":             {Schema: &openAPISchema{Type: "string"}},
+							"application/x-ndjson": {Schema: &openAPISchema{Type: "string"}},
+							"text/event-stream":    {Schema: &openAPISchema{Type: "string"}},
+						},
+					},
+					"304": {Description: "Not modified, If-None-Match matches the ETag of the response"},
+					"400": problem("Invalid username, includeRepoStats or field, or unsupported format"),
+					"404": problem("Every username is not found"),
+					"405": problem("Method not allowed, the Allow header list the allowed methods"),
+					"406": problem("None of the accepted media types is supported"),
+					"502": problem("Github failed for every username"),
+					"503": problem("Github rate limit is exhausted, retry after the Retry-After header"),
+				},
+			},
+		},
+		"/orgs/{org}": {
+			"get": {
+				OperationID: "retrieveOrganization",
+				Summary:     "Retrieve a github organization",
+				Parameters: []*openAPIParameter{
+					pathParameter("org", "Login of the organization", &openAPISchema{Type: "string"}),
+				},
+				Responses: map[string]*openAPIResponse{
+					"200": {Description: "Organization, or errors when it is not found", Content: jsonContent(model.ResultRetrieveOrganization{})},
+				},
+			},
+		},
+		"/users/{login}/{relation}": {
+			"get": {
+				OperationID: "retrieveUserConnection",
+				Summary:     "Page through the followers or following of a user",
+				Parameters: append([]*openAPIParameter{
+					pathParameter("login", "Login of the user", &openAPISchema{Type: "string"}),
+					pathParameter("relation", "Followers or following", &openAPISchema{Type: "string", Enum: []string{FOLLOW_RELATION_FOLLOWERS, FOLLOW_RELATION_FOLLOWING}}),
+				}, pageParameters...),
+				Responses: map[string]*openAPIResponse{
+					"200": {Description: "Page of users, or errors", Content: jsonContent(model.ResultRetrieveUserConnection{})},
+				},
+			},
+		},
+		"/relationships": {
+			"get": {
+				OperationID: "relationships",
+				Summary:     "Compute mutual follows, common followers and common following between logins",
+				Parameters: []*openAPIParameter{
+					commaSeparatedQueryParameter("logins", "Comma separated logins (at least 2)", &openAPISchema{Type: "string"}),
+				},
+				Responses: map[string]*openAPIResponse{
+					"200": {Description: "Relationships, or errors", Content: jsonContent(model.ResultRelationships{})},
+				},
+			},
+		},
+		"/search/users": {
+			"get": {
+				OperationID: "searchUsers",
+				Summary:     "Search github users",
+				Parameters: append([]*openAPIParameter{
+					queryParameter("query", "Free text query", &openAPISchema{Type: "string"}),
+					queryParameter("location", "Location qualifier", &openAPISchema{Type: "string"}),
+					queryParameter("language", "Language qualifier", &openAPISchema{Type: "string"}),
+					queryParameter("minFollowers", "Minimum number of followers", &openAPISchema{Type: "integer"}),
+				}, pageParameters...),
+				Responses: map[string]*openAPIResponse{
+					"200": {Description: "Page of users, or errors", Content: jsonContent(model.ResultRetrieveUserConnection{})},
+				},
+			},
+		},
+		"/jobs": {
+			"post": {
+				OperationID: "submitJob",
+				Summary:     "Submit a batch job fetching a large username list in the background",
+				RequestBody: &openAPIRequestBody{
+					Required: true,
+					Content: map[string]*openAPIMediaType{
+						"application/json": {Schema: schemas.schemaOf(reflect.TypeOf(jobRequest{}))},
+						"application/x-www-form-urlencoded": {Schema: &openAPISchema{
+							Type: "object",
+							Properties: map[string]*openAPISchema{
+								"usernames":        {Type: "string", Description: "Comma separated logins"},
+								"includeRepoStats": {Type: "boolean"},
+							},
+							Required: []string{"usernames"},
+						}},
+					},
+				},
+				Responses: map[string]*openAPIResponse{
+					"202": {Description: "Job is queued, the Location header points to the job", Content: jsonContent(jobStatus{})},
+					"400": problem("No usernames, too many usernames or invalid username"),
+					"413": problem("Request body too large"),
+					"405": problem("Method not allowed"),
+					"503": problem("Job queue is full"),
+				},
+			},
+		},
+		"/jobs/{id}": {
+			"get": {
+				OperationID: "retrieveJob",
+				Summary:     "Retrieve the progress of a batch job",
+				Parameters: []*openAPIParameter{
+					pathParameter("id", "Id of the job", &openAPISchema{Type: "string"}),
+				},
+				Responses: map[string]*openAPIResponse{
+					"200": {Description: "Progress of the job", Content: jsonContent(jobStatus{})},
+					"404": problem("Job not found"),
+					"405": problem("Method not allowed"),
+				},
+			},
+		},
+		"/jobs/{id}/result": {
+			"get": {
+				OperationID: "retrieveJobResult",
+				Summary:     "Retrieve the result of a completed batch job",
+				Parameters: []*openAPIParameter{
+					pathParameter("id", "Id of the job", &openAPISchema{Type: "string"}),
+				},
+				Responses: map[string]*openAPIResponse{
+					"200": {Description: "Users and errors", Content: jsonContent(model.ResultRetrieveUsers{})},
+					"202": {Description: "Job is not completed yet, progress of the job", Content: jsonContent(jobStatus{})},
+					"404": problem("Job not found"),
+					"405": problem("Method not allowed"),
+				},
+			},
+		},
+	}
+
+	// Every JSON response is compact unless pretty is set
+	for _, eachOperations := range paths {
+		for _, eachOperation := range eachOperations {
+			eachOperation.Parameters = append(eachOperation.Parameters, queryParameter("pretty", "Indent the JSON response", &openAPISchema{Type: "boolean", Default: false}))
+		}
+	}
+
+	return &openAPIDocument{
+		OpenAPI: OPENAPI_VERSION,
+		Info: &openAPIInfo{
+			Title:       "Machship Github API",
+			Description: "Retrieve github users, organizations and relationships, cached in front of the github API",
+			Version:     API_VERSION,
+		},
+		Servers: []*openAPIServer{
+			{URL: API_PREFIX_V1, Description: "Version 1, the unversioned routes are deprecated aliases"},
+		},
+		Paths: paths,
+		Components: &openAPIComponents{
+			Schemas: schemas,
+		},
+	}
+}
+
+// queryParameter return an optional query parameter
+func queryParameter(name string, description string, schema *openAPISchema) *openAPIParameter {
+	return &openAPIParameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      schema,
+	}
+}
+
+// commaSeparatedQueryParameter return an optional query parameter holding a comma separated list of items
+func commaSeparatedQueryParameter(name string, description string, itemSchema *openAPISchema) *openAPIParameter {
+	explode := false
+	return &openAPIParameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Style:       "form",
+		Explode:     &explode,
+		Schema:      &openAPISchema{Type: "array", Items: itemSchema},
+	}
+}
+
+// pathParameter return a path parameter (always required)
+func pathParameter(name string, description string, schema *openAPISchema) *openAPIParameter {
+	return &openAPIParameter{
+		Name:        name,
+		In:          "path",
+		Description: description,
+		Required:    true,
+		Schema:      schema,
+	}
+}
+
+// openAPI handling serving the OpenAPI document
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, r, s.openAPIDocument())
+}
+
+// openAPIDocs handling serving the docs UI rendering the OpenAPI document
+func (s *Server) openAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, openAPIDocsPage, SWAGGER_UI_VERSION, SWAGGER_UI_VERSION, "/openapi.json")
+}
+
+// openAPIDocsPage Swagger UI page, the assets are loaded from the CDN like the GraphQL playground
+const openAPIDocsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Machship Github API</title>
+  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@%s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@%s/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function() {
+      window.ui = SwaggerUIBundle({ url: %q, dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// handlerFormValues return the query parameters read with FormValue by each function of the package, including the functions it calls
+func handlerFormValues(t *testing.T) map[string]map[string]bool {
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("expected no error when parsing package, got %v", err)
+	}
+
+	formValues := make(map[string]map[string]bool)
+	calls := make(map[string]map[string]bool)
+	for _, eachFile := range packages["server"].Files {
+		for _, eachDecl := range eachFile.Decls {
+			funcDecl, ok := eachDecl.(*ast.FuncDecl)
+			if !ok || funcDecl.Body == nil {
+				continue
+			}
+			name := funcDecl.Name.Name
+			formValues[name] = make(map[string]bool)
+			calls[name] = make(map[string]bool)
+			ast.Inspect(funcDecl.Body, func(node ast.Node) bool {
+				callExpr, ok := node.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				switch fun := callExpr.Fun.(type) {
+				case *ast.Ident:
+					calls[name][fun.Name] = true
+				case *ast.SelectorExpr:
+					calls[name][fun.Sel.Name] = true
+					if fun.Sel.Name == "FormValue" && len(callExpr.Args) == 1 {
+						if literal, ok := callExpr.Args[0].(*ast.BasicLit); ok && literal.Kind == token.STRING {
+							value, _ := strconv.Unquote(literal.Value)
+							formValues[name][value] = true
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	// Include the query parameters read by the called functions
+	var collect func(name string, visited map[string]bool, result map[string]bool)
+	collect = func(name string, visited map[string]bool, result map[string]bool) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for eachValue := range formValues[name] {
+			result[eachValue] = true
+		}
+		for eachCall := range calls[name] {
+			if _, found := formValues[eachCall]; found {
+				collect(eachCall, visited, result)
+			}
+		}
+	}
+	handlerValues := make(map[string]map[string]bool)
+	for eachName := range formValues {
+		handlerValues[eachName] = make(map[string]bool)
+		collect(eachName, make(map[string]bool), handlerValues[eachName])
+	}
+	return handlerValues
+}
+
+// handlerName return the name of the handler method
+func handlerName(handler http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	return strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+}
+
+// routeMatchesPath check whether the path of the OpenAPI document is served by the route pattern
+func routeMatchesPath(pattern string, path string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern) && len(path) > len(pattern)
+	}
+	return pattern == path
+}
+
+// sortedKeys return the keys of the set sorted
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for eachKey := range set {
+		keys = append(keys, eachKey)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestOpenAPIRoutesMatchHandlers(t *testing.T) {
+	s := NewServer(NewServerConfig("", 8777, "http://localhost", "users"))
+	document := s.openAPIDocument()
+	formValues := handlerFormValues(t)
+
+	servedPaths := make(map[string]bool)
+	for _, eachRoute := range s.v1Routes() {
+		name := handlerName(eachRoute.Handler)
+		documentedValues := make(map[string]bool)
+		matched := false
+		for eachPath, eachOperations := range document.Paths {
+			if !routeMatchesPath(eachRoute.Pattern, eachPath) {
+				continue
+			}
+			matched = true
+			servedPaths[eachPath] = true
+			for _, eachOperation := range eachOperations {
+				for _, eachParameter := range eachOperation.Parameters {
+					if eachParameter.In == "query" {
+						documentedValues[eachParameter.Name] = true
+					}
+				}
+				if eachOperation.RequestBody != nil {
+					if formContent, found := eachOperation.RequestBody.Content["application/x-www-form-urlencoded"]; found {
+						for eachProperty := range formContent.Schema.Properties {
+							documentedValues[eachProperty] = true
+						}
+					}
+				}
+			}
+		}
+
+		if !matched {
+			t.Errorf("route %v is not described by the OpenAPI document", eachRoute.Pattern)
+			continue
+		}
+		if documented, read := strings.Join(sortedKeys(documentedValues), ","), strings.Join(sortedKeys(formValues[name]), ","); documented != read {
+			t.Errorf("route %v documents query parameters [%v] but %v reads [%v]", eachRoute.Pattern, documented, name, read)
+		}
+	}
+
+	for eachPath := range document.Paths {
+		if !servedPaths[eachPath] {
+			t.Errorf("path %v of the OpenAPI document is not served by any route", eachPath)
+		}
+	}
+}
+
+func TestOpenAPIResponseSchemas(t *testing.T) {
+	s := NewServer(NewServerConfig("", 8777, "http://localhost", "users"))
+	responseRecorder := httptest.NewRecorder()
+	s.openAPI(responseRecorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	document := &openAPIDocument{}
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), document)
+	if err != nil {
+		t.Fatalf("expected no error when parsing the OpenAPI document, got %v", err)
+	}
+	if document.OpenAPI != OPENAPI_VERSION {
+		t.Errorf("expected openapi %v, got %v", OPENAPI_VERSION, document.OpenAPI)
+	}
+
+	// Every reference resolve to a component schema
+	var checkRefs func(schema *openAPISchema)
+	checkRefs = func(schema *openAPISchema) {
+		if schema == nil {
+			return
+		}
+		if schema.Ref != "" {
+			if _, found := document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]; !found {
+				t.Errorf("reference %v does not resolve", schema.Ref)
+			}
+		}
+		checkRefs(schema.Items)
+		checkRefs(schema.AdditionalProperties)
+		for _, eachProperty := range schema.Properties {
+			checkRefs(eachProperty)
+		}
+	}
+	for _, eachSchema := range document.Components.Schemas {
+		checkRefs(eachSchema)
+	}
+
+	// The response schema of retrieveUsers follow the JSON encoding of the model
+	response := document.Paths["/retrieveUsers"]["get"].Responses["200"]
+	if ref := response.Content["application/json"].Schema.Ref; ref != "#/components/schemas/ResultRetrieveUsers" {
+		t.Errorf("expected retrieveUsers to return ResultRetrieveUsers, got %v", ref)
+	}
+	tests := map[string]struct {
+		Value interface{}
+	}{
+		"ResultRetrieveUsers": {Value: model.ResultRetrieveUsers{Users: []*model.GithubUserInfo{}, Errors: []*model.ResultError{}}},
+		"GithubUserInfo":      {Value: model.GithubUserInfo{Message: "m", RepoStats: &model.GithubRepoStats{}}},
+		"ResultError":         {Value: model.ResultError{RequestID: "r"}},
+	}
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			encoded, _ := json.Marshal(test.Value)
+			encodedFields := make(map[string]json.RawMessage)
+			json.Unmarshal(encoded, &encodedFields)
+			jsonFields := make(map[string]bool)
+			for eachField := range encodedFields {
+				jsonFields[eachField] = true
+			}
+
+			schemaFields := make(map[string]bool)
+			for eachProperty := range document.Components.Schemas[testName].Properties {
+				schemaFields[eachProperty] = true
+			}
+			if expected, result := strings.Join(sortedKeys(jsonFields), ","), strings.Join(sortedKeys(schemaFields), ","); expected != result {
+				t.Errorf("expected properties [%v], got [%v]", expected, result)
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocs(t *testing.T) {
+	s := NewServer(NewServerConfig("", 8777, "http://localhost", "users"))
+	responseRecorder := httptest.NewRecorder()
+	s.openAPIDocs(responseRecorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	if contentType := responseRecorder.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("expected html content type, got %v", contentType)
+	}
+	if !strings.Contains(responseRecorder.Body.String(), `"/openapi.json"`) {
+		t.Errorf("expected the docs UI to load /openapi.json")
+	}
+}
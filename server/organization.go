@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ORGANIZATION_USERNAME_PREFIX usernames with this prefix are expanded into the public members of the organization
+	ORGANIZATION_USERNAME_PREFIX = "org:"
+)
+
+// retrieveOrganization handling retrieving organization by the login in the path /orgs/{org}
+func (s *Server) retrieveOrganization(w http.ResponseWriter, r *http.Request) {
+	client := s.newGithubClient(r.Context())
+
+	resultObj := &model.ResultRetrieveOrganization{
+		Errors: make([]*model.ResultError, 0),
+	}
+
+	login := strings.Trim(strings.TrimPrefix(r.URL.Path, "/orgs/"), "/")
+	if len(login) == 0 || strings.Contains(login, "/") {
+		resultObj.Errors = append(resultObj.Errors, &model.ResultError{
+			Message: fmt.Sprintf("invalid organization path %q, expected /orgs/{org}", r.URL.Path),
+		})
+	} else {
+		orgInfo, err := s.retrieveOrganizationInfo(client, login)
+		if err != nil {
+			resultObj.Errors = append(resultObj.Errors, organizationError(login, err))
+		} else {
+			resultObj.Organization = orgInfo
+		}
+	}
+
+	writeJSONResponse(w, r, resultObj)
+}
+
+// retrieveOrganizationInfo return the organization profile (from cache or from API call)
+func (s *Server) retrieveOrganizationInfo(client *http.Client, login string) (*model.GithubOrganizationInfo, error) {
+	orgInfo := tracedCacheGet(githubClientContext(client), s.tracer, CACHE_ORG_INFO, s.githubOrgInfoCache, login)
+	if orgInfo != nil {
+		return orgInfo, nil
+	}
+
+	orgInfo = &model.GithubOrganizationInfo{}
+	apiURL := fmt.Sprintf("%s/%s/%s", s.config.githubAPIURL, s.config.githubAPIOrg, url.PathEscape(login))
+	_, err := s.githubGet(client, apiURL, orgInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	s.githubOrgInfoCache.Set(login, orgInfo)
+	return orgInfo, nil
+}
+
+// retrieveOrganizationMembers return the logins of the public members of the organization (from cache or from API calls)
+func (s *Server) retrieveOrganizationMembers(client *http.Client, login string) (*model.GithubOrganizationMembers, error) {
+	orgMembers := tracedCacheGet(githubClientContext(client), s.tracer, CACHE_ORG_MEMBERS, s.githubOrgMembersCache, login)
+	if orgMembers != nil {
+		return orgMembers, nil
+	}
+
+	orgMembers = &model.GithubOrganizationMembers{
+		Login:   login,
+		Members: make([]string, 0),
+	}
+	nextURL := fmt.Sprintf("%s/%s/%s/public_members?per_page=100", s.config.githubAPIURL, s.config.githubAPIOrg, url.PathEscape(login))
+	for page := 0; nextURL != "" && page < s.config.orgMembersMaxPages; page++ {
+		pageMembers := make([]*model.GithubUserInfo, 0)
+		next, err := s.githubGet(client, nextURL, &pageMembers)
+		if err != nil {
+			return nil, err
+		}
+		for _, eachMember := range pageMembers {
+			orgMembers.Members = append(orgMembers.Members, eachMember.Login)
+		}
+		nextURL = next
+	}
+
+	s.githubOrgMembersCache.Set(login, orgMembers)
+	return orgMembers, nil
+}
+
+// expandOrganizationMembers replace each org:NAME in usernames with the public members of the organization
+func (s *Server) expandOrganizationMembers(client *http.Client, usernames []string) ([]string, []*model.ResultError) {
+	expandedUsernames := make([]string, 0, len(usernames))
+	expandErrors := make([]*model.ResultError, 0)
+	for _, eachUsername := range usernames {
+		trimmedUsername := strings.TrimSpace(eachUsername)
+		if !strings.HasPrefix(trimmedUsername, ORGANIZATION_USERNAME_PREFIX) {
+			expandedUsernames = append(expandedUsernames, eachUsername)
+			continue
+		}
+
+		orgLogin := strings.TrimPrefix(trimmedUsername, ORGANIZATION_USERNAME_PREFIX)
+		orgMembers, err := s.retrieveOrganizationMembers(client, orgLogin)
+		if err != nil {
+			expandErrors = append(expandErrors, organizationError(orgLogin, err))
+			continue
+		}
+		expandedUsernames = append(expandedUsernames, orgMembers.Members...)
+	}
+	return expandedUsernames, expandErrors
+}
+
+// organizationError convert error when retrieving an organization into result error
+func organizationError(login string, err error) *model.ResultError {
+	if isGithubNotFound(err) {
+		return &model.ResultError{
+			Message:    fmt.Sprintf("organization %q not found", login),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return &model.ResultError{
+		Message:    fmt.Sprintf("encounter err for organization %q: %v", login, err),
+		StatusCode: upstreamErrorStatus(err),
+	}
+}
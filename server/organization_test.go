@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newOrganizationTestServer return a github API test server with the organization "apache" (members: a, b, c) and users
+func newOrganizationTestServer(t *testing.T) *httptest.Server {
+	var githubAPITestServer *httptest.Server
+	githubAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var responseData interface{}
+		switch {
+		case r.URL.Path == "/orgs/apache":
+			responseData = model.GithubOrganizationInfo{Login: "apache", Name: "The Apache Software Foundation", PublicRepos: 2000}
+		case r.URL.Path == "/orgs/apache/public_members":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/orgs/apache/public_members?per_page=100&page=2>; rel="next"`, githubAPITestServer.URL))
+				responseData = []model.GithubUserInfo{{Login: "a"}, {Login: "b"}}
+			} else {
+				responseData = []model.GithubUserInfo{{Login: "c"}}
+			}
+		case strings.HasPrefix(r.URL.Path, "/users/") && !strings.Contains(r.URL.Path, "notfound"):
+			username := strings.TrimPrefix(r.URL.Path, "/users/")
+			responseData = model.GithubUserInfo{Login: username, Name: username, Followers: 3, PublicRepos: 100}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			responseData = struct {
+				Message string `json:"message"`
+			}{
+				Message: "Not Found",
+			}
+		}
+
+		jsonString, _ := json.Marshal(responseData)
+		w.Write([]byte(jsonString))
+	}))
+	return githubAPITestServer
+}
+
+func TestRetrieveOrganization(t *testing.T) {
+	tests := map[string]struct {
+		Path          string
+		ExpectedLogin string
+		ExpectedError bool
+	}{
+		"Test existing organization": {
+			Path:          "/orgs/apache",
+			ExpectedLogin: "apache",
+			ExpectedError: false,
+		},
+		"Test organization not found": {
+			Path:          "/orgs/notfound",
+			ExpectedError: true,
+		},
+		"Test invalid path": {
+			Path:          "/orgs/apache/members",
+			ExpectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, test.Path, nil)
+			s.retrieveOrganization(responseRecorder, request)
+
+			response := responseRecorder.Result()
+			defer response.Body.Close()
+			responseData, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("expected no error when read data from response body, got err = %v", err)
+			}
+
+			jsonResponseData := &model.ResultRetrieveOrganization{}
+			err = json.Unmarshal([]byte(responseData), &jsonResponseData)
+			if err != nil {
+				t.Fatalf("expected no error when unmarshal response data, got err = %v", err)
+			}
+
+			if test.ExpectedError {
+				if len(jsonResponseData.Errors) == 0 {
+					t.Errorf("expected an error from response, got no errors")
+				}
+				if jsonResponseData.Organization != nil {
+					t.Errorf("expected no organization, got %v", jsonResponseData.Organization)
+				}
+			} else {
+				if len(jsonResponseData.Errors) > 0 {
+					t.Errorf("expected no error from response, got errors = %v", jsonResponseData.Errors)
+				}
+				if jsonResponseData.Organization == nil || jsonResponseData.Organization.Login != test.ExpectedLogin {
+					t.Errorf("expected organization %v, got %v", test.ExpectedLogin, jsonResponseData.Organization)
+				}
+			}
+		})
+	}
+}
+
+func TestRetrieveUsersWithOrganizationExpansion(t *testing.T) {
+	tests := map[string]struct {
+		Usernames           string
+		ExpectedUsernames   string
+		ExpectedErrorsCount int
+	}{
+		"Test organization expanded into public members (paged)": {
+			Usernames:           "org:apache",
+			ExpectedUsernames:   "a,b,c",
+			ExpectedErrorsCount: 0,
+		},
+		"Test organization expansion together with usernames (duplicate skipped)": {
+			Usernames:           "b,org:apache,d",
+			ExpectedUsernames:   "a,b,c,d",
+			ExpectedErrorsCount: 0,
+		},
+		"Test organization not found": {
+			Usernames:           "d,org:notfound",
+			ExpectedUsernames:   "d",
+			ExpectedErrorsCount: 1,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			target := fmt.Sprintf("/retrieveUsers?usernames=%v", test.Usernames)
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+			s.retrieveUsers(responseRecorder, request)
+
+			response := responseRecorder.Result()
+			defer response.Body.Close()
+			responseData, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("expected no error when read data from response body, got err = %v", err)
+			}
+
+			jsonResponseData := &model.ResultRetrieveUsers{}
+			err = json.Unmarshal([]byte(responseData), &jsonResponseData)
+			if err != nil {
+				t.Fatalf("expected no error when unmarshal response data, got err = %v", err)
+			}
+
+			if len(jsonResponseData.Errors) != test.ExpectedErrorsCount {
+				t.Errorf("expected %v error(s), got %v", test.ExpectedErrorsCount, jsonResponseData.Errors)
+			}
+
+			expectedUsernames := strings.Split(test.ExpectedUsernames, ",")
+			if len(jsonResponseData.Users) != len(expectedUsernames) {
+				t.Fatalf("expected %v user record(s), got = %v", len(expectedUsernames), len(jsonResponseData.Users))
+			}
+			for i, eachExpectedUsername := range expectedUsernames {
+				if jsonResponseData.Users[i].Login != eachExpectedUsername {
+					t.Errorf("expected record for username %v at index %d, got %v", eachExpectedUsername, i, jsonResponseData.Users[i].Login)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DEFAULT_PAGE_SIZE number of items returned by a connection when first is not specified
+	DEFAULT_PAGE_SIZE = 10
+	// MAX_PAGE_SIZE maximum number of items returned by a connection
+	MAX_PAGE_SIZE = 100
+
+	cursorPrefix = "cursor:"
+)
+
+// encodeCursor return an opaque cursor pointing at the item after offset
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// decodeCursor return the offset encoded in the cursor (0 for empty cursor)
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), cursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(decoded), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+// parsePageArgs parse first and after query values into page size and offset
+func parsePageArgs(firstValue string, afterValue string) (int, int, error) {
+	first := DEFAULT_PAGE_SIZE
+	if firstValue != "" {
+		var err error
+		first, err = strconv.Atoi(firstValue)
+		if err != nil || first < 1 || first > MAX_PAGE_SIZE {
+			return 0, 0, fmt.Errorf("invalid first %q, expected a number between 1 and %d", firstValue, MAX_PAGE_SIZE)
+		}
+	}
+
+	offset, err := decodeCursor(afterValue)
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, offset, nil
+}
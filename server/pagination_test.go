@@ -0,0 +1,74 @@
+package server
+
+import "testing"
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := map[string]struct {
+		Offset int
+	}{
+		"Offset 0":   {Offset: 0},
+		"Offset 10":  {Offset: 10},
+		"Offset 999": {Offset: 999},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := decodeCursor(encodeCursor(test.Offset))
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if result != test.Offset {
+				t.Errorf("expected offset %v, got %v", test.Offset, result)
+			}
+		})
+	}
+}
+
+func TestParsePageArgs(t *testing.T) {
+	tests := map[string]struct {
+		First          string
+		After          string
+		ExpectedFirst  int
+		ExpectedOffset int
+		ExpectedError  bool
+	}{
+		"Default values": {
+			ExpectedFirst:  DEFAULT_PAGE_SIZE,
+			ExpectedOffset: 0,
+		},
+		"First and after": {
+			First:          "5",
+			After:          encodeCursor(20),
+			ExpectedFirst:  5,
+			ExpectedOffset: 20,
+		},
+		"First too large": {
+			First:         "101",
+			ExpectedError: true,
+		},
+		"First not a number": {
+			First:         "abc",
+			ExpectedError: true,
+		},
+		"Invalid cursor": {
+			After:         "abc",
+			ExpectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			first, offset, err := parsePageArgs(test.First, test.After)
+			if test.ExpectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if first != test.ExpectedFirst || offset != test.ExpectedOffset {
+				t.Errorf("expected first = %v and offset = %v, got first = %v and offset = %v", test.ExpectedFirst, test.ExpectedOffset, first, offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PROBLEM_CONTENT_TYPE content type of RFC 7807 problem details
+	PROBLEM_CONTENT_TYPE = "application/problem+json"
+)
+
+// githubLoginPattern github logins are made of alphanumeric characters and hyphens, at most 39 characters
+var githubLoginPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,39}$`)
+
+// problemDetails RFC 7807 body of a whole request failure, errors carry the same per username errors as ResultRetrieveUsers
+type problemDetails struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail,omitempty"`
+	Instance  string               `json:"instance,omitempty"`
+	RequestID string               `json:"request_id,omitempty"` // X-Request-ID of the request, to find its access log
+	Errors    []*model.ResultError `json:"errors"`
+}
+
+// writeProblem write the problem details of a whole request failure, the detail is the only error when there are no errors
+func writeProblem(w http.ResponseWriter, r *http.Request, statusCode int, detail string, resultErrors []*model.ResultError) {
+	if len(resultErrors) == 0 {
+		resultErrors = []*model.ResultError{
+			{Message: detail, StatusCode: statusCode},
+		}
+	}
+	problem := &problemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestIDFromContext(r.Context()),
+		Errors:    resultErrorsWithRequestID(resultErrors, requestIDFromContext(r.Context())),
+	}
+	writeEncodedJSON(w, r, statusCode, PROBLEM_CONTENT_TYPE, problem, jsonPretty(r))
+}
+
+// allowMethods check whether the request method is allowed, otherwise write 405 with the Allow header and return false
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, eachMethod := range methods {
+		if r.Method == eachMethod {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeProblem(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method), nil)
+	return false
+}
+
+// invalidUsernameErrors return an error for each username that can not be a github login (org:NAME is checked against the organization login)
+func invalidUsernameErrors(usernames []string) []*model.ResultError {
+	invalidErrors := make([]*model.ResultError, 0)
+	for _, eachUsername := range usernames {
+		login := strings.TrimSpace(eachUsername)
+		if login == "" {
+			continue
+		}
+		if !githubLoginPattern.MatchString(strings.TrimPrefix(login, ORGANIZATION_USERNAME_PREFIX)) {
+			invalidErrors = append(invalidErrors, &model.ResultError{
+				Message:    fmt.Sprintf("invalid username %q, expected at most 39 alphanumeric characters or hyphens", login),
+				StatusCode: http.StatusBadRequest,
+			})
+		}
+	}
+	return invalidErrors
+}
+
+// upstreamErrorStatus return the status code matching the error of an upstream call: 503 when the rate limit is exhausted, 404 when github respond Not Found, otherwise 502
+func upstreamErrorStatus(err error) int {
+	var rateLimitErr *rateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return http.StatusServiceUnavailable
+	}
+	if isGithubNotFound(err) {
+		return http.StatusNotFound
+	}
+	var peerErr *clusterPeerError
+	if errors.As(err, &peerErr) && peerErr.StatusCode != 0 {
+		// Reported by the replica owning the user
+		return peerErr.StatusCode
+	}
+	return http.StatusBadGateway
+}
+
+// failureStatus return the status code of a request where every username failed: 503 if any was rate limited, 502 if any upstream call failed, otherwise 404
+func failureStatus(resultErrors []*model.ResultError) int {
+	statusCode := http.StatusNotFound
+	for _, eachError := range resultErrors {
+		switch eachError.StatusCode {
+		case http.StatusServiceUnavailable:
+			return http.StatusServiceUnavailable
+		case http.StatusNotFound:
+		default:
+			statusCode = http.StatusBadGateway
+		}
+	}
+	return statusCode
+}
+
+// setRetryAfter set the Retry-After header to the number of seconds until the exhausted rate limit buckets reset
+func (s *Server) setRetryAfter(w http.ResponseWriter) {
+	reset := s.githubRateLimiter.exhaustedUntil()
+	if reset.IsZero() {
+		return
+	}
+	seconds := int(time.Until(reset).Seconds()) + 1
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetrieveUsersStatusCodes(t *testing.T) {
+	tests := map[string]struct {
+		Method              string
+		Query               string
+		ExpectedStatusCode  int
+		ExpectedProblem     bool
+		ExpectedAllow       string
+		ExpectedRetryAfter  bool
+		ExpectedErrorsCount int
+	}{
+		"Test method not allowed": {
+			Method:              http.MethodDelete,
+			Query:               "usernames=a",
+			ExpectedStatusCode:  http.StatusMethodNotAllowed,
+			ExpectedProblem:     true,
+			ExpectedAllow:       "GET, HEAD",
+			ExpectedErrorsCount: 1,
+		},
+		"Test invalid username": {
+			Query:               "usernames=a,not_valid!,org:also/invalid",
+			ExpectedStatusCode:  http.StatusBadRequest,
+			ExpectedProblem:     true,
+			ExpectedErrorsCount: 2,
+		},
+		"Test invalid includeRepoStats": {
+			Query:               "usernames=a&includeRepoStats=maybe",
+			ExpectedStatusCode:  http.StatusBadRequest,
+			ExpectedProblem:     true,
+			ExpectedErrorsCount: 1,
+		},
+		"Test partial result": {
+			Query:               "usernames=a,notfound,broken",
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedErrorsCount: 2,
+		},
+		"Test every username not found": {
+			Query:               "usernames=notfound,org:notfound",
+			ExpectedStatusCode:  http.StatusNotFound,
+			ExpectedProblem:     true,
+			ExpectedErrorsCount: 2,
+		},
+		"Test every username failed upstream": {
+			Query:               "usernames=notfound,broken",
+			ExpectedStatusCode:  http.StatusBadGateway,
+			ExpectedProblem:     true,
+			ExpectedErrorsCount: 2,
+		},
+		"Test rate limit exhausted": {
+			Query:               "usernames=notfound,ratelimited,a",
+			ExpectedStatusCode:  http.StatusServiceUnavailable,
+			ExpectedProblem:     true,
+			ExpectedRetryAfter:  true,
+			ExpectedErrorsCount: 3,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/broken"):
+					w.WriteHeader(http.StatusInternalServerError)
+				case strings.HasSuffix(r.URL.Path, "/ratelimited"):
+					w.Header().Set("X-RateLimit-Limit", "60")
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Minute).Unix()))
+					w.WriteHeader(http.StatusForbidden)
+				case strings.Contains(r.URL.Path, "notfound"):
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{"message":"Not Found"}`))
+				default:
+					jsonString, _ := json.Marshal(model.GithubUserInfo{Login: "a", Name: "a"})
+					w.Write(jsonString)
+				}
+			}))
+			defer githubAPITestServer.Close()
+
+			// The REST fetcher fetch users in order, so the rate limited user exhaust the bucket before the next ones
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			method := test.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+			responseRecorder := httptest.NewRecorder()
+			s.retrieveUsers(responseRecorder, httptest.NewRequest(method, fmt.Sprintf("/retrieveUsers?%v", test.Query), nil))
+
+			if responseRecorder.Code != test.ExpectedStatusCode {
+				t.Errorf("expected status %v, got %v", test.ExpectedStatusCode, responseRecorder.Code)
+			}
+			if allow := responseRecorder.Header().Get("Allow"); allow != test.ExpectedAllow {
+				t.Errorf("expected Allow header %q, got %q", test.ExpectedAllow, allow)
+			}
+			if retryAfter := responseRecorder.Header().Get("Retry-After"); (retryAfter != "") != test.ExpectedRetryAfter {
+				t.Errorf("expected Retry-After header %v, got %q", test.ExpectedRetryAfter, retryAfter)
+			}
+
+			contentType := responseRecorder.Header().Get("Content-Type")
+			if test.ExpectedProblem {
+				if contentType != PROBLEM_CONTENT_TYPE {
+					t.Errorf("expected content type %v, got %v", PROBLEM_CONTENT_TYPE, contentType)
+				}
+				problem := &problemDetails{}
+				err := json.Unmarshal(responseRecorder.Body.Bytes(), problem)
+				if err != nil {
+					t.Fatalf("expected no error when parsing problem, got %v", err)
+				}
+				if problem.Status != test.ExpectedStatusCode || problem.Title != http.StatusText(test.ExpectedStatusCode) {
+					t.Errorf("unexpected problem %+v", problem)
+				}
+				if len(problem.Errors) != test.ExpectedErrorsCount {
+					t.Errorf("expected %v errors, got %v", test.ExpectedErrorsCount, problem.Errors)
+				}
+			} else {
+				resultObj := &model.ResultRetrieveUsers{}
+				err := json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+				if err != nil {
+					t.Fatalf("expected no error when parsing response, got %v", err)
+				}
+				if len(resultObj.Users) != 1 || len(resultObj.Errors) != test.ExpectedErrorsCount {
+					t.Errorf("expected 1 user and %v errors, got %v users and %v errors", test.ExpectedErrorsCount, len(resultObj.Users), resultObj.Errors)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RATE_LIMIT_RESOURCE_CORE rate limit bucket of the REST API
+	RATE_LIMIT_RESOURCE_CORE = "core"
+	// RATE_LIMIT_RESOURCE_SEARCH rate limit bucket of the search API
+	RATE_LIMIT_RESOURCE_SEARCH = "search"
+	// RATE_LIMIT_RESOURCE_GRAPHQL rate limit bucket of the GraphQL v4 API
+	RATE_LIMIT_RESOURCE_GRAPHQL = "graphql"
+)
+
+// rateLimitBucket the state of a github rate limit bucket as reported by the last response
+type rateLimitBucket struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// rateLimitError error returned when the rate limit bucket is exhausted
+type rateLimitError struct {
+	Resource string
+	Reset    time.Time
+}
+
+// Error comply with error interface
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("github API rate limit for %q exhausted until %v", e.Resource, e.Reset.UTC().Format(time.RFC3339))
+}
+
+// githubRateLimiter track the rate limit buckets reported by github API so calls are not made while a bucket is exhausted
+type githubRateLimiter struct {
+	buckets     map[string]*rateLimitBucket
+	bucketsLock *sync.RWMutex
+}
+
+// newGithubRateLimiter return new rate limiter
+func newGithubRateLimiter() *githubRateLimiter {
+	return &githubRateLimiter{
+		buckets:     make(map[string]*rateLimitBucket),
+		bucketsLock: &sync.RWMutex{},
+	}
+}
+
+// rateLimitResource return the rate limit bucket used by the API URL
+func (s *Server) rateLimitResource(apiURL *url.URL) string {
+	githubGraphQLURL, err := url.Parse(s.config.githubGraphQLURL)
+	if err == nil && apiURL.Host == githubGraphQLURL.Host && apiURL.Path == githubGraphQLURL.Path {
+		return RATE_LIMIT_RESOURCE_GRAPHQL
+	}
+
+	basePath := ""
+	githubAPIURL, err := url.Parse(s.config.githubAPIURL)
+	if err == nil {
+		basePath = strings.TrimSuffix(githubAPIURL.Path, "/")
+	}
+
+	if strings.HasPrefix(strings.TrimPrefix(apiURL.Path, basePath), "/search/") {
+		return RATE_LIMIT_RESOURCE_SEARCH
+	}
+	return RATE_LIMIT_RESOURCE_CORE
+}
+
+// check return error if the bucket is known to be exhausted
+func (rl *githubRateLimiter) check(resource string) error {
+	rl.bucketsLock.RLock()
+	defer rl.bucketsLock.RUnlock()
+	bucket, found := rl.buckets[resource]
+	if found && bucket.Remaining <= 0 && time.Now().Before(bucket.Reset) {
+		return &rateLimitError{
+			Resource: resource,
+			Reset:    bucket.Reset,
+		}
+	}
+	return nil
+}
+
+// update record the rate limit headers of a github API response
+func (rl *githubRateLimiter) update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	resource := header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = RATE_LIMIT_RESOURCE_CORE
+	}
+
+	rl.bucketsLock.Lock()
+	defer rl.bucketsLock.Unlock()
+	rl.buckets[resource] = &rateLimitBucket{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}
+}
+
+// bucket return a copy of the last known state of the bucket
+func (rl *githubRateLimiter) bucket(resource string) (rateLimitBucket, bool) {
+	rl.bucketsLock.RLock()
+	defer rl.bucketsLock.RUnlock()
+	bucket, found := rl.buckets[resource]
+	if !found {
+		return rateLimitBucket{}, false
+	}
+	return *bucket, true
+}
+
+// exhaustedUntil return the latest reset of the exhausted buckets (zero if no bucket is exhausted)
+func (rl *githubRateLimiter) exhaustedUntil() time.Time {
+	rl.bucketsLock.RLock()
+	defer rl.bucketsLock.RUnlock()
+	until := time.Time{}
+	for _, eachBucket := range rl.buckets {
+		if eachBucket.Remaining <= 0 && time.Now().Before(eachBucket.Reset) && eachBucket.Reset.After(until) {
+			until = eachBucket.Reset
+		}
+	}
+	return until
+}
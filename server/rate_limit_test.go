@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterCheck(t *testing.T) {
+	tests := map[string]struct {
+		Remaining     string
+		Reset         time.Time
+		Resource      string
+		CheckResource string
+		ExpectedError bool
+	}{
+		"Bucket not exhausted": {
+			Remaining:     "1",
+			Reset:         time.Now().Add(time.Minute),
+			Resource:      "search",
+			CheckResource: "search",
+			ExpectedError: false,
+		},
+		"Bucket exhausted": {
+			Remaining:     "0",
+			Reset:         time.Now().Add(time.Minute),
+			Resource:      "search",
+			CheckResource: "search",
+			ExpectedError: true,
+		},
+		"Bucket exhausted but reset time passed": {
+			Remaining:     "0",
+			Reset:         time.Now().Add(-time.Minute),
+			Resource:      "search",
+			CheckResource: "search",
+			ExpectedError: false,
+		},
+		"Other bucket exhausted": {
+			Remaining:     "0",
+			Reset:         time.Now().Add(time.Minute),
+			Resource:      "search",
+			CheckResource: "core",
+			ExpectedError: false,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			rateLimiter := newGithubRateLimiter()
+			header := http.Header{}
+			header.Set("X-RateLimit-Resource", test.Resource)
+			header.Set("X-RateLimit-Remaining", test.Remaining)
+			header.Set("X-RateLimit-Reset", strconv.FormatInt(test.Reset.Unix(), 10))
+			rateLimiter.update(header)
+
+			err := rateLimiter.check(test.CheckResource)
+			if test.ExpectedError && err == nil {
+				t.Errorf("expected error, got none")
+			} else if !test.ExpectedError && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRateLimitResource(t *testing.T) {
+	tests := map[string]struct {
+		GithubAPIURL string
+		APIURL       string
+		Expected     string
+	}{
+		"Search API": {
+			GithubAPIURL: "https://api.github.com",
+			APIURL:       "https://api.github.com/search/users?q=a",
+			Expected:     RATE_LIMIT_RESOURCE_SEARCH,
+		},
+		"Search API behind a path prefix": {
+			GithubAPIURL: "https://example.com/api/v3",
+			APIURL:       "https://example.com/api/v3/search/users?q=a",
+			Expected:     RATE_LIMIT_RESOURCE_SEARCH,
+		},
+		"User named search": {
+			GithubAPIURL: "https://api.github.com",
+			APIURL:       "https://api.github.com/users/search/followers",
+			Expected:     RATE_LIMIT_RESOURCE_CORE,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			s := NewServer(NewServerConfig("", 8777, test.GithubAPIURL, "users"))
+			apiURL, _ := url.Parse(test.APIURL)
+			result := s.rateLimitResource(apiURL)
+			if result != test.Expected {
+				t.Errorf("expected %v, got %v", test.Expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,550 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// REDIS_DEFAULT_ADDRESS address of the Redis server when none is configured
+	REDIS_DEFAULT_ADDRESS = "localhost:6379"
+	// REDIS_DIAL_TIMEOUT timeout of connecting to the Redis server
+	REDIS_DIAL_TIMEOUT = 2 * time.Second
+	// REDIS_IO_TIMEOUT timeout of sending a command to the Redis server and reading its reply
+	REDIS_IO_TIMEOUT = 2 * time.Second
+	// REDIS_MAX_IDLE_CONNECTIONS maximum number of connections kept open between commands
+	REDIS_MAX_IDLE_CONNECTIONS = 8
+	// REDIS_MAX_BULK_LENGTH maximum length of a bulk string read from the Redis server
+	REDIS_MAX_BULK_LENGTH = 512 * 1024 * 1024
+	// REDIS_SCAN_COUNT number of keys inspected by each SCAN call and requested by each MGET or DEL call
+	REDIS_SCAN_COUNT = 100
+	// REDIS_CIRCUIT_FAILURE_THRESHOLD consecutive commands failing to reach the Redis server opening the circuit
+	REDIS_CIRCUIT_FAILURE_THRESHOLD = 3
+	// REDIS_CIRCUIT_OPEN_DURATION time the commands fail without reaching the Redis server once the circuit is open, the next command then probes the server
+	REDIS_CIRCUIT_OPEN_DURATION = 5 * time.Second
+	// REDIS_KEY_NAMESPACE prefix of the keys of the cache entries, followed by the cache name and the partition id
+	REDIS_KEY_NAMESPACE = "machshipgithubapi"
+)
+
+// errRedisCircuitOpen error of the commands not sent while the Redis circuit is open
+var errRedisCircuitOpen = errors.New("redis circuit open, the server failed to answer the last commands")
+
+// RedisOptions connection options of the Redis server
+type RedisOptions struct {
+	Address  string // host:port
+	Username string // ACL username, the password authenticate the default user when empty
+	Password string // no authentication when empty
+	DB       int    // database selected after connecting
+}
+
+// ParseRedisURL parse the options of a redis://[[username]:password@]host[:port][/db] URL, the port is 6379 by default
+func ParseRedisURL(rawURL string) (*RedisOptions, error) {
+	redisURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if redisURL.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported scheme %q, expected redis", redisURL.Scheme)
+	}
+	if redisURL.Hostname() == "" {
+		return nil, errors.New("host is missing")
+	}
+	port := redisURL.Port()
+	if port == "" {
+		port = "6379"
+	}
+	options := &RedisOptions{
+		Address: net.JoinHostPort(redisURL.Hostname(), port),
+	}
+	if redisURL.User != nil {
+		options.Username = redisURL.User.Username()
+		options.Password, _ = redisURL.User.Password()
+	}
+	if db := strings.Trim(redisURL.Path, "/"); db != "" {
+		options.DB, err = strconv.Atoi(db)
+		if err != nil || options.DB < 0 {
+			return nil, fmt.Errorf("invalid database %q, expected a non negative number", db)
+		}
+	}
+	return options, nil
+}
+
+// redisError error reply of the Redis server, the connection can still be used
+type redisError string
+
+// Error this method comply with error interface
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn connection to the Redis server
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// do send the command and read its reply
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	err := c.conn.SetDeadline(time.Now().Add(REDIS_IO_TIMEOUT))
+	if err != nil {
+		return nil, err
+	}
+	err = writeRedisCommand(c.writer, args)
+	if err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.reader)
+}
+
+// writeRedisCommand write the command as a RESP array of bulk strings
+func writeRedisCommand(w *bufio.Writer, args []string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, eachArg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(eachArg), eachArg)
+	}
+	return w.Flush()
+}
+
+// readRedisReply read a RESP2 reply: a string for a simple string, a redisError for an error, an int64 for an integer,
+// a []byte for a bulk string and a []interface{} for an array (nil for a null bulk string or array)
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil || length > REDIS_MAX_BULK_LENGTH {
+			return nil, fmt.Errorf("invalid redis bulk string length %q", payload)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		bulk := make([]byte, length+2)
+		if _, err := io.ReadFull(r, bulk); err != nil {
+			return nil, err
+		}
+		if bulk[length] != '\r' || bulk[length+1] != '\n' {
+			return nil, errors.New("invalid redis bulk string terminator")
+		}
+		return bulk[:length], nil
+	case '*':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis array length %q", payload)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, length)
+		for i := range items {
+			items[i], err = readRedisReply(r)
+			var redisErr redisError
+			if errors.As(err, &redisErr) {
+				// Keep reading the array so the connection can still be used
+				items[i] = redisErr
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("invalid redis reply %q", line)
+}
+
+// redisClient client of a Redis server (or a server speaking its protocol), the connections are reused between commands
+type redisClient struct {
+	options *RedisOptions
+	idle    chan *redisConn // connections open waiting for a command
+
+	// The circuit is open after REDIS_CIRCUIT_FAILURE_THRESHOLD consecutive commands failing to reach the server, the commands then fail
+	// immediately until openUntil instead of waiting for the dial and IO timeouts, and closed again by the next command reaching the server
+	circuitLock         *sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+// newRedisClient return new Redis client, the connections are opened by the commands
+func newRedisClient(options *RedisOptions) *redisClient {
+	if options == nil {
+		options = &RedisOptions{Address: REDIS_DEFAULT_ADDRESS}
+	}
+	return &redisClient{
+		options:     options,
+		idle:        make(chan *redisConn, REDIS_MAX_IDLE_CONNECTIONS),
+		circuitLock: &sync.Mutex{},
+	}
+}
+
+// dial open a connection, authenticated and on the selected database
+func (rc *redisClient) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", rc.options.Address, REDIS_DIAL_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+	if rc.options.Password != "" {
+		args := []string{"AUTH", rc.options.Password}
+		if rc.options.Username != "" {
+			args = []string{"AUTH", rc.options.Username, rc.options.Password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("encounter err when authenticating: %w", err)
+		}
+	}
+	if rc.options.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(rc.options.DB)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("encounter err when selecting database %d: %w", rc.options.DB, err)
+		}
+	}
+	return c, nil
+}
+
+// do send the command on an idle connection (or a new one) and return its reply, a command failing on an idle connection closed meanwhile
+// is sent again on a new connection, errRedisCircuitOpen when the circuit is open
+func (rc *redisClient) do(args ...string) (interface{}, error) {
+	if rc.circuitOpen() {
+		return nil, errRedisCircuitOpen
+	}
+	reply, err := rc.send(args...)
+	var redisErr redisError
+	rc.record(err == nil || errors.As(err, &redisErr))
+	return reply, err
+}
+
+// circuitOpen check whether the commands fail without reaching the server
+func (rc *redisClient) circuitOpen() bool {
+	rc.circuitLock.Lock()
+	defer rc.circuitLock.Unlock()
+	return time.Now().Before(rc.openUntil)
+}
+
+// record record whether a command reached the server, the circuit is opened again by a failure once the threshold is reached
+func (rc *redisClient) record(reached bool) {
+	rc.circuitLock.Lock()
+	defer rc.circuitLock.Unlock()
+	if reached {
+		rc.consecutiveFailures = 0
+		rc.openUntil = time.Time{}
+		return
+	}
+	rc.consecutiveFailures++
+	if rc.consecutiveFailures >= REDIS_CIRCUIT_FAILURE_THRESHOLD {
+		rc.openUntil = time.Now().Add(REDIS_CIRCUIT_OPEN_DURATION)
+	}
+}
+
+// send send the command on an idle connection (or a new one) and return its reply
+func (rc *redisClient) send(args ...string) (interface{}, error) {
+	for {
+		var c *redisConn
+		reused := false
+		select {
+		case c = <-rc.idle:
+			reused = true
+		default:
+			var err error
+			c, err = rc.dial()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		reply, err := c.do(args...)
+		var redisErr redisError
+		if err != nil && !errors.As(err, &redisErr) {
+			c.conn.Close()
+			if reused {
+				continue
+			}
+			return nil, err
+		}
+		select {
+		case rc.idle <- c:
+		default:
+			c.conn.Close()
+		}
+		return reply, err
+	}
+}
+
+// Close close the idle connections
+func (rc *redisClient) Close() {
+	for {
+		select {
+		case c := <-rc.idle:
+			c.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// ping check whether the Redis server answers
+func (rc *redisClient) ping() error {
+	_, err := rc.do("PING")
+	return err
+}
+
+// get return the value of the key, nil when there is none
+func (rc *redisClient) get(key string) ([]byte, error) {
+	reply, err := rc.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+	value, _ := reply.([]byte)
+	return value, nil
+}
+
+// set set the value of the key, expiring after the ttl (never when 0)
+func (rc *redisClient) set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := rc.do(args...)
+	return err
+}
+
+// del delete the keys, return the number of keys deleted
+func (rc *redisClient) del(keys ...string) (int, error) {
+	deleted := 0
+	for start := 0; start < len(keys); start += REDIS_SCAN_COUNT {
+		end := start + REDIS_SCAN_COUNT
+		if end > len(keys) {
+			end = len(keys)
+		}
+		reply, err := rc.do(append([]string{"DEL"}, keys[start:end]...)...)
+		if err != nil {
+			return deleted, err
+		}
+		count, _ := reply.(int64)
+		deleted += int(count)
+	}
+	return deleted, nil
+}
+
+// mget return the values of the keys in the same order, nil for the keys without value
+func (rc *redisClient) mget(keys ...string) ([][]byte, error) {
+	values := make([][]byte, 0, len(keys))
+	for start := 0; start < len(keys); start += REDIS_SCAN_COUNT {
+		end := start + REDIS_SCAN_COUNT
+		if end > len(keys) {
+			end = len(keys)
+		}
+		reply, err := rc.do(append([]string{"MGET"}, keys[start:end]...)...)
+		if err != nil {
+			return nil, err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != end-start {
+			return nil, fmt.Errorf("unexpected redis MGET reply %v", reply)
+		}
+		for _, eachItem := range items {
+			value, _ := eachItem.([]byte)
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// scan return the keys matching the glob pattern, a key may be returned twice when it is modified during the scan
+func (rc *redisClient) scan(pattern string) ([]string, error) {
+	keys := make([]string, 0)
+	cursor := "0"
+	for {
+		reply, err := rc.do("SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(REDIS_SCAN_COUNT))
+		if err != nil {
+			return nil, err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			return nil, fmt.Errorf("unexpected redis SCAN reply %v", reply)
+		}
+		nextCursor, _ := items[0].([]byte)
+		scannedKeys, _ := items[1].([]interface{})
+		for _, eachKey := range scannedKeys {
+			if key, ok := eachKey.([]byte); ok {
+				keys = append(keys, string(key))
+			}
+		}
+		cursor = string(nextCursor)
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// escapeRedisGlob escape the characters of the text interpreted by the glob patterns of Redis
+func escapeRedisGlob(text string) string {
+	var escaped strings.Builder
+	for _, eachRune := range text {
+		if strings.ContainsRune(`*?[]^\`, eachRune) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(eachRune)
+	}
+	return escaped.String()
+}
+
+// redisCacheBackend cache backend keeping the entries of a partition in Redis, under the keys machshipgithubapi:{cache}:{partition}:{key},
+// the entries expire in Redis when they expire in the cache, the replicas configured with the same Redis server share the entries
+type redisCacheBackend[T ICacheable] struct {
+	client *redisClient
+	prefix string // prefix of the Redis keys of the partition
+}
+
+// newRedisCacheBackend return new Redis cache backend of the partition of the cache
+func newRedisCacheBackend[T ICacheable](client *redisClient, cacheName string, partitionID string) *redisCacheBackend[T] {
+	return &redisCacheBackend[T]{
+		client: client,
+		prefix: REDIS_KEY_NAMESPACE + ":" + cacheName + ":" + partitionID + ":",
+	}
+}
+
+// encodeEntry encode the entry as a line with the stored and expiry time in Unix nanoseconds (0 when it never expires), followed by the value
+// serialized by its String method
+func encodeEntry[T ICacheable](entry *ServerCacheEntry[T]) ([]byte, error) {
+	if entry.Value == nil {
+		return nil, errors.New("value is nil")
+	}
+	value := (*entry.Value).String()
+	if value == "" {
+		return nil, errors.New("value can not be serialized")
+	}
+	expiresAt := int64(0)
+	if !entry.ExpiresAt.IsZero() {
+		expiresAt = entry.ExpiresAt.UnixNano()
+	}
+	return []byte(fmt.Sprintf("%d %d\n%s", entry.StoredAt.UnixNano(), expiresAt, value)), nil
+}
+
+// decodeEntry decode the entry encoded by encodeEntry, the value is decoded from JSON
+func decodeEntry[T ICacheable](data []byte) (*ServerCacheEntry[T], error) {
+	header, value, found := strings.Cut(string(data), "\n")
+	if !found {
+		return nil, errors.New("invalid cache entry, the header is missing")
+	}
+	var storedAt, expiresAt int64
+	if _, err := fmt.Sscanf(header, "%d %d", &storedAt, &expiresAt); err != nil {
+		return nil, fmt.Errorf("invalid cache entry header %q: %w", header, err)
+	}
+	entry := &ServerCacheEntry[T]{
+		Value:    new(T),
+		StoredAt: time.Unix(0, storedAt),
+	}
+	if expiresAt != 0 {
+		entry.ExpiresAt = time.Unix(0, expiresAt)
+	}
+	if err := json.Unmarshal([]byte(value), entry.Value); err != nil {
+		return nil, fmt.Errorf("invalid cache entry value: %w", err)
+	}
+	return entry, nil
+}
+
+// Load return the entry stored for the key, nil when there is none
+func (rcb *redisCacheBackend[T]) Load(key string) (*ServerCacheEntry[T], error) {
+	data, err := rcb.client.get(rcb.prefix + key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decodeEntry[T](data)
+}
+
+// Store store the entry for the key, replacing the previous one, an entry already expired remove the previous one
+func (rcb *redisCacheBackend[T]) Store(key string, entry *ServerCacheEntry[T]) error {
+	data, err := encodeEntry(entry)
+	if err != nil {
+		return err
+	}
+	ttl := time.Duration(0)
+	if !entry.ExpiresAt.IsZero() {
+		ttl = time.Until(entry.ExpiresAt)
+		if ttl < time.Millisecond {
+			_, err = rcb.client.del(rcb.prefix + key)
+			return err
+		}
+	}
+	return rcb.client.set(rcb.prefix+key, data, ttl)
+}
+
+// Remove remove the entry of the key, return the entry removed, nil when there was none
+func (rcb *redisCacheBackend[T]) Remove(key string) (*ServerCacheEntry[T], error) {
+	data, err := rcb.client.get(rcb.prefix + key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	deleted, err := rcb.client.del(rcb.prefix + key)
+	if err != nil || deleted == 0 {
+		return nil, err
+	}
+	return decodeEntry[T](data)
+}
+
+// scanEntries return the Redis keys of the partition and the entries by key, the values that can not be decoded are skipped
+func (rcb *redisCacheBackend[T]) scanEntries() ([]string, map[string]*ServerCacheEntry[T], error) {
+	redisKeys, err := rcb.client.scan(escapeRedisGlob(rcb.prefix) + "*")
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := rcb.client.mget(redisKeys...)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := make(map[string]*ServerCacheEntry[T], len(redisKeys))
+	for i, eachRedisKey := range redisKeys {
+		if values[i] == nil {
+			// Expired or removed since the scan
+			continue
+		}
+		if entry, err := decodeEntry[T](values[i]); err == nil {
+			entries[strings.TrimPrefix(eachRedisKey, rcb.prefix)] = entry
+		}
+	}
+	return redisKeys, entries, nil
+}
+
+// Entries return the stored entries by key
+func (rcb *redisCacheBackend[T]) Entries() (map[string]*ServerCacheEntry[T], error) {
+	_, entries, err := rcb.scanEntries()
+	return entries, err
+}
+
+// Clear remove every entry, return the entries removed by key
+func (rcb *redisCacheBackend[T]) Clear() (map[string]*ServerCacheEntry[T], error) {
+	redisKeys, entries, err := rcb.scanEntries()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rcb.client.del(redisKeys...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
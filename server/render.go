@@ -0,0 +1,321 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// RESPONSE_FORMAT_JSON compact JSON, indented with pretty=true (default)
+	RESPONSE_FORMAT_JSON = "json"
+	// RESPONSE_FORMAT_JSON_COMPACT JSON without indentation, even with pretty=true
+	RESPONSE_FORMAT_JSON_COMPACT = "json-compact"
+	// RESPONSE_FORMAT_CSV users as CSV rows followed by a separate errors section
+	RESPONSE_FORMAT_CSV = "csv"
+	// RESPONSE_FORMAT_NDJSON one JSON object per line for each user and each error
+	RESPONSE_FORMAT_NDJSON = "ndjson"
+	// RESPONSE_FORMAT_YAML YAML document
+	RESPONSE_FORMAT_YAML = "yaml"
+	// RESPONSE_FORMAT_XML XML document
+	RESPONSE_FORMAT_XML = "xml"
+)
+
+// responseFormatContentTypes content type written for each response format
+var responseFormatContentTypes = map[string]string{
+	RESPONSE_FORMAT_JSON:         "application/json",
+	RESPONSE_FORMAT_JSON_COMPACT: "application/json",
+	RESPONSE_FORMAT_CSV:          "text/csv; charset=utf-8",
+	RESPONSE_FORMAT_NDJSON:       "application/x-ndjson",
+	RESPONSE_FORMAT_YAML:         "application/yaml",
+	RESPONSE_FORMAT_XML:          "application/xml; charset=utf-8",
+}
+
+// acceptMediaTypeFormats response format selected by each media type of the Accept header
+var acceptMediaTypeFormats = map[string]string{
+	"*/*":                  RESPONSE_FORMAT_JSON,
+	"application/*":        RESPONSE_FORMAT_JSON,
+	"application/json":     RESPONSE_FORMAT_JSON,
+	"text/csv":             RESPONSE_FORMAT_CSV,
+	"application/x-ndjson": RESPONSE_FORMAT_NDJSON,
+	"application/ndjson":   RESPONSE_FORMAT_NDJSON,
+	"application/yaml":     RESPONSE_FORMAT_YAML,
+	"application/x-yaml":   RESPONSE_FORMAT_YAML,
+	"text/yaml":            RESPONSE_FORMAT_YAML,
+	"application/xml":      RESPONSE_FORMAT_XML,
+	"text/xml":             RESPONSE_FORMAT_XML,
+}
+
+// responseFormatError error when the response format can not be negotiated
+type responseFormatError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error comply with error interface
+func (e *responseFormatError) Error() string {
+	return e.Message
+}
+
+// negotiateResponseFormat select the response format from the format query parameter, or from the Accept header
+func negotiateResponseFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(r.FormValue("format")))
+	if format != "" {
+		if _, supported := responseFormatContentTypes[format]; !supported {
+			return "", &responseFormatError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("unsupported format %q, expected one of %s", format, strings.Join(supportedResponseFormats(), ", ")),
+			}
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return RESPONSE_FORMAT_JSON, nil
+	}
+
+	// Pick the supported media type with the highest quality, the first one wins on ties
+	bestFormat := ""
+	bestQuality := 0.0
+	for _, eachMediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(eachMediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		quality := 1.0
+		for _, eachParam := range params[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(eachParam), "=")
+			if found && strings.TrimSpace(name) == "q" {
+				parsedQuality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					quality = parsedQuality
+				}
+			}
+		}
+
+		mediaTypeFormat, supported := acceptMediaTypeFormats[mediaType]
+		if supported && quality > bestQuality {
+			bestFormat = mediaTypeFormat
+			bestQuality = quality
+		}
+	}
+
+	if bestFormat == "" {
+		return "", &responseFormatError{
+			StatusCode: http.StatusNotAcceptable,
+			Message:    fmt.Sprintf("none of the accepted media types %q is supported", accept),
+		}
+	}
+	return bestFormat, nil
+}
+
+// supportedResponseFormats return the supported formats sorted alphabetically
+func supportedResponseFormats() []string {
+	formats := make([]string, 0, len(responseFormatContentTypes))
+	for eachFormat := range responseFormatContentTypes {
+		formats = append(formats, eachFormat)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// writeResultRetrieveUsers render the result in the format and write it as response, only the selected fields of each user are rendered (every field when fields is nil)
+func writeResultRetrieveUsers(w http.ResponseWriter, r *http.Request, format string, fields []string, resultObj *model.ResultRetrieveUsers) {
+	resultObj = resultWithRequestID(r, resultObj).(*model.ResultRetrieveUsers)
+	var renderObj interface{} = resultObj
+	if fields != nil && format != RESPONSE_FORMAT_CSV {
+		// Only JSON formats support sparse fields besides CSV
+		sparseResultObj, err := sparseResult(resultObj, fields)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf("unexpected error: %v", err)))
+			return
+		}
+		renderObj = sparseResultObj
+	}
+
+	var responseData []byte
+	var err error
+	switch format {
+	case RESPONSE_FORMAT_JSON_COMPACT:
+		writeEncodedJSON(w, r, http.StatusOK, responseFormatContentTypes[format], renderObj, false)
+		return
+	case RESPONSE_FORMAT_CSV:
+		responseData, err = renderCSV(resultObj, fields)
+	case RESPONSE_FORMAT_NDJSON:
+		responseData, err = renderNDJSON(resultObj)
+	case RESPONSE_FORMAT_YAML:
+		responseData, err = yaml.Marshal(resultObj)
+	case RESPONSE_FORMAT_XML:
+		responseData, err = renderXML(resultObj)
+	default:
+		writeJSONResponse(w, r, renderObj)
+		return
+	}
+
+	if err == nil {
+		w.Header().Set("Content-Type", responseFormatContentTypes[format])
+		w.WriteHeader(http.StatusOK)
+		w.Write(responseData)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("unexpected error: %v", err)))
+	}
+}
+
+// csvColumn a column of the CSV users section
+type csvColumn struct {
+	Name  string
+	Value func(userInfo *model.GithubUserInfo) string
+}
+
+// csvUserColumns columns of the CSV users section, named after the JSON fields
+var csvUserColumns = []csvColumn{
+	{Name: "login", Value: func(u *model.GithubUserInfo) string { return u.Login }},
+	{Name: "name", Value: func(u *model.GithubUserInfo) string { return u.Name }},
+	{Name: "company", Value: func(u *model.GithubUserInfo) string { return u.Company }},
+	{Name: "followers", Value: func(u *model.GithubUserInfo) string { return strconv.Itoa(u.Followers) }},
+	{Name: "following", Value: func(u *model.GithubUserInfo) string { return strconv.Itoa(u.Following) }},
+	{Name: "public_repos", Value: func(u *model.GithubUserInfo) string { return strconv.Itoa(u.PublicRepos) }},
+	{Name: "avg_followers_per_public_repo", Value: func(u *model.GithubUserInfo) string {
+		return strconv.FormatFloat(float64(u.AvgFollowersPerPublicRepo), 'f', -1, 32)
+	}},
+}
+
+// csvRepoStatsColumns columns added to the CSV users section when repository statistics are included
+var csvRepoStatsColumns = []csvColumn{
+	{Name: "total_stargazers", Value: func(u *model.GithubUserInfo) string {
+		if u.RepoStats == nil {
+			return ""
+		}
+		return strconv.Itoa(u.RepoStats.TotalStargazers)
+	}},
+	{Name: "total_forks", Value: func(u *model.GithubUserInfo) string {
+		if u.RepoStats == nil {
+			return ""
+		}
+		return strconv.Itoa(u.RepoStats.TotalForks)
+	}},
+	{Name: "most_starred_repo", Value: func(u *model.GithubUserInfo) string {
+		if u.RepoStats == nil || u.RepoStats.MostStarredRepo == nil {
+			return ""
+		}
+		return u.RepoStats.MostStarredRepo.FullName
+	}},
+	{Name: "languages", Value: func(u *model.GithubUserInfo) string {
+		if u.RepoStats == nil {
+			return ""
+		}
+		// Language:repo_count:bytes separated by ;
+		languages := make([]string, 0, len(u.RepoStats.Languages))
+		for _, eachLanguage := range u.RepoStats.Languages {
+			languages = append(languages, fmt.Sprintf("%s:%d:%d", eachLanguage.Language, eachLanguage.RepoCount, eachLanguage.Bytes))
+		}
+		return strings.Join(languages, ";")
+	}},
+}
+
+// renderCSV render users as CSV rows with the selected fields (every field when fields is nil), errors follow in a separate section after an empty line
+func renderCSV(resultObj *model.ResultRetrieveUsers, fields []string) ([]byte, error) {
+	columns := make([]csvColumn, 0, len(csvUserColumns)+len(csvRepoStatsColumns))
+	for _, eachColumn := range csvUserColumns {
+		if hasUserField(fields, eachColumn.Name) {
+			columns = append(columns, eachColumn)
+		}
+	}
+	if fields != nil && hasUserField(fields, USER_FIELD_REPO_STATS) {
+		columns = append(columns, csvRepoStatsColumns...)
+	} else if fields == nil {
+		for _, eachUser := range resultObj.Users {
+			if eachUser.RepoStats != nil {
+				columns = append(columns, csvRepoStatsColumns...)
+				break
+			}
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	csvWriter := csv.NewWriter(buffer)
+	header := make([]string, 0, len(columns))
+	for _, eachColumn := range columns {
+		header = append(header, eachColumn.Name)
+	}
+	csvWriter.Write(header)
+	for _, eachUser := range resultObj.Users {
+		row := make([]string, 0, len(columns))
+		for _, eachColumn := range columns {
+			row = append(row, csvSafeCell(eachColumn.Value(eachUser)))
+		}
+		csvWriter.Write(row)
+	}
+	csvWriter.Flush()
+
+	if len(resultObj.Errors) > 0 {
+		buffer.WriteString("\n")
+		csvWriter.Write([]string{"error"})
+		for _, eachError := range resultObj.Errors {
+			csvWriter.Write([]string{csvSafeCell(eachError.Message)})
+		}
+		csvWriter.Flush()
+	}
+	return buffer.Bytes(), csvWriter.Error()
+}
+
+// csvSafeCell prefix the value with ' when a spreadsheet would evaluate it as a formula (starting with =, +, -, @, tab or carriage return),
+// the profiles and the usernames echoed by the errors are controlled by the users
+func csvSafeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ndjsonFrame a line of NDJSON output
+type ndjsonFrame struct {
+	Type    string                `json:"type"`
+	User    *model.GithubUserInfo `json:"user,omitempty"`
+	Error   *model.ResultError    `json:"error,omitempty"`
+	Summary *streamSummary        `json:"summary,omitempty"`
+}
+
+// renderNDJSON render one line per user ({"type":"user","user":{...}}) followed by one line per error ({"type":"error","error":{...}})
+func renderNDJSON(resultObj *model.ResultRetrieveUsers) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	for _, eachUser := range resultObj.Users {
+		err := encoder.Encode(&ndjsonFrame{Type: "user", User: eachUser})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, eachError := range resultObj.Errors {
+		err := encoder.Encode(&ndjsonFrame{Type: "error", Error: eachError})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// xmlResultRetrieveUsers root element of the XML output
+type xmlResultRetrieveUsers struct {
+	XMLName xml.Name `xml:"result"`
+	*model.ResultRetrieveUsers
+}
+
+// renderXML render the result as XML document with <result> root element
+func renderXML(resultObj *model.ResultRetrieveUsers) ([]byte, error) {
+	responseData, err := xml.MarshalIndent(&xmlResultRetrieveUsers{ResultRetrieveUsers: resultObj}, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), responseData...), nil
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNegotiateResponseFormat(t *testing.T) {
+	tests := map[string]struct {
+		Format             string
+		Accept             string
+		Expected           string
+		ExpectedStatusCode int
+	}{
+		"No format and no Accept header": {
+			Expected: RESPONSE_FORMAT_JSON,
+		},
+		"Format query parameter wins over Accept header": {
+			Format:   "csv",
+			Accept:   "application/xml",
+			Expected: RESPONSE_FORMAT_CSV,
+		},
+		"Unsupported format query parameter": {
+			Format:             "pdf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		"Accept header with quality values": {
+			Accept:   "application/xml;q=0.5, application/x-ndjson;q=0.9, */*;q=0.1",
+			Expected: RESPONSE_FORMAT_NDJSON,
+		},
+		"Accept header from a browser": {
+			Accept:   "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			Expected: RESPONSE_FORMAT_XML,
+		},
+		"Accept header with yaml": {
+			Accept:   "application/yaml",
+			Expected: RESPONSE_FORMAT_YAML,
+		},
+		"Accept header without supported media type": {
+			Accept:             "application/pdf",
+			ExpectedStatusCode: http.StatusNotAcceptable,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/retrieveUsers?format=%v", test.Format), nil)
+			if test.Accept != "" {
+				request.Header.Set("Accept", test.Accept)
+			}
+
+			result, err := negotiateResponseFormat(request)
+			if test.ExpectedStatusCode != 0 {
+				formatErr, ok := err.(*responseFormatError)
+				if !ok || formatErr.StatusCode != test.ExpectedStatusCode {
+					t.Errorf("expected error with status %v, got %v", test.ExpectedStatusCode, err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if result != test.Expected {
+				t.Errorf("expected %v, got %v", test.Expected, result)
+			}
+		})
+	}
+}
+
+func TestRetrieveUsersFormats(t *testing.T) {
+	tests := map[string]struct {
+		Format              string
+		ExpectedContentType string
+		Parse               func(body []byte) (*model.ResultRetrieveUsers, error)
+	}{
+		"Compact JSON": {
+			Format:              RESPONSE_FORMAT_JSON_COMPACT,
+			ExpectedContentType: "application/json",
+			Parse: func(body []byte) (*model.ResultRetrieveUsers, error) {
+				if bytes.Contains(bytes.TrimSuffix(body, []byte("\n")), []byte("\n")) {
+					return nil, fmt.Errorf("expected compact JSON, got %s", body)
+				}
+				resultObj := &model.ResultRetrieveUsers{}
+				return resultObj, json.Unmarshal(body, resultObj)
+			},
+		},
+		"CSV": {
+			Format:              RESPONSE_FORMAT_CSV,
+			ExpectedContentType: "text/csv; charset=utf-8",
+			Parse: func(body []byte) (*model.ResultRetrieveUsers, error) {
+				resultObj := &model.ResultRetrieveUsers{}
+				sections := strings.SplitN(string(body), "\n\n", 2)
+				userRecords, err := csv.NewReader(strings.NewReader(sections[0])).ReadAll()
+				if err != nil {
+					return nil, err
+				}
+				if strings.Join(userRecords[0], ",") != "login,name,company,followers,following,public_repos,avg_followers_per_public_repo" {
+					return nil, fmt.Errorf("unexpected header %v", userRecords[0])
+				}
+				for _, eachRecord := range userRecords[1:] {
+					resultObj.Users = append(resultObj.Users, &model.GithubUserInfo{Login: eachRecord[0]})
+				}
+				if len(sections) == 2 {
+					errorRecords, err := csv.NewReader(strings.NewReader(sections[1])).ReadAll()
+					if err != nil {
+						return nil, err
+					}
+					for _, eachRecord := range errorRecords[1:] {
+						resultObj.Errors = append(resultObj.Errors, &model.ResultError{Message: eachRecord[0]})
+					}
+				}
+				return resultObj, nil
+			},
+		},
+		"NDJSON": {
+			Format:              RESPONSE_FORMAT_NDJSON,
+			ExpectedContentType: "application/x-ndjson",
+			Parse: func(body []byte) (*model.ResultRetrieveUsers, error) {
+				resultObj := &model.ResultRetrieveUsers{}
+				scanner := bufio.NewScanner(bytes.NewReader(body))
+				for scanner.Scan() {
+					frame := &ndjsonFrame{}
+					err := json.Unmarshal(scanner.Bytes(), frame)
+					if err != nil {
+						return nil, err
+					}
+					if frame.User != nil {
+						resultObj.Users = append(resultObj.Users, frame.User)
+					}
+					if frame.Error != nil {
+						resultObj.Errors = append(resultObj.Errors, frame.Error)
+					}
+				}
+				return resultObj, nil
+			},
+		},
+		"YAML": {
+			Format:              RESPONSE_FORMAT_YAML,
+			ExpectedContentType: "application/yaml",
+			Parse: func(body []byte) (*model.ResultRetrieveUsers, error) {
+				resultObj := &model.ResultRetrieveUsers{}
+				return resultObj, yaml.Unmarshal(body, resultObj)
+			},
+		},
+		"XML": {
+			Format:              RESPONSE_FORMAT_XML,
+			ExpectedContentType: "application/xml; charset=utf-8",
+			Parse: func(body []byte) (*model.ResultRetrieveUsers, error) {
+				resultObj := &xmlResultRetrieveUsers{ResultRetrieveUsers: &model.ResultRetrieveUsers{}}
+				err := xml.Unmarshal(body, resultObj)
+				return resultObj.ResultRetrieveUsers, err
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			target := fmt.Sprintf("/retrieveUsers?usernames=b,a,notfound&format=%v", test.Format)
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+			s.retrieveUsers(responseRecorder, request)
+
+			if contentType := responseRecorder.Header().Get("Content-Type"); contentType != test.ExpectedContentType {
+				t.Errorf("expected content type %v, got %v", test.ExpectedContentType, contentType)
+			}
+
+			resultObj, err := test.Parse(responseRecorder.Body.Bytes())
+			if err != nil {
+				t.Fatalf("expected no error when parsing response, got %v", err)
+			}
+			if len(resultObj.Users) != 2 || resultObj.Users[0].Login != "a" || resultObj.Users[1].Login != "b" {
+				t.Errorf("expected users a and b, got %v", resultObj.Users)
+			}
+			if len(resultObj.Errors) != 1 || !strings.Contains(resultObj.Errors[0].Message, "notfound") {
+				t.Errorf("expected an error for notfound, got %v", resultObj.Errors)
+			}
+		})
+	}
+}
+
+func TestRenderCSVFormulas(t *testing.T) {
+	tests := map[string]struct {
+		Value    string
+		Expected string
+	}{
+		"Test plain value":       {Value: "Machship", Expected: "Machship"},
+		"Test equals formula":    {Value: "=HYPERLINK(\"http://x\")", Expected: "'=HYPERLINK(\"http://x\")"},
+		"Test plus formula":      {Value: "+1+1", Expected: "'+1+1"},
+		"Test minus formula":     {Value: "-1+1", Expected: "'-1+1"},
+		"Test at formula":        {Value: "@SUM(A1)", Expected: "'@SUM(A1)"},
+		"Test tab prefix":        {Value: "\t=1", Expected: "'\t=1"},
+		"Test carriage return":   {Value: "\r=1", Expected: "'\r=1"},
+		"Test formula not first": {Value: "a=1", Expected: "a=1"},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			resultObj := &model.ResultRetrieveUsers{
+				Users:  []*model.GithubUserInfo{{Login: "a", Name: test.Value, Company: test.Value}},
+				Errors: []*model.ResultError{{Message: test.Value}},
+			}
+			body, err := renderCSV(resultObj, nil)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			sections := strings.SplitN(string(body), "\n\n", 2)
+			userRecords, err := csv.NewReader(strings.NewReader(sections[0])).ReadAll()
+			if err != nil || len(userRecords) != 2 {
+				t.Fatalf("expected a header and a user row, got %v %v", userRecords, err)
+			}
+			if userRecords[1][0] != "a" || userRecords[1][1] != test.Expected || userRecords[1][2] != test.Expected {
+				t.Errorf("expected name and company %q, got %q", test.Expected, userRecords[1])
+			}
+			errorRecords, err := csv.NewReader(strings.NewReader(sections[1])).ReadAll()
+			if err != nil || len(errorRecords) != 2 || errorRecords[1][0] != test.Expected {
+				t.Errorf("expected error %q, got %q %v", test.Expected, errorRecords, err)
+			}
+		})
+	}
+}
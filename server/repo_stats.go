@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// retrieveRepoStats compute aggregated statistics from the public repositories owned by the user (forks are excluded)
+func (s *Server) retrieveRepoStats(client *http.Client, username string) (*model.GithubRepoStats, error) {
+	// Page through the repositories owned by the user
+	repos := make([]*model.GithubRepoInfo, 0)
+	nextURL := fmt.Sprintf("%s/%s/%s/repos?type=owner&per_page=100", s.config.githubAPIURL, s.config.githubAPIUser, url.PathEscape(username))
+	for page := 0; nextURL != "" && page < s.config.repoStatsMaxPages; page++ {
+		pageRepos := make([]*model.GithubRepoInfo, 0)
+		next, err := s.githubGet(client, nextURL, &pageRepos)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, pageRepos...)
+		nextURL = next
+	}
+
+	stats := &model.GithubRepoStats{
+		Languages: make([]*model.LanguageStat, 0),
+	}
+	languageStatMap := make(map[string]*model.LanguageStat)
+	sourceRepos := make([]*model.GithubRepoInfo, 0, len(repos))
+	for _, eachRepo := range repos {
+		if eachRepo.Fork {
+			continue
+		}
+		sourceRepos = append(sourceRepos, eachRepo)
+
+		stats.TotalStargazers += eachRepo.StargazersCount
+		stats.TotalForks += eachRepo.ForksCount
+		if stats.MostStarredRepo == nil || eachRepo.StargazersCount > stats.MostStarredRepo.StargazersCount {
+			stats.MostStarredRepo = eachRepo
+		}
+
+		// Histogram by repo count use the primary language of the repository
+		if eachRepo.Language != "" {
+			languageStat(languageStatMap, eachRepo.Language).RepoCount++
+		}
+	}
+
+	// Histogram by bytes require one call per repository, only the most starred repositories are inspected
+	sort.SliceStable(sourceRepos, func(i, j int) bool {
+		return sourceRepos[i].StargazersCount > sourceRepos[j].StargazersCount
+	})
+	for i, eachRepo := range sourceRepos {
+		if i >= s.config.repoStatsMaxLanguageRepos {
+			break
+		}
+
+		languageBytes := make(map[string]int)
+		apiURL := fmt.Sprintf("%s/repos/%s/languages", s.config.githubAPIURL, eachRepo.FullName)
+		_, err := s.githubGet(client, apiURL, &languageBytes)
+		if err != nil {
+			return nil, err
+		}
+		for language, bytes := range languageBytes {
+			languageStat(languageStatMap, language).Bytes += bytes
+		}
+		stats.LanguagesSampledRepos++
+	}
+	stats.LanguagesTruncated = stats.LanguagesSampledRepos < len(sourceRepos)
+
+	for _, eachLanguageStat := range languageStatMap {
+		stats.Languages = append(stats.Languages, eachLanguageStat)
+	}
+	sort.SliceStable(stats.Languages, func(i, j int) bool {
+		if stats.Languages[i].RepoCount != stats.Languages[j].RepoCount {
+			return stats.Languages[i].RepoCount > stats.Languages[j].RepoCount
+		}
+		if stats.Languages[i].Bytes != stats.Languages[j].Bytes {
+			return stats.Languages[i].Bytes > stats.Languages[j].Bytes
+		}
+		return strings.Compare(stats.Languages[i].Language, stats.Languages[j].Language) < 0
+	})
+
+	return stats, nil
+}
+
+// languageStat return the stat of the language from the map, create a new one if it does not exist yet
+func languageStat(languageStatMap map[string]*model.LanguageStat, language string) *model.LanguageStat {
+	stat, found := languageStatMap[language]
+	if !found {
+		stat = &model.LanguageStat{
+			Language: language,
+		}
+		languageStatMap[language] = stat
+	}
+	return stat
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRetrieveUsersWithRepoStats(t *testing.T) {
+	tests := map[string]struct {
+		Host                    string
+		Port                    int
+		GithubAPIUser           string
+		Username                string
+		IncludeRepoStats        bool
+		ExpectedTotalStargazers int
+		ExpectedTotalForks      int
+		ExpectedMostStarredRepo string
+		MaxLanguageRepos        int
+		ExpectedLanguages       []model.LanguageStat
+		ExpectedSampledRepos    int
+		ExpectedTruncated       bool
+	}{
+		"Test repository statistics are computed when requested (forks excluded, paged)": {
+			Host:                    "",
+			Port:                    8777,
+			GithubAPIUser:           "users",
+			Username:                "abc",
+			IncludeRepoStats:        true,
+			ExpectedTotalStargazers: 17,
+			ExpectedTotalForks:      6,
+			ExpectedMostStarredRepo: "abc/b",
+			ExpectedLanguages: []model.LanguageStat{
+				{Language: "Go", RepoCount: 2, Bytes: 300},
+				{Language: "Rust", RepoCount: 1, Bytes: 50},
+				{Language: "Shell", RepoCount: 0, Bytes: 10},
+			},
+			ExpectedSampledRepos: 3,
+		},
+		"Test language bytes are counted on the most starred repositories only": {
+			Host:                    "",
+			Port:                    8777,
+			GithubAPIUser:           "users",
+			Username:                "abc",
+			IncludeRepoStats:        true,
+			MaxLanguageRepos:        2,
+			ExpectedTotalStargazers: 17,
+			ExpectedTotalForks:      6,
+			ExpectedMostStarredRepo: "abc/b",
+			ExpectedLanguages: []model.LanguageStat{
+				{Language: "Go", RepoCount: 2, Bytes: 300},
+				{Language: "Rust", RepoCount: 1, Bytes: 0},
+				{Language: "Shell", RepoCount: 0, Bytes: 10},
+			},
+			ExpectedSampledRepos: 2,
+			ExpectedTruncated:    true,
+		},
+		"Test repository statistics are omitted when not requested": {
+			Host:             "",
+			Port:             8777,
+			GithubAPIUser:    "users",
+			Username:         "abc",
+			IncludeRepoStats: false,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// Create an API test server
+			var githubAPITestServer *httptest.Server
+			githubAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var responseData interface{}
+				switch r.URL.Path {
+				case "/users/abc":
+					responseData = model.GithubUserInfo{Login: "abc", Name: "abc", Followers: 3, PublicRepos: 4}
+				case "/users/abc/repos":
+					if r.URL.Query().Get("page") == "" {
+						w.Header().Set("Link", fmt.Sprintf(`<%s/users/abc/repos?page=2>; rel="next"`, githubAPITestServer.URL))
+						responseData = []model.GithubRepoInfo{
+							{Name: "a", FullName: "abc/a", Language: "Go", StargazersCount: 5, ForksCount: 1},
+							{Name: "b", FullName: "abc/b", Language: "Go", StargazersCount: 10, ForksCount: 2},
+						}
+					} else {
+						responseData = []model.GithubRepoInfo{
+							{Name: "c", FullName: "abc/c", Language: "Rust", StargazersCount: 2, ForksCount: 3},
+							{Name: "d", FullName: "abc/d", Language: "Go", StargazersCount: 100, ForksCount: 100, Fork: true},
+						}
+					}
+				case "/repos/abc/a/languages":
+					responseData = map[string]int{"Go": 100}
+				case "/repos/abc/b/languages":
+					responseData = map[string]int{"Go": 200, "Shell": 10}
+				case "/repos/abc/c/languages":
+					responseData = map[string]int{"Rust": 50}
+				default:
+					t.Errorf("unexpected call to %v", r.URL.Path)
+					responseData = struct {
+						Message string `json:"message"`
+					}{
+						Message: "Not Found",
+					}
+				}
+
+				jsonString, _ := json.Marshal(responseData)
+				w.Write([]byte(jsonString))
+			}))
+			defer githubAPITestServer.Close()
+
+			// Using the API test server to mock API calling
+			config := NewServerConfig(test.Host, test.Port, githubAPITestServer.URL, test.GithubAPIUser)
+			s := NewServer(config)
+			if test.MaxLanguageRepos > 0 {
+				s.config.repoStatsMaxLanguageRepos = test.MaxLanguageRepos
+			}
+			responseRecorder := httptest.NewRecorder()
+			target := fmt.Sprintf("/retrieveUsers?usernames=%v&includeRepoStats=%v", test.Username, test.IncludeRepoStats)
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+			s.retrieveUsers(responseRecorder, request)
+
+			response := responseRecorder.Result()
+			defer response.Body.Close()
+			responseData, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("expected no error when read data from response body, got err = %v", err)
+			}
+
+			jsonResponseData := &model.ResultRetrieveUsers{}
+			err = json.Unmarshal([]byte(responseData), &jsonResponseData)
+			if err != nil {
+				t.Fatalf("expected no error when unmarshal response data, got err = %v", err)
+			}
+			if len(jsonResponseData.Errors) > 0 {
+				t.Fatalf("expected no error from response, got errors = %v", jsonResponseData.Errors)
+			}
+			if len(jsonResponseData.Users) != 1 {
+				t.Fatalf("expected 1 user record, got %v", len(jsonResponseData.Users))
+			}
+
+			repoStats := jsonResponseData.Users[0].RepoStats
+			if !test.IncludeRepoStats {
+				if repoStats != nil {
+					t.Errorf("expected no repository statistics, got %v", repoStats)
+				}
+				return
+			}
+
+			if repoStats == nil {
+				t.Fatalf("expected repository statistics, got none")
+			}
+			if repoStats.TotalStargazers != test.ExpectedTotalStargazers {
+				t.Errorf("expected total stargazers = %v, got %v", test.ExpectedTotalStargazers, repoStats.TotalStargazers)
+			}
+			if repoStats.TotalForks != test.ExpectedTotalForks {
+				t.Errorf("expected total forks = %v, got %v", test.ExpectedTotalForks, repoStats.TotalForks)
+			}
+			if repoStats.MostStarredRepo == nil || repoStats.MostStarredRepo.FullName != test.ExpectedMostStarredRepo {
+				t.Errorf("expected most starred repo = %v, got %v", test.ExpectedMostStarredRepo, repoStats.MostStarredRepo)
+			}
+			if len(repoStats.Languages) != len(test.ExpectedLanguages) {
+				t.Fatalf("expected %v languages, got %v", len(test.ExpectedLanguages), len(repoStats.Languages))
+			}
+			for i, eachExpectedLanguage := range test.ExpectedLanguages {
+				if *repoStats.Languages[i] != eachExpectedLanguage {
+					t.Errorf("expected language at index %d = %v, got %v", i, eachExpectedLanguage, *repoStats.Languages[i])
+				}
+			}
+			if repoStats.LanguagesSampledRepos != test.ExpectedSampledRepos || repoStats.LanguagesTruncated != test.ExpectedTruncated {
+				t.Errorf("expected %v repositories sampled for languages (truncated %v), got %v (truncated %v)", test.ExpectedSampledRepos, test.ExpectedTruncated, repoStats.LanguagesSampledRepos, repoStats.LanguagesTruncated)
+			}
+
+			// Repository statistics should be cached alongside the profile
+			cachedUserInfo := s.githubUserInfoCache.Get(test.Username)
+			if cachedUserInfo == nil || cachedUserInfo.RepoStats == nil {
+				t.Errorf("expected repository statistics to be cached, got %v", cachedUserInfo)
+			} else if !strings.Contains(cachedUserInfo.String(), "repo_stats") {
+				t.Errorf("expected cached string to contain repo_stats, got %v", cachedUserInfo.String())
+			}
+		})
+	}
+}
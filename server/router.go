@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// API_PREFIX_V1 path prefix of the version 1 of the REST API
+	API_PREFIX_V1 = "/v1"
+)
+
+// contextKey type of the keys of the values stored in the request context by the server
+type contextKey string
+
+const (
+	// apiPrefixContextKey key of the API prefix the request was routed through
+	apiPrefixContextKey contextKey = "apiPrefix"
+)
+
+// restRoute a REST route, the pattern is relative to the prefix of the API version (the handler sees the path without the prefix)
+type restRoute struct {
+	Pattern string
+	Handler http.HandlerFunc
+}
+
+// apiVersion a version of the REST API, each version has its own routes so a new version can change the response shape while the previous one is still served
+type apiVersion struct {
+	Prefix string
+	Routes []restRoute
+}
+
+// apiVersions return the versions of the REST API served by the server
+func (s *Server) apiVersions() []apiVersion {
+	return []apiVersion{
+		{Prefix: API_PREFIX_V1, Routes: s.v1Routes()},
+	}
+}
+
+// v1Routes return the routes of the version 1 of the REST API, they are also served without prefix as deprecated aliases
+func (s *Server) v1Routes() []restRoute {
+	return []restRoute{
+		{Pattern: "/retrieveUsers", Handler: s.retrieveUsers},
+		{Pattern: "/orgs/", Handler: s.retrieveOrganization},
+		{Pattern: "/users/", Handler: s.retrieveUserConnection},
+		{Pattern: "/relationships", Handler: s.relationships},
+		{Pattern: "/search/users", Handler: s.searchUsers},
+		{Pattern: "/jobs", Handler: s.submitJob},
+		{Pattern: "/jobs/", Handler: s.retrieveJob},
+	}
+}
+
+// versionedHandler strip the API prefix from the path before calling the handler, the prefix is kept in the request context to build URLs
+func versionedHandler(prefix string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routedRequest := r.WithContext(context.WithValue(r.Context(), apiPrefixContextKey, prefix))
+		routedURL := *r.URL
+		routedURL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		routedURL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+		routedRequest.URL = &routedURL
+		handler(w, routedRequest)
+	})
+}
+
+// deprecatedHandler serve the unversioned alias of a route with the Deprecation (RFC 9745) and Sunset (RFC 8594) headers and a link to the successor version
+func (s *Server) deprecatedHandler(successorPrefix string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", s.config.legacyRoutesDeprecation.Unix()))
+		w.Header().Set("Sunset", s.config.legacyRoutesSunset.UTC().Format(http.TimeFormat))
+		w.Header().Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successorPrefix, r.URL.Path))
+		handler(w, r)
+	})
+}
+
+// requestAPIPrefix return the prefix of the API version the request was routed through (empty for the deprecated aliases)
+func requestAPIPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(apiPrefixContextKey).(string)
+	return prefix
+}
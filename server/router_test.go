@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	tests := map[string]struct {
+		Path               string
+		ExpectedDeprecated bool
+		ExpectedLink       string
+	}{
+		"Test v1 retrieveUsers": {
+			Path:               "/v1/retrieveUsers?usernames=a",
+			ExpectedDeprecated: false,
+		},
+		"Test v1 organization": {
+			Path:               "/v1/orgs/apache",
+			ExpectedDeprecated: false,
+		},
+		"Test deprecated retrieveUsers alias": {
+			Path:               "/retrieveUsers?usernames=a",
+			ExpectedDeprecated: true,
+			ExpectedLink:       `</v1/retrieveUsers>; rel="successor-version"`,
+		},
+		"Test deprecated organization alias": {
+			Path:               "/orgs/apache",
+			ExpectedDeprecated: true,
+			ExpectedLink:       `</v1/orgs/apache>; rel="successor-version"`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users").
+				WithLegacyRoutesSunset(time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC))
+			s := NewServer(config)
+			s.registerHandlers()
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, test.Path, nil))
+
+			if responseRecorder.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, responseRecorder.Code)
+			}
+			if deprecated := responseRecorder.Header().Get("Deprecation") != ""; deprecated != test.ExpectedDeprecated {
+				t.Errorf("expected deprecated %v, got Deprecation header %q", test.ExpectedDeprecated, responseRecorder.Header().Get("Deprecation"))
+			}
+			if test.ExpectedDeprecated {
+				if sunset := responseRecorder.Header().Get("Sunset"); sunset != "Mon, 19 Apr 2027 00:00:00 GMT" {
+					t.Errorf("unexpected Sunset header %q", sunset)
+				}
+				if link := responseRecorder.Header().Get("Link"); link != test.ExpectedLink {
+					t.Errorf("expected Link header %q, got %q", test.ExpectedLink, link)
+				}
+			}
+
+			resultObj := make(map[string]json.RawMessage)
+			err := json.Unmarshal(responseRecorder.Body.Bytes(), &resultObj)
+			if err != nil {
+				t.Fatalf("expected no error when parsing response, got %v", err)
+			}
+			if string(resultObj["errors"]) != "[]" {
+				t.Errorf("expected no errors, got %s", resultObj["errors"])
+			}
+		})
+	}
+}
+
+func TestVersionedJobURLs(t *testing.T) {
+	tests := map[string]struct {
+		Prefix string
+	}{
+		"Test v1 jobs":               {Prefix: API_PREFIX_V1},
+		"Test deprecated jobs alias": {Prefix: ""},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+			s.registerHandlers()
+			responseRecorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, test.Prefix+"/jobs", strings.NewReader(`{"usernames":["a"]}`))
+			request.Header.Set("Content-Type", "application/json")
+			s.serverMux.ServeHTTP(responseRecorder, request)
+
+			location := responseRecorder.Header().Get("Location")
+			if !strings.HasPrefix(location, test.Prefix+"/jobs/") {
+				t.Fatalf("expected location under %v/jobs/, got %v", test.Prefix, location)
+			}
+			status := waitForJob(t, s, strings.TrimPrefix(location, test.Prefix+"/jobs/"))
+
+			responseRecorder = httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, location, nil))
+			if responseRecorder.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, responseRecorder.Code)
+			}
+			polledStatus := &jobStatus{}
+			json.Unmarshal(responseRecorder.Body.Bytes(), polledStatus)
+			if polledStatus.ResultURL != test.Prefix+"/jobs/"+status.ID+"/result" {
+				t.Errorf("expected result URL under %v, got %v", test.Prefix, polledStatus.ResultURL)
+			}
+
+			responseRecorder = httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, polledStatus.ResultURL, nil))
+			resultObj := &model.ResultRetrieveUsers{}
+			json.Unmarshal(responseRecorder.Body.Bytes(), resultObj)
+			if len(resultObj.Users) != 1 || resultObj.Users[0].Login != "a" {
+				t.Errorf("expected user a, got %v", resultObj.Users)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// GITHUB_SEARCH_MAX_RESULTS github search API only return the first 1000 results of a search
+	GITHUB_SEARCH_MAX_RESULTS = 1000
+)
+
+// githubSearchUsersResponse response of github search users API
+type githubSearchUsersResponse struct {
+	TotalCount        int                     `json:"total_count"`
+	IncompleteResults bool                    `json:"incomplete_results"`
+	Items             []*model.GithubUserInfo `json:"items"`
+}
+
+// searchUsers handling searching github users by query, location, language and minFollowers
+func (s *Server) searchUsers(w http.ResponseWriter, r *http.Request) {
+	client := s.newGithubClient(r.Context())
+
+	resultObj := &model.ResultRetrieveUserConnection{
+		Errors: make([]*model.ResultError, 0),
+	}
+
+	searchQuery, err := buildUserSearchQuery(r.FormValue("query"), r.FormValue("location"), r.FormValue("language"), r.FormValue("minFollowers"))
+	if err != nil {
+		resultObj.Errors = append(resultObj.Errors, &model.ResultError{
+			Message: err.Error(),
+		})
+	} else {
+		first, offset, err := parsePageArgs(r.FormValue("first"), r.FormValue("after"))
+		if err != nil {
+			resultObj.Errors = append(resultObj.Errors, &model.ResultError{
+				Message: err.Error(),
+			})
+		} else {
+			connection, searchErrors := s.searchUserConnection(client, searchQuery, first, offset)
+			resultObj.Connection = connection
+			resultObj.Errors = append(resultObj.Errors, searchErrors...)
+		}
+	}
+
+	writeJSONResponse(w, r, resultObj)
+}
+
+// buildUserSearchQuery build github search query from the free text query and the qualifiers
+func buildUserSearchQuery(query string, location string, language string, minFollowers string) (string, error) {
+	terms := make([]string, 0)
+	if len(strings.TrimSpace(query)) > 0 {
+		terms = append(terms, strings.TrimSpace(query))
+	}
+	if len(strings.TrimSpace(location)) > 0 {
+		terms = append(terms, "location:"+searchQualifierValue(location))
+	}
+	if len(strings.TrimSpace(language)) > 0 {
+		terms = append(terms, "language:"+searchQualifierValue(language))
+	}
+	if len(strings.TrimSpace(minFollowers)) > 0 {
+		followers, err := strconv.Atoi(strings.TrimSpace(minFollowers))
+		if err != nil || followers < 0 {
+			return "", fmt.Errorf("invalid minFollowers %q, expected a non-negative number", minFollowers)
+		}
+		terms = append(terms, fmt.Sprintf("followers:>=%d", followers))
+	}
+
+	if len(terms) == 0 {
+		return "", errors.New("at least one of query, location, language or minFollowers is required")
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// searchQualifierValue quote the qualifier value when it contains spaces (e.g. location:"San Francisco")
+func searchQualifierValue(value string) string {
+	value = strings.ReplaceAll(strings.TrimSpace(value), `"`, "")
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// searchUserConnection return a page of the search results, each user is enriched through the user cache
+func (s *Server) searchUserConnection(client *http.Client, searchQuery string, first int, offset int) (*model.GithubUserConnection, []*model.ResultError) {
+	searchErrors := make([]*model.ResultError, 0)
+
+	// Github search API is paged by 100, fetch the pages covering [offset, offset + first)
+	items := make([]*model.GithubUserInfo, 0)
+	totalCount := 0
+	startPage := offset/MAX_PAGE_SIZE + 1
+	endPage := (offset+first-1)/MAX_PAGE_SIZE + 1
+	for page := startPage; page <= endPage && (page-1)*MAX_PAGE_SIZE < GITHUB_SEARCH_MAX_RESULTS; page++ {
+		searchResponse := &githubSearchUsersResponse{}
+		apiURL := fmt.Sprintf("%s/search/users?q=%s&per_page=%d&page=%d", s.config.githubAPIURL, url.QueryEscape(searchQuery), MAX_PAGE_SIZE, page)
+		_, err := s.githubGet(client, apiURL, searchResponse)
+		if err != nil {
+			return nil, append(searchErrors, &model.ResultError{
+				Message: fmt.Sprintf("encounter err when searching users %q: %v", searchQuery, err),
+			})
+		}
+
+		totalCount = searchResponse.TotalCount
+		items = append(items, searchResponse.Items...)
+		if len(searchResponse.Items) < MAX_PAGE_SIZE {
+			break
+		}
+	}
+
+	// Keep only the requested window
+	skip := offset - (startPage-1)*MAX_PAGE_SIZE
+	if skip > len(items) {
+		skip = len(items)
+	}
+	items = items[skip:]
+	if len(items) > first {
+		items = items[:first]
+	}
+
+	reachableCount := totalCount
+	if reachableCount > GITHUB_SEARCH_MAX_RESULTS {
+		reachableCount = GITHUB_SEARCH_MAX_RESULTS
+	}
+	end := offset + len(items)
+	connection := &model.GithubUserConnection{
+		TotalCount: totalCount,
+		Nodes:      make([]*model.GithubUserInfo, 0, len(items)),
+		PageInfo: &model.PageInfo{
+			HasNextPage: end < reachableCount,
+		},
+	}
+	if len(items) > 0 {
+		endCursor := encodeCursor(end)
+		connection.PageInfo.EndCursor = &endCursor
+	}
+
+	// Enrich with the full profiles fetched together, fall back to the search result when the profile is not available
+	logins := make([]string, 0, len(items))
+	for _, eachItem := range items {
+		logins = append(logins, eachItem.Login)
+	}
+	userFetchResults := s.retrieveUserInfos(client, logins)
+	for _, eachItem := range items {
+		userInfo := eachItem
+		var err error
+		if userFetchResult := userFetchResults[eachItem.Login]; userFetchResult != nil {
+			userInfo, err = userFetchResult.userInfo, userFetchResult.err
+		}
+		if err != nil || userInfo == nil || userInfo.Message == GITHUB_API_MESSAGE_USER_NOT_FOUND {
+			var rateLimitErr *rateLimitError
+			if err != nil && !errors.As(err, &rateLimitErr) {
+				searchErrors = append(searchErrors, &model.ResultError{
+					Message: fmt.Sprintf("encounter err for username %q: %v", eachItem.Login, err),
+				})
+			}
+			userInfo = eachItem
+		}
+		connection.Nodes = append(connection.Nodes, userInfo)
+	}
+
+	return connection, searchErrors
+}
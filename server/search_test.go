@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildUserSearchQuery(t *testing.T) {
+	tests := map[string]struct {
+		Query         string
+		Location      string
+		Language      string
+		MinFollowers  string
+		Expected      string
+		ExpectedError bool
+	}{
+		"Query only": {
+			Query:    "tom",
+			Expected: "tom",
+		},
+		"All qualifiers": {
+			Query:        "tom",
+			Location:     "San Francisco",
+			Language:     "go",
+			MinFollowers: "100",
+			Expected:     `tom location:"San Francisco" language:go followers:>=100`,
+		},
+		"Invalid minFollowers": {
+			MinFollowers:  "-1",
+			ExpectedError: true,
+		},
+		"Nothing to search": {
+			Query:         "  ",
+			ExpectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := buildUserSearchQuery(test.Query, test.Location, test.Language, test.MinFollowers)
+			if test.ExpectedError {
+				if err == nil {
+					t.Errorf("expected error, got %v", result)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if result != test.Expected {
+				t.Errorf("expected %q, got %q", test.Expected, result)
+			}
+		})
+	}
+}
+
+// newSearchTestServer return a github API test server with 150 search results (u0...u149) and their profiles
+func newSearchTestServer(t *testing.T, searchRemaining int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var responseData interface{}
+		if r.URL.Path == "/search/users" {
+			w.Header().Set("X-RateLimit-Resource", "search")
+			w.Header().Set("X-RateLimit-Limit", "10")
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(searchRemaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+			searchResponse := githubSearchUsersResponse{
+				TotalCount: 150,
+				Items:      make([]*model.GithubUserInfo, 0),
+			}
+			for i := (page - 1) * perPage; i < page*perPage && i < 150; i++ {
+				searchResponse.Items = append(searchResponse.Items, &model.GithubUserInfo{Login: fmt.Sprintf("u%d", i)})
+			}
+			responseData = searchResponse
+		} else {
+			w.Header().Set("X-RateLimit-Resource", "core")
+			w.Header().Set("X-RateLimit-Remaining", "100")
+			username := strings.TrimPrefix(r.URL.Path, "/users/")
+			responseData = model.GithubUserInfo{Login: username, Name: "Name " + username}
+		}
+
+		jsonString, _ := json.Marshal(responseData)
+		w.Write([]byte(jsonString))
+	}))
+}
+
+func TestSearchUsers(t *testing.T) {
+	tests := map[string]struct {
+		First            string
+		After            string
+		ExpectedLogins   []string
+		ExpectedNextPage bool
+	}{
+		"Test first page": {
+			First:            "3",
+			ExpectedLogins:   []string{"u0", "u1", "u2"},
+			ExpectedNextPage: true,
+		},
+		"Test page spanning two github pages": {
+			First:            "4",
+			After:            encodeCursor(98),
+			ExpectedLogins:   []string{"u98", "u99", "u100", "u101"},
+			ExpectedNextPage: true,
+		},
+		"Test last page": {
+			First:            "10",
+			After:            encodeCursor(145),
+			ExpectedLogins:   []string{"u145", "u146", "u147", "u148", "u149"},
+			ExpectedNextPage: false,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newSearchTestServer(t, 5)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			query := url.Values{}
+			query.Set("query", "u")
+			query.Set("first", test.First)
+			query.Set("after", test.After)
+			request := httptest.NewRequest(http.MethodGet, "/search/users?"+query.Encode(), nil)
+			s.searchUsers(responseRecorder, request)
+
+			response := responseRecorder.Result()
+			defer response.Body.Close()
+			responseData, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("expected no error when read data from response body, got err = %v", err)
+			}
+
+			jsonResponseData := &model.ResultRetrieveUserConnection{}
+			err = json.Unmarshal([]byte(responseData), &jsonResponseData)
+			if err != nil {
+				t.Fatalf("expected no error when unmarshal response data, got err = %v", err)
+			}
+			if len(jsonResponseData.Errors) > 0 {
+				t.Fatalf("expected no error from response, got errors = %v", jsonResponseData.Errors)
+			}
+
+			logins := make([]string, 0)
+			for _, eachNode := range jsonResponseData.Connection.Nodes {
+				logins = append(logins, eachNode.Login)
+				if eachNode.Name != "Name "+eachNode.Login {
+					t.Errorf("expected node %v to be enriched with the profile, got %v", eachNode.Login, eachNode)
+				}
+			}
+			if !reflect.DeepEqual(logins, test.ExpectedLogins) {
+				t.Errorf("expected logins %v, got %v", test.ExpectedLogins, logins)
+			}
+			if jsonResponseData.Connection.TotalCount != 150 {
+				t.Errorf("expected total count 150, got %v", jsonResponseData.Connection.TotalCount)
+			}
+			if jsonResponseData.Connection.PageInfo.HasNextPage != test.ExpectedNextPage {
+				t.Errorf("expected has next page %v, got %v", test.ExpectedNextPage, jsonResponseData.Connection.PageInfo.HasNextPage)
+			}
+		})
+	}
+}
+
+func TestSearchUsersInBatch(t *testing.T) {
+	var graphqlCalls int32
+	githubGraphQLTestServer := newGraphQLTestServer(t, &graphqlCalls)
+	defer githubGraphQLTestServer.Close()
+	githubAPITestServer := newSearchTestServer(t, 5)
+	defer githubAPITestServer.Close()
+
+	config := NewServerConfig("", 8777, githubAPITestServer.URL, "users").
+		WithGithubToken("token").
+		WithGithubUpstream(GITHUB_UPSTREAM_GRAPHQL).
+		WithGithubGraphQLURL(githubGraphQLTestServer.URL + "/graphql")
+	s := NewServer(config)
+	responseRecorder := httptest.NewRecorder()
+	s.searchUsers(responseRecorder, httptest.NewRequest(http.MethodGet, "/search/users?query=u&first=20", nil))
+
+	jsonResponseData := &model.ResultRetrieveUserConnection{}
+	json.Unmarshal(responseRecorder.Body.Bytes(), jsonResponseData)
+	if jsonResponseData.Connection == nil || len(jsonResponseData.Connection.Nodes) != 20 || jsonResponseData.Connection.Nodes[19].Name != "u19" {
+		t.Fatalf("expected 20 users enriched with the profile, got %s", responseRecorder.Body.String())
+	}
+	if graphqlCalls != 1 {
+		t.Errorf("expected the page enriched with one GraphQL call, got %v calls", graphqlCalls)
+	}
+}
+
+func TestSearchUsersRateLimit(t *testing.T) {
+	githubAPITestServer := newSearchTestServer(t, 0)
+	defer githubAPITestServer.Close()
+
+	config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+	s := NewServer(config)
+
+	// First search exhaust the search bucket, the second one should not reach github
+	for i, expectedError := range []bool{false, true} {
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/search/users?query=u", nil)
+		s.searchUsers(responseRecorder, request)
+
+		jsonResponseData := &model.ResultRetrieveUserConnection{}
+		err := json.NewDecoder(responseRecorder.Result().Body).Decode(jsonResponseData)
+		if err != nil {
+			t.Fatalf("expected no error when decode response data, got err = %v", err)
+		}
+		if expectedError && (len(jsonResponseData.Errors) == 0 || !strings.Contains(jsonResponseData.Errors[0].Message, "rate limit")) {
+			t.Errorf("search %d: expected rate limit error, got %v", i, jsonResponseData.Errors)
+		}
+		if !expectedError && len(jsonResponseData.Errors) > 0 {
+			t.Errorf("search %d: expected no error, got %v", i, jsonResponseData.Errors)
+		}
+	}
+
+	// The core bucket is separate and still usable
+	userInfo, err := s.retrieveUserInfo(&http.Client{}, "abc")
+	if err != nil || userInfo.Login != "abc" {
+		t.Errorf("expected user abc to be retrieved, got %v (err = %v)", userInfo, err)
+	}
+}
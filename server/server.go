@@ -2,26 +2,49 @@ package server
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"machshipgithubapi/graph"
 	"machshipgithubapi/graph/model"
+	"net"
 	"net/http"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Server struct {
-	httpServer          *http.Server
-	serverMux           *http.ServeMux
-	config              *ServerConfig
-	githubUserInfoCache *ServerCache[model.GithubUserInfo]
+	httpServer            *http.Server
+	serverMux             *http.ServeMux
+	config                *ServerConfig
+	githubUserInfoCache   *ServerCache[model.GithubUserInfo]
+	githubOrgInfoCache    *ServerCache[model.GithubOrganizationInfo]
+	githubOrgMembersCache *ServerCache[model.GithubOrganizationMembers]
+	githubFollowListCache *ServerCache[model.GithubFollowList]
+	redisClient           *redisClient // client of the Redis server keeping the cache entries, nil with the memory cache backend
+	cluster               *cluster     // replicas sharing the users fetched from github, nil when the server is not clustered
+	githubRateLimiter     *githubRateLimiter
+	githubUserFetcher     githubUserFetcher
+	jobQueue              *jobQueue
+	metrics               *serverMetrics
+	tracerProvider        *sdktrace.TracerProvider
+	tracer                trace.Tracer
+	logger                *logger
+	upstreamHealth        *upstreamHealth
+	listening             atomic.Bool
+	startedAt             time.Time
+	snapshotLock          sync.Mutex         // held while writing the cache snapshot
+	snapshotCtx           context.Context    // done once the periodic cache snapshots are stopped
+	stopSnapshots         context.CancelFunc // stop the periodic cache snapshots
 }
 
 const (
@@ -34,152 +57,416 @@ func NewServer(config *ServerConfig) *Server {
 	serverMux := http.NewServeMux()
 	httpServer := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", config.host, config.port),
-		Handler: serverMux,
+		Handler: compressionHandler(serverMux),
 	}
-	return &Server{
-		httpServer:          httpServer,
-		serverMux:           serverMux,
-		githubUserInfoCache: NewServerCache[model.GithubUserInfo](config.defaultCachePartitions),
-		config:              config,
+	s := &Server{
+		httpServer:            httpServer,
+		serverMux:             serverMux,
+		githubUserInfoCache:   NewServerCache[model.GithubUserInfo](config.defaultCachePartitions).WithTTL(config.cacheTTL),
+		githubOrgInfoCache:    NewServerCache[model.GithubOrganizationInfo](config.defaultCachePartitions).WithTTL(config.cacheTTL),
+		githubOrgMembersCache: NewServerCache[model.GithubOrganizationMembers](config.defaultCachePartitions).WithTTL(config.cacheTTL),
+		githubFollowListCache: NewServerCache[model.GithubFollowList](config.defaultCachePartitions).WithTTL(config.cacheTTL),
+		githubRateLimiter:     newGithubRateLimiter(),
+		config:                config,
+		metrics:               newServerMetrics(),
+		logger:                newLogger(os.Stderr, config.logLevel),
+		upstreamHealth:        newUpstreamHealth(),
+		startedAt:             time.Now(),
 	}
+	if config.cacheBackend == CACHE_BACKEND_REDIS {
+		s.redisClient = newRedisClient(config.redisOptions)
+	}
+	if config.clusterSelfURL != "" {
+		s.cluster = newCluster(config.clusterSelfURL, config.clusterPeers)
+	}
+	configureCache(s, CACHE_USER_INFO, s.githubUserInfoCache)
+	configureCache(s, CACHE_ORG_INFO, s.githubOrgInfoCache)
+	configureCache(s, CACHE_ORG_MEMBERS, s.githubOrgMembersCache)
+	configureCache(s, CACHE_FOLLOW_LIST, s.githubFollowListCache)
+	s.tracerProvider = s.newTracerProvider()
+	s.tracer = s.tracerProvider.Tracer(TRACER_NAME)
+	s.githubUserFetcher = s.newGithubUserFetcher()
+	s.jobQueue = newJobQueue(s)
+	s.snapshotCtx, s.stopSnapshots = context.WithCancel(context.Background())
+	return s
 }
 
-// retrieveUsers handling retrieving users
+// retrieveUsers handling retrieving users, respond 200 when at least one user is returned (the failed usernames are listed in errors)
+// and a problem when the whole request fails: 400 for invalid input, 404 when every username is not found, 502 when github fails and 503 when the rate limit is exhausted
 func (s *Server) retrieveUsers(w http.ResponseWriter, r *http.Request) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	client := s.newGithubClient(r.Context())
+
+	streamMode, err := negotiateStreamMode(r)
+	format := ""
+	if err == nil && streamMode == "" {
+		format, err = negotiateResponseFormat(r)
+	}
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		var formatErr *responseFormatError
+		if errors.As(err, &formatErr) {
+			statusCode = formatErr.StatusCode
+		}
+		writeProblem(w, r, statusCode, err.Error(), nil)
+		return
+	}
+
+	fieldsFormat := format
+	if streamMode != "" {
+		fieldsFormat = streamMode
+	}
+	fields, err := parseUserFields(r.FormValue("fields"), fieldsFormat)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
 	}
 
 	usernamesFormValue := r.FormValue("usernames")
-	processedUserMap := make(map[string]bool)
+	includeRepoStats := false
+	if includeRepoStatsFormValue := r.FormValue("includeRepoStats"); includeRepoStatsFormValue != "" {
+		includeRepoStats, err = strconv.ParseBool(includeRepoStatsFormValue)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("invalid includeRepoStats %q, expected true or false", includeRepoStatsFormValue), nil)
+			return
+		}
+	}
+	if invalidErrors := invalidUsernameErrors(strings.Split(usernamesFormValue, ",")); len(invalidErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, "invalid usernames", invalidErrors)
+		return
+	}
+	if fields != nil && hasUserField(fields, USER_FIELD_REPO_STATS) {
+		// Selecting the repository statistics imply computing them
+		includeRepoStats = true
+	}
 	resultObj := &model.ResultRetrieveUsers{
 		Users:  make([]*model.GithubUserInfo, 0),
 		Errors: make([]*model.ResultError, 0),
 	}
 
+	distinctUsernames := make([]string, 0)
 	if len(usernamesFormValue) > 0 {
 		// Split the usernames by separator ,
-		usernames := strings.Split(usernamesFormValue, ",")
-
-		if len(usernames) > 0 {
-			for _, eachUsername := range usernames {
-				// Validate length of username (trimmed)
-				if len(strings.TrimSpace(eachUsername)) == 0 {
-					continue
-				}
-
-				// Check if this login has been processed before
-				_, processed := processedUserMap[eachUsername]
-				if processed {
-					// Skip if it has been processed before
-					continue
-				}
-
-				// Mark this username has been processed
-				processedUserMap[eachUsername] = true
-
-				var userInfo *model.GithubUserInfo
-				// Get from cache (if have)
-				userInfo = s.githubUserInfoCache.Get(eachUsername)
-
-				// Can not find in cache, calling API to get data
-				if userInfo == nil {
-					apiURL := fmt.Sprintf("%s/%s/%s", s.config.githubAPIURL, s.config.githubAPIUser, eachUsername)
-					req, err := http.NewRequest(http.MethodGet, apiURL, nil)
-					if err != nil {
-						resultObj.Errors = append(resultObj.Errors, &model.ResultError{
-							Message: fmt.Sprintf("encounter err for username %q: %v", eachUsername, err),
-						})
-					} else {
-						req.Header.Add("Accept", "application/vnd.github+json")
-						req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
-						resp, err := client.Do(req)
-						if err != nil {
-							resultObj.Errors = append(resultObj.Errors, &model.ResultError{
-								Message: fmt.Sprintf("encounter err for username %q: %v", eachUsername, err),
-							})
-						} else {
-							responseData, err := io.ReadAll(resp.Body)
-							if err != nil {
-								resultObj.Errors = append(resultObj.Errors, &model.ResultError{
-									Message: fmt.Sprintf("encounter err for username %q: %v", eachUsername, err),
-								})
-							} else {
-								userInfo = &model.GithubUserInfo{}
-								err = json.Unmarshal(responseData, &userInfo)
-								if err != nil {
-									resultObj.Errors = append(resultObj.Errors, &model.ResultError{
-										Message: fmt.Sprintf("encounter err for username %q: %v", eachUsername, err),
-									})
-								} else {
-									// Calculate AvgFollowersPerPublicRepo
-									if userInfo.PublicRepos > 0 {
-										userInfo.AvgFollowersPerPublicRepo = float32(userInfo.Followers) / float32(userInfo.PublicRepos)
-									}
-
-									// Cache the data
-									s.githubUserInfoCache.Set(eachUsername, userInfo)
-								}
-							}
-						}
-					}
-				}
-
-				// Process result (whether from cache or from API call)
-				if userInfo != nil {
-					if userInfo.Message == GITHUB_API_MESSAGE_USER_NOT_FOUND {
-						resultObj.Errors = append(resultObj.Errors, &model.ResultError{
-							Message: fmt.Sprintf("username %q not found", eachUsername),
-						})
-					} else {
-						// Add the user to result object's user list
-						resultObj.Users = append(resultObj.Users, userInfo)
-					}
-				}
-			}
+		var expandErrors []*model.ResultError
+		distinctUsernames, expandErrors = s.resolveUsernames(client, strings.Split(usernamesFormValue, ","))
+		resultObj.Errors = append(resultObj.Errors, expandErrors...)
+	}
+	requestLogFromContext(r.Context()).countUsernames(len(distinctUsernames))
+
+	if streamMode != "" {
+		// Emit each user or error as soon as it is resolved
+		s.streamUsers(w, r, client, streamMode, distinctUsernames, includeRepoStats, resultObj.Errors)
+		return
+	}
+
+	userFetchResults := s.retrieveUserInfos(client, distinctUsernames)
+	for _, eachUsername := range distinctUsernames {
+		userInfo, userErrors := s.processUserFetchResult(client, eachUsername, userFetchResults[eachUsername], includeRepoStats)
+		resultObj.Errors = append(resultObj.Errors, userErrors...)
+		if userInfo != nil {
+			// Add the user to result object's user list
+			resultObj.Users = append(resultObj.Users, userInfo)
 		}
 	}
 
+	// The response is fresh until the earliest included user expires from the cache
+	freshness := s.userCacheFreshness(distinctUsernames, len(resultObj.Errors) > 0)
+	recordCacheFreshness(r.Context(), freshness)
+
+	if len(resultObj.Users) == 0 && len(resultObj.Errors) > 0 {
+		// Every username failed, the status reflect the cause of the failures
+		statusCode := failureStatus(resultObj.Errors)
+		if statusCode == http.StatusServiceUnavailable {
+			s.setRetryAfter(w)
+		}
+		writeProblem(w, r, statusCode, "no user could be retrieved", resultObj.Errors)
+		return
+	}
+
 	// Sort users data
-	sort.SliceStable(resultObj.Users, func(i, j int) bool {
-		return strings.Compare(resultObj.Users[i].Name, resultObj.Users[j].Name) < 0
-	})
+	sortUsers(resultObj.Users)
 
-	// Write response (pretty JSON format)
-	w.Header().Set("Content-Type", "application/json")
-	responseData, err := json.MarshalIndent(resultObj, "", "    ")
+	etag, err := resultEntityTag(format, fields, jsonPretty(r), resultObj)
 	if err == nil {
-		w.WriteHeader(http.StatusOK)
-		w.Write(responseData)
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("unexpected error: %v", err)))
+		// The format is negotiated from the Accept header
+		w.Header().Add("Vary", "Accept")
+		if writeCacheHeaders(w, r, etag, freshness) {
+			return
+		}
 	}
-	// json.NewEncoder(w).Encode(resultObj)
+	writeResultRetrieveUsers(w, r, format, fields, resultObj)
+}
+
+// resolveUsernames expand org:NAME into the public members of the organization and return the distinct non empty usernames
+func (s *Server) resolveUsernames(client *http.Client, usernames []string) ([]string, []*model.ResultError) {
+	expandedUsernames, expandErrors := s.expandOrganizationMembers(client, usernames)
+
+	// Collect the distinct usernames so the cache misses can be fetched from upstream together
+	processedUserMap := make(map[string]bool)
+	distinctUsernames := make([]string, 0, len(expandedUsernames))
+	for _, eachUsername := range expandedUsernames {
+		// Validate length of username (trimmed)
+		if len(strings.TrimSpace(eachUsername)) == 0 {
+			continue
+		}
+
+		// Check if this login has been processed before
+		_, processed := processedUserMap[eachUsername]
+		if processed {
+			// Skip if it has been processed before
+			continue
+		}
+
+		// Mark this username has been processed
+		processedUserMap[eachUsername] = true
+		distinctUsernames = append(distinctUsernames, eachUsername)
+	}
+	return distinctUsernames, expandErrors
+}
+
+// sortUsers sort users by name
+func sortUsers(users []*model.GithubUserInfo) {
+	sort.SliceStable(users, func(i, j int) bool {
+		return strings.Compare(users[i].Name, users[j].Name) < 0
+	})
+}
+
+// processUserFetchResult turn the fetch result of a username into the user to return (nil if not found or failed) and its errors
+func (s *Server) processUserFetchResult(client *http.Client, username string, fetchResult *userFetchResult, includeRepoStats bool) (*model.GithubUserInfo, []*model.ResultError) {
+	userErrors := make([]*model.ResultError, 0)
+	userInfo, err := fetchResult.userInfo, fetchResult.err
+	if err != nil {
+		userErrors = append(userErrors, &model.ResultError{
+			Message:    fmt.Sprintf("encounter err for username %q: %v", username, err),
+			StatusCode: upstreamErrorStatus(err),
+		})
+	}
+
+	// Process result (whether from cache or from API call)
+	if userInfo == nil {
+		return nil, userErrors
+	}
+	if userInfo.Message == GITHUB_API_MESSAGE_USER_NOT_FOUND {
+		return nil, append(userErrors, &model.ResultError{
+			Message:    fmt.Sprintf("username %q not found", username),
+			StatusCode: http.StatusNotFound,
+		})
+	}
+
+	if includeRepoStats && userInfo.RepoStats == nil {
+		// Compute repository statistics and cache them alongside the profile
+		repoStats, err := s.retrieveRepoStats(client, username)
+		if err != nil {
+			userErrors = append(userErrors, &model.ResultError{
+				Message:    fmt.Sprintf("encounter err when computing repository statistics for username %q: %v", username, err),
+				StatusCode: upstreamErrorStatus(err),
+			})
+		} else {
+			userInfoWithRepoStats := *userInfo
+			userInfoWithRepoStats.RepoStats = repoStats
+			userInfo = &userInfoWithRepoStats
+			s.githubUserInfoCache.Set(username, userInfo)
+		}
+	} else if !includeRepoStats && userInfo.RepoStats != nil {
+		// Only return repository statistics when requested
+		userInfoWithoutRepoStats := *userInfo
+		userInfoWithoutRepoStats.RepoStats = nil
+		userInfo = &userInfoWithoutRepoStats
+	}
+	return userInfo, userErrors
+}
+
+// retrieveUserInfo return the user profile (from cache or from upstream), user not found is returned with the github message
+func (s *Server) retrieveUserInfo(client *http.Client, username string) (*model.GithubUserInfo, error) {
+	userFetchResult := s.retrieveUserInfos(client, []string{username})[username]
+	return userFetchResult.userInfo, userFetchResult.err
+}
+
+// retrieveUserInfos return the user profiles (from cache, from the replica owning them in a cluster or from upstream), the cache misses are fetched from upstream together
+func (s *Server) retrieveUserInfos(client *http.Client, usernames []string) map[string]*userFetchResult {
+	return s.retrieveUserInfosFrom(client, usernames, s.cluster != nil)
+}
+
+// retrieveUserInfosFrom return the user profiles from cache, the cache misses owned by other replicas are fetched from their owner when forwarding
+// (from upstream when the owner fails), the other cache misses are fetched from upstream together
+func (s *Server) retrieveUserInfosFrom(client *http.Client, usernames []string, forwardToOwners bool) map[string]*userFetchResult {
+	userFetchResults := make(map[string]*userFetchResult)
+	cacheMisses := make([]string, 0)
+	for _, eachUsername := range usernames {
+		// Get from cache (if have)
+		userInfo := tracedCacheGet(githubClientContext(client), s.tracer, CACHE_USER_INFO, s.githubUserInfoCache, eachUsername)
+		if userInfo != nil {
+			userFetchResults[eachUsername] = &userFetchResult{
+				userInfo: userInfo,
+			}
+		} else {
+			cacheMisses = append(cacheMisses, eachUsername)
+		}
+	}
+
+	if forwardToOwners && len(cacheMisses) > 0 {
+		cacheMisses = s.fetchFromOwners(client, cacheMisses, userFetchResults)
+	}
+	if len(cacheMisses) == 0 {
+		return userFetchResults
+	}
+
+	// Can not find in cache, calling upstream to get data
+	for eachUsername, eachResult := range s.githubUserFetcher.fetchUsers(client, cacheMisses) {
+		if eachResult.err == nil && eachResult.userInfo != nil {
+			// Calculate AvgFollowersPerPublicRepo
+			if eachResult.userInfo.PublicRepos > 0 {
+				eachResult.userInfo.AvgFollowersPerPublicRepo = float32(eachResult.userInfo.Followers) / float32(eachResult.userInfo.PublicRepos)
+			}
+
+			// Cache the data
+			s.githubUserInfoCache.Set(eachUsername, eachResult.userInfo)
+		}
+		userFetchResults[eachUsername] = eachResult
+	}
+	return userFetchResults
+}
+
+// writeJSONResponse write the result object as response (compact JSON, indented with pretty=true)
+func writeJSONResponse(w http.ResponseWriter, r *http.Request, resultObj interface{}) {
+	writeJSONResponseWithStatus(w, r, http.StatusOK, resultObj)
+}
+
+// writeJSONResponseWithStatus write the result object as response (compact JSON, indented with pretty=true) with the status code
+func writeJSONResponseWithStatus(w http.ResponseWriter, r *http.Request, statusCode int, resultObj interface{}) {
+	writeEncodedJSON(w, r, statusCode, "application/json", resultWithRequestID(r, resultObj), jsonPretty(r))
 }
 
 // Serve server will use this function to register and serve handlers, this function will block and listen to connections
 func (s *Server) Serve() error {
+	s.registerHandlers()
+	if s.redisClient != nil {
+		if err := s.redisClient.ping(); err != nil {
+			s.logger.warn("redis cache backend is not reachable, cache lookups miss until it is", logFields{"address": s.redisClient.options.Address, "err": err})
+		}
+	}
+	if s.cluster != nil && s.config.clusterHeartbeatInterval > 0 {
+		go s.sendHeartbeatsPeriodically()
+	}
+	if s.config.cacheSnapshotPath != "" {
+		s.restoreCacheSnapshot()
+		if s.config.cacheSnapshotInterval > 0 {
+			go s.snapshotCachesPeriodically()
+		}
+	}
+
+	// Listen and serve
+	address := fmt.Sprintf("%s:%d", s.config.host, s.config.port)
+	s.logger.info("server is listening", logFields{
+		"address":            address,
+		"graphql_playground": address + "/graphql/playground",
+		"docs":               address + "/docs",
+		"metrics":            address + "/metrics",
+	})
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	s.listening.Store(true)
+	defer s.listening.Store(false)
+	return s.httpServer.Serve(listener)
+}
+
+// registerHandlers register the REST routes of each API version, the deprecated unversioned aliases, the API documentation, the metrics, the health checks and graphql on the serverMux
+func (s *Server) registerHandlers() {
 	// Register handler
-	s.serverMux.HandleFunc("/retrieveUsers", s.retrieveUsers)
+	for _, eachVersion := range s.apiVersions() {
+		for _, eachRoute := range eachVersion.Routes {
+			s.handle(eachVersion.Prefix+eachRoute.Pattern, s.corsHandler(versionedHandler(eachVersion.Prefix, eachRoute.Handler)))
+		}
+	}
+	for _, eachRoute := range s.v1Routes() {
+		s.handle(eachRoute.Pattern, s.corsHandler(s.deprecatedHandler(API_PREFIX_V1, eachRoute.Handler)))
+	}
+
+	// Register API documentation
+	s.handle("/openapi.json", http.HandlerFunc(s.openAPI))
+	s.handle("/docs", http.HandlerFunc(s.openAPIDocs))
+
+	// Register metrics and health
+	s.handle("/metrics", http.HandlerFunc(s.serveMetrics))
+	s.handle("/healthz", http.HandlerFunc(s.healthz))
+	s.handle("/readyz", http.HandlerFunc(s.readyz))
+	s.handle("/status", http.HandlerFunc(s.status))
+
+	// Register admin API
+	s.handle("/admin/cache", http.HandlerFunc(s.adminCache))
+	s.handle("/admin/cache/", http.HandlerFunc(s.adminCache))
+	s.handle("/admin/refresh/", http.HandlerFunc(s.adminRefreshUser))
+
+	// Register cluster routes
+	if s.cluster != nil {
+		s.handle(CLUSTER_USERS_PATH, http.HandlerFunc(s.clusterUsers))
+		s.handle(CLUSTER_HEARTBEAT_PATH, http.HandlerFunc(s.clusterHeartbeat))
+	}
 
 	// Register graphql
 	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{
-		RetrieveUsersHandler: s.retrieveUsers,
+		RetrieveUsersHandler:          s.retrieveUsers,
+		RetrieveOrganizationHandler:   s.retrieveOrganization,
+		RetrieveUserConnectionHandler: s.retrieveUserConnection,
+		SearchUsersHandler:            s.searchUsers,
+		AdminCacheHandler:             s.adminCache,
+		AdminRefreshUserHandler:       s.adminRefreshUser,
 	}}))
-	s.serverMux.Handle("/graphql/playground", playground.Handler("GraphQL playground", "/graphql/query"))
-	s.serverMux.Handle("/graphql/query", srv)
+	srv.AroundOperations(s.countGraphQLOperation)
+	srv.AroundOperations(s.traceGraphQLOperation)
+	srv.AroundFields(s.traceGraphQLResolver)
+	srv.SetErrorPresenter(presentGraphQLError)
+	s.handle("/graphql/playground", playground.Handler("GraphQL playground", "/graphql/query"))
+	s.handle("/graphql/query", s.corsHandler(s.adminGraphQLHandler(graphQLCacheHandler(srv))))
+}
 
-	// Listen and serve
-	log.Println("Server is listening on", fmt.Sprintf("%s:%d", s.config.host, s.config.port))
-	log.Println("GraphQL playground is available on", fmt.Sprintf("%s:%d/graphql/playground", s.config.host, s.config.port))
-	return s.httpServer.ListenAndServe()
+// handle register the handler of the pattern on the serverMux, the requests are traced, logged and counted in the metrics by pattern
+func (s *Server) handle(pattern string, handler http.Handler) {
+	s.serverMux.Handle(pattern, s.tracedHandler(pattern, s.loggedHandler(pattern, s.instrumentedHandler(pattern, handler))))
 }
 
-// Shutdown shutdown the server
+// Shutdown stop accepting connections, then drain the in-flight requests and the batch jobs within the shutdown grace period,
+// the requests still running when the grace period is over are closed and the jobs cancelled
 func (s *Server) Shutdown() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second) // Gracefully shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.shutdownGracePeriod)
 	defer cancel()
-	log.Println("Server is stopping...")
-	return s.httpServer.Shutdown(ctx)
+	s.logger.info("server is stopping", logFields{"grace_period": s.config.shutdownGracePeriod.String()})
+	s.listening.Store(false)
+	if s.cluster != nil {
+		s.cluster.stop()
+	}
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.logger.warn("requests not served within the grace period are closed", logFields{"err": err})
+		s.httpServer.Close()
+	}
+
+	if jobsErr := s.jobQueue.drain(ctx); jobsErr != nil {
+		s.logger.warn("batch jobs not completed within the grace period are cancelled", logFields{"err": jobsErr})
+		if err == nil {
+			err = jobsErr
+		}
+	}
+
+	if s.config.cacheSnapshotPath != "" {
+		if snapshotErr := s.flushCacheSnapshot(); snapshotErr != nil {
+			s.logger.error("encounter err when writing the cache snapshot", logFields{"path": s.config.cacheSnapshotPath, "err": snapshotErr})
+		}
+	}
+
+	if s.redisClient != nil {
+		s.redisClient.Close()
+	}
+
+	// Flush the spans not exported yet
+	if tracingErr := s.tracerProvider.Shutdown(ctx); tracingErr != nil {
+		s.logger.error("encounter err when flushing the spans", logFields{"err": tracingErr})
+	}
+	s.logger.info("server is stopped", nil)
+	return err
 }
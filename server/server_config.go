@@ -1,12 +1,60 @@
 package server
 
+import (
+	"net/http"
+	"time"
+)
+
 // ServerConfig configuration for the server
 type ServerConfig struct {
 	host                   string
 	port                   int
-	defaultCachePartitions int // default number of cache partition to use when create new cache
+	defaultCachePartitions int           // default number of cache partition to use when create new cache
+	cacheTTL               time.Duration // how long github data is cached, also the max-age advertised to HTTP caches
+	cacheSnapshotPath      string        // file the caches are written to on shutdown (and periodically) and restored from on startup, no snapshot when empty
+	cacheSnapshotInterval  time.Duration // how often the cache snapshot is written, only on shutdown when 0
+	cacheBackend           string        // where the cache entries are kept, see CACHE_BACKEND_*
+	redisOptions           *RedisOptions // connection options of the Redis server of the redis cache backend, localhost:6379 when nil
 	githubAPIURL           string
 	githubAPIUser          string
+	githubAPIOrg           string
+	githubToken            string // token used to authenticate github API calls (optional for REST, required for GraphQL)
+	githubUpstream         string // upstream used to fetch users: rest or graphql
+	githubGraphQLURL       string
+
+	repoStatsMaxPages         int // maximum number of repository pages (100 repositories each) to inspect when computing repository statistics
+	repoStatsMaxLanguageRepos int // maximum number of repositories to inspect for language bytes when computing repository statistics
+	orgMembersMaxPages        int // maximum number of member pages (100 members each) to inspect when expanding an organization
+	followMaxPages            int // maximum number of follower/following pages (100 users each) to traverse for a user
+	githubGraphQLBatchSize    int // maximum number of users fetched by a single GraphQL request
+	maxConcurrentFetches      int // maximum number of concurrent upstream fetches when streaming users
+
+	jobWorkers      int           // number of background workers processing batch jobs
+	jobQueueSize    int           // maximum number of batch jobs waiting for a worker
+	jobMaxUsernames int           // maximum number of usernames accepted by a batch job
+	jobRetention    time.Duration // how long the result of a completed batch job is kept
+
+	corsAllowedOrigins   []string      // origins allowed to call the REST routes and graphql from a browser, * allows any origin, CORS is disabled when empty
+	corsAllowedMethods   []string      // methods allowed by the preflight responses
+	corsAllowedHeaders   []string      // request headers allowed by the preflight responses, * allows any header
+	corsAllowCredentials bool          // whether browsers may send cookies and authorization headers
+	corsMaxAge           time.Duration // how long browsers may cache the preflight responses
+
+	shutdownGracePeriod time.Duration // how long the in-flight requests and batch jobs are drained on shutdown
+
+	adminToken string // bearer token authenticating the admin API, the admin API is disabled when empty
+
+	clusterSelfURL           string        // URL the other replicas reach this replica on, the server is not clustered when empty
+	clusterPeers             []string      // URL of the other replicas known at startup, the others are learnt from the heartbeats
+	clusterHeartbeatInterval time.Duration // how often the replicas exchange heartbeats, the peers are never considered down when 0
+	clusterToken             string        // bearer token authenticating the calls between the replicas, the calls are rejected when empty
+
+	version      string // version of the server reported by /status
+	logLevel     string // lowest level of the log records written, see LOG_LEVEL_*
+	otlpEndpoint string // base URL of the OTLP collector the spans are exported to (over HTTP), spans are not exported when empty
+
+	legacyRoutesDeprecation time.Time // when the unversioned REST routes were deprecated in favor of /v1
+	legacyRoutesSunset      time.Time // when the unversioned REST routes will stop being served
 }
 
 // NewServerConfig return new configuration instance for server
@@ -15,7 +63,183 @@ func NewServerConfig(host string, port int, githubAPIURL string, githubAPIUser s
 		host:                   host,
 		port:                   port,
 		defaultCachePartitions: 7,
+		cacheTTL:               10 * time.Minute,
+		cacheSnapshotInterval:  5 * time.Minute,
+		cacheBackend:           CACHE_BACKEND_MEMORY,
 		githubAPIURL:           githubAPIURL,
 		githubAPIUser:          githubAPIUser,
+		githubAPIOrg:           "orgs",
+		githubUpstream:         GITHUB_UPSTREAM_REST,
+		githubGraphQLURL:       "https://api.github.com/graphql",
+
+		repoStatsMaxPages:         10,
+		repoStatsMaxLanguageRepos: 30,
+		orgMembersMaxPages:        10,
+		followMaxPages:            10,
+		githubGraphQLBatchSize:    50,
+		maxConcurrentFetches:      8,
+
+		jobWorkers:      2,
+		jobQueueSize:    100,
+		jobMaxUsernames: 10000,
+		jobRetention:    time.Hour,
+
+		corsAllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+		corsAllowedHeaders: []string{"Accept", "Content-Type", "If-None-Match", REQUEST_ID_HEADER},
+		corsMaxAge:         10 * time.Minute,
+
+		shutdownGracePeriod: 30 * time.Second,
+
+		clusterHeartbeatInterval: 5 * time.Second,
+
+		version:  "dev",
+		logLevel: LOG_LEVEL_INFO,
+
+		legacyRoutesDeprecation: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		legacyRoutesSunset:      time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
 	}
 }
+
+// WithGithubAPIOrg set the Github API path used to retrieve organizations (default: orgs)
+func (c *ServerConfig) WithGithubAPIOrg(githubAPIOrg string) *ServerConfig {
+	c.githubAPIOrg = githubAPIOrg
+	return c
+}
+
+// WithGithubToken set the token used to authenticate github API calls
+func (c *ServerConfig) WithGithubToken(githubToken string) *ServerConfig {
+	c.githubToken = githubToken
+	return c
+}
+
+// WithGithubUpstream set the upstream used to fetch users: rest (default) or graphql, graphql falls back to rest without a token
+func (c *ServerConfig) WithGithubUpstream(githubUpstream string) *ServerConfig {
+	c.githubUpstream = githubUpstream
+	return c
+}
+
+// WithGithubGraphQLURL set the URL of the github GraphQL v4 API (default: https://api.github.com/graphql)
+func (c *ServerConfig) WithGithubGraphQLURL(githubGraphQLURL string) *ServerConfig {
+	c.githubGraphQLURL = githubGraphQLURL
+	return c
+}
+
+// WithLegacyRoutesSunset set when the unversioned REST routes will stop being served (advertised with the Sunset header)
+func (c *ServerConfig) WithLegacyRoutesSunset(legacyRoutesSunset time.Time) *ServerConfig {
+	c.legacyRoutesSunset = legacyRoutesSunset
+	return c
+}
+
+// WithCacheTTL set how long github data is cached
+func (c *ServerConfig) WithCacheTTL(cacheTTL time.Duration) *ServerConfig {
+	c.cacheTTL = cacheTTL
+	return c
+}
+
+// WithCacheSnapshotPath set the file the caches are written to on shutdown and restored from on startup
+func (c *ServerConfig) WithCacheSnapshotPath(cacheSnapshotPath string) *ServerConfig {
+	c.cacheSnapshotPath = cacheSnapshotPath
+	return c
+}
+
+// WithCacheSnapshotInterval set how often the cache snapshot is written besides on shutdown, only on shutdown when 0
+func (c *ServerConfig) WithCacheSnapshotInterval(cacheSnapshotInterval time.Duration) *ServerConfig {
+	c.cacheSnapshotInterval = cacheSnapshotInterval
+	return c
+}
+
+// WithCacheBackend set where the cache entries are kept: memory (default) or redis, see CACHE_BACKEND_*
+func (c *ServerConfig) WithCacheBackend(cacheBackend string) *ServerConfig {
+	c.cacheBackend = cacheBackend
+	return c
+}
+
+// WithRedisOptions set the connection options of the Redis server of the redis cache backend (default: localhost:6379)
+func (c *ServerConfig) WithRedisOptions(redisOptions *RedisOptions) *ServerConfig {
+	c.redisOptions = redisOptions
+	return c
+}
+
+// WithCORSAllowedOrigins set the origins allowed to call the REST routes and graphql from a browser (* allows any origin)
+func (c *ServerConfig) WithCORSAllowedOrigins(corsAllowedOrigins []string) *ServerConfig {
+	c.corsAllowedOrigins = corsAllowedOrigins
+	return c
+}
+
+// WithCORSAllowedMethods set the methods allowed by the preflight responses
+func (c *ServerConfig) WithCORSAllowedMethods(corsAllowedMethods []string) *ServerConfig {
+	c.corsAllowedMethods = corsAllowedMethods
+	return c
+}
+
+// WithCORSAllowedHeaders set the request headers allowed by the preflight responses (* allows any header)
+func (c *ServerConfig) WithCORSAllowedHeaders(corsAllowedHeaders []string) *ServerConfig {
+	c.corsAllowedHeaders = corsAllowedHeaders
+	return c
+}
+
+// WithCORSAllowCredentials set whether browsers may send cookies and authorization headers
+func (c *ServerConfig) WithCORSAllowCredentials(corsAllowCredentials bool) *ServerConfig {
+	c.corsAllowCredentials = corsAllowCredentials
+	return c
+}
+
+// WithCORSMaxAge set how long browsers may cache the preflight responses
+func (c *ServerConfig) WithCORSMaxAge(corsMaxAge time.Duration) *ServerConfig {
+	c.corsMaxAge = corsMaxAge
+	return c
+}
+
+// WithOTLPEndpoint set the base URL of the OTLP collector the spans are exported to, e.g. http://localhost:4318
+func (c *ServerConfig) WithOTLPEndpoint(otlpEndpoint string) *ServerConfig {
+	c.otlpEndpoint = otlpEndpoint
+	return c
+}
+
+// WithAdminToken set the bearer token authenticating the admin API
+func (c *ServerConfig) WithAdminToken(adminToken string) *ServerConfig {
+	c.adminToken = adminToken
+	return c
+}
+
+// WithClusterSelfURL set the URL the other replicas reach this replica on, the users are shared by the replicas of the cluster when set
+func (c *ServerConfig) WithClusterSelfURL(clusterSelfURL string) *ServerConfig {
+	c.clusterSelfURL = clusterSelfURL
+	return c
+}
+
+// WithClusterPeers set the URL of the other replicas known at startup
+func (c *ServerConfig) WithClusterPeers(clusterPeers []string) *ServerConfig {
+	c.clusterPeers = clusterPeers
+	return c
+}
+
+// WithClusterHeartbeatInterval set how often the replicas exchange heartbeats, the peers are never considered down when 0
+func (c *ServerConfig) WithClusterHeartbeatInterval(clusterHeartbeatInterval time.Duration) *ServerConfig {
+	c.clusterHeartbeatInterval = clusterHeartbeatInterval
+	return c
+}
+
+// WithClusterToken set the bearer token authenticating the calls between the replicas
+func (c *ServerConfig) WithClusterToken(clusterToken string) *ServerConfig {
+	c.clusterToken = clusterToken
+	return c
+}
+
+// WithLogLevel set the lowest level of the log records written, see LOG_LEVEL_*
+func (c *ServerConfig) WithLogLevel(logLevel string) *ServerConfig {
+	c.logLevel = logLevel
+	return c
+}
+
+// WithVersion set the version of the server reported by /status
+func (c *ServerConfig) WithVersion(version string) *ServerConfig {
+	c.version = version
+	return c
+}
+
+// WithShutdownGracePeriod set how long the in-flight requests and batch jobs are drained on shutdown
+func (c *ServerConfig) WithShutdownGracePeriod(shutdownGracePeriod time.Duration) *ServerConfig {
+	c.shutdownGracePeriod = shutdownGracePeriod
+	return c
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"machshipgithubapi/graph/model"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -669,3 +670,90 @@ func TestServe(t *testing.T) {
 		})
 	}
 }
+
+// freePort return a port nothing is listening on
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected a free port, got %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestShutdown(t *testing.T) {
+	tests := map[string]struct {
+		UpstreamDelay      time.Duration
+		GracePeriod        time.Duration
+		ExpectedError      bool
+		ExpectedStatusCode int
+	}{
+		"Test in-flight request is drained": {
+			UpstreamDelay:      300 * time.Millisecond,
+			GracePeriod:        5 * time.Second,
+			ExpectedStatusCode: http.StatusOK,
+		},
+		"Test in-flight request exceeding the grace period": {
+			UpstreamDelay: 1500 * time.Millisecond,
+			GracePeriod:   200 * time.Millisecond,
+			ExpectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			upstreamCalled := make(chan struct{}, 1)
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreamCalled <- struct{}{}
+				time.Sleep(test.UpstreamDelay)
+				jsonString, _ := json.Marshal(model.GithubUserInfo{Login: "a"})
+				w.Write(jsonString)
+			}))
+			defer githubAPITestServer.Close()
+
+			port := freePort(t)
+			s := NewServer(NewServerConfig("127.0.0.1", port, githubAPITestServer.URL, "users").WithShutdownGracePeriod(test.GracePeriod))
+			serveErr := make(chan error, 1)
+			go func() {
+				serveErr <- s.Serve()
+			}()
+			address := fmt.Sprintf("http://127.0.0.1:%d", port)
+			for i := 0; i < 100 && !s.listening.Load(); i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			statusCode := make(chan int, 1)
+			go func() {
+				resp, err := http.Get(address + "/v1/retrieveUsers?usernames=a")
+				if err != nil {
+					statusCode <- 0
+					return
+				}
+				resp.Body.Close()
+				statusCode <- resp.StatusCode
+			}()
+			<-upstreamCalled
+
+			start := time.Now()
+			err := s.Shutdown()
+			if (err != nil) != test.ExpectedError {
+				t.Errorf("expected error %v, got %v", test.ExpectedError, err)
+			}
+			if elapsed := time.Since(start); elapsed > test.GracePeriod+time.Second {
+				t.Errorf("expected shutdown within the grace period, took %v", elapsed)
+			}
+			if result := <-statusCode; result != test.ExpectedStatusCode {
+				t.Errorf("expected in-flight request status %v, got %v", test.ExpectedStatusCode, result)
+			}
+			if err := <-serveErr; err != http.ErrServerClosed {
+				t.Errorf("expected Serve to return %v, got %v", http.ErrServerClosed, err)
+			}
+			if s.listening.Load() {
+				t.Errorf("expected the server to stop listening")
+			}
+			if _, err := http.Get(address + "/healthz"); err == nil {
+				t.Errorf("expected new connections to be refused")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// STREAM_MODE_NDJSON stream one JSON frame per line over chunked transfer
+	STREAM_MODE_NDJSON = "ndjson"
+	// STREAM_MODE_SSE stream one Server-Sent Event per frame
+	STREAM_MODE_SSE = "sse"
+)
+
+// streamSummary the final frame of a stream, logins are sorted the same way as the non streaming users list
+type streamSummary struct {
+	UserCount  int      `json:"user_count"`
+	ErrorCount int      `json:"error_count"`
+	Logins     []string `json:"logins"`
+}
+
+// negotiateStreamMode return the stream mode requested with stream=true, format=sse or Accept: text/event-stream (empty when not streaming)
+func negotiateStreamMode(r *http.Request) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(r.FormValue("format")))
+	stream, _ := strconv.ParseBool(r.FormValue("stream"))
+	if format == STREAM_MODE_SSE || strings.Contains(strings.ToLower(r.Header.Get("Accept")), "text/event-stream") {
+		return STREAM_MODE_SSE, nil
+	}
+	if !stream {
+		return "", nil
+	}
+	if format != "" && format != RESPONSE_FORMAT_NDJSON {
+		return "", &responseFormatError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("format %q can not be streamed, expected %s or %s", format, RESPONSE_FORMAT_NDJSON, STREAM_MODE_SSE),
+		}
+	}
+	return STREAM_MODE_NDJSON, nil
+}
+
+// streamUsers write each user or error as soon as it is resolved, followed by a summary frame
+func (s *Server) streamUsers(w http.ResponseWriter, r *http.Request, client *http.Client, streamMode string, usernames []string, includeRepoStats bool, initialErrors []*model.ResultError) {
+	if streamMode == STREAM_MODE_SSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", responseFormatContentTypes[RESPONSE_FORMAT_NDJSON])
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	frames := make(chan *ndjsonFrame)
+	go s.resolveUserFrames(r, client, usernames, includeRepoStats, frames)
+
+	users := make([]*model.GithubUserInfo, 0)
+	errorCount := 0
+	frameID := 0
+	writeFailed := false
+	writeFrame := func(frame *ndjsonFrame) {
+		if writeFailed {
+			return
+		}
+		frameID++
+		if frame.Error != nil {
+			frame.Error = resultErrorsWithRequestID([]*model.ResultError{frame.Error}, requestIDFromContext(r.Context()))[0]
+		}
+		err := writeStreamFrame(w, streamMode, frameID, frame)
+		if err != nil {
+			// Keep draining the frames so the workers can finish
+			writeFailed = true
+			return
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	for _, eachError := range initialErrors {
+		errorCount++
+		writeFrame(&ndjsonFrame{Type: "error", Error: eachError})
+	}
+	for eachFrame := range frames {
+		if eachFrame.User != nil {
+			users = append(users, eachFrame.User)
+		} else {
+			errorCount++
+		}
+		writeFrame(eachFrame)
+	}
+
+	// Summary frame carry the logins in the same order as the non streaming response
+	sortUsers(users)
+	summary := &streamSummary{
+		UserCount:  len(users),
+		ErrorCount: errorCount,
+		Logins:     make([]string, 0, len(users)),
+	}
+	for _, eachUser := range users {
+		summary.Logins = append(summary.Logins, eachUser.Login)
+	}
+	writeFrame(&ndjsonFrame{Type: "summary", Summary: summary})
+}
+
+// resolveUserFrames fetch the users with bounded concurrency and send a frame per user or error, the channel is closed once every user is resolved
+func (s *Server) resolveUserFrames(r *http.Request, client *http.Client, usernames []string, includeRepoStats bool, frames chan<- *ndjsonFrame) {
+	defer close(frames)
+
+	// Cached users are emitted on their own, cache misses are grouped by the batch size of the upstream
+	tasks := make([][]string, 0)
+	cacheMisses := make([]string, 0)
+	for _, eachUsername := range usernames {
+		if s.githubUserInfoCache.GetEntry(eachUsername) != nil {
+			tasks = append(tasks, []string{eachUsername})
+		} else {
+			cacheMisses = append(cacheMisses, eachUsername)
+		}
+	}
+	batchSize := s.githubUserFetcher.batchSize()
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	for start := 0; start < len(cacheMisses); start += batchSize {
+		end := start + batchSize
+		if end > len(cacheMisses) {
+			end = len(cacheMisses)
+		}
+		tasks = append(tasks, cacheMisses[start:end])
+	}
+
+	maxConcurrentFetches := s.config.maxConcurrentFetches
+	if maxConcurrentFetches < 1 {
+		maxConcurrentFetches = 1
+	}
+	semaphore := make(chan struct{}, maxConcurrentFetches)
+	wg := sync.WaitGroup{}
+	for _, eachTask := range tasks {
+		select {
+		case semaphore <- struct{}{}:
+		case <-r.Context().Done():
+			// Client has gone away, do not start any more fetches
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go func(taskUsernames []string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			userFetchResults := s.retrieveUserInfos(client, taskUsernames)
+			for _, eachUsername := range taskUsernames {
+				userInfo, userErrors := s.processUserFetchResult(client, eachUsername, userFetchResults[eachUsername], includeRepoStats)
+				taskFrames := make([]*ndjsonFrame, 0, len(userErrors)+1)
+				if userInfo != nil {
+					taskFrames = append(taskFrames, &ndjsonFrame{Type: "user", User: userInfo})
+				}
+				for _, eachError := range userErrors {
+					taskFrames = append(taskFrames, &ndjsonFrame{Type: "error", Error: eachError})
+				}
+				for _, eachFrame := range taskFrames {
+					select {
+					case frames <- eachFrame:
+					case <-r.Context().Done():
+						return
+					}
+				}
+			}
+		}(eachTask)
+	}
+	wg.Wait()
+}
+
+// writeStreamFrame write a frame as a NDJSON line or as a Server-Sent Event
+func writeStreamFrame(w http.ResponseWriter, streamMode string, frameID int, frame *ndjsonFrame) error {
+	frameData, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+
+	buffer := &bytes.Buffer{}
+	if streamMode == STREAM_MODE_SSE {
+		fmt.Fprintf(buffer, "event: %s\nid: %d\ndata: %s\n\n", frame.Type, frameID, frameData)
+	} else {
+		buffer.Write(frameData)
+		buffer.WriteByte('\n')
+	}
+	_, err = w.Write(buffer.Bytes())
+	return err
+}
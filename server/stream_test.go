@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateStreamMode(t *testing.T) {
+	tests := map[string]struct {
+		Query              string
+		Accept             string
+		Expected           string
+		ExpectedStatusCode int
+	}{
+		"Not streaming": {
+			Query:    "format=csv",
+			Expected: "",
+		},
+		"Stream without format": {
+			Query:    "stream=true",
+			Expected: STREAM_MODE_NDJSON,
+		},
+		"Stream with ndjson format": {
+			Query:    "stream=true&format=ndjson",
+			Expected: STREAM_MODE_NDJSON,
+		},
+		"Server-Sent Events format": {
+			Query:    "format=sse",
+			Expected: STREAM_MODE_SSE,
+		},
+		"Server-Sent Events Accept header": {
+			Accept:   "text/event-stream",
+			Expected: STREAM_MODE_SSE,
+		},
+		"Stream with format that can not be streamed": {
+			Query:              "stream=true&format=csv",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/retrieveUsers?%v", test.Query), nil)
+			if test.Accept != "" {
+				request.Header.Set("Accept", test.Accept)
+			}
+
+			result, err := negotiateStreamMode(request)
+			if test.ExpectedStatusCode != 0 {
+				formatErr, ok := err.(*responseFormatError)
+				if !ok || formatErr.StatusCode != test.ExpectedStatusCode {
+					t.Errorf("expected error with status %v, got %v", test.ExpectedStatusCode, err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if result != test.Expected {
+				t.Errorf("expected %v, got %v", test.Expected, result)
+			}
+		})
+	}
+}
+
+func TestRetrieveUsersStream(t *testing.T) {
+	tests := map[string]struct {
+		Query               string
+		Accept              string
+		ExpectedContentType string
+		ParseFrames         func(body []byte) ([]*ndjsonFrame, error)
+	}{
+		"NDJSON stream": {
+			Query:               "stream=true",
+			ExpectedContentType: "application/x-ndjson",
+			ParseFrames: func(body []byte) ([]*ndjsonFrame, error) {
+				frames := make([]*ndjsonFrame, 0)
+				scanner := bufio.NewScanner(bytes.NewReader(body))
+				for scanner.Scan() {
+					frame := &ndjsonFrame{}
+					err := json.Unmarshal(scanner.Bytes(), frame)
+					if err != nil {
+						return nil, err
+					}
+					frames = append(frames, frame)
+				}
+				return frames, nil
+			},
+		},
+		"Server-Sent Events stream": {
+			Accept:              "text/event-stream",
+			ExpectedContentType: "text/event-stream",
+			ParseFrames: func(body []byte) ([]*ndjsonFrame, error) {
+				frames := make([]*ndjsonFrame, 0)
+				for _, eachEvent := range strings.Split(strings.TrimSpace(string(body)), "\n\n") {
+					event := ""
+					frame := &ndjsonFrame{}
+					for _, eachLine := range strings.Split(eachEvent, "\n") {
+						name, value, _ := strings.Cut(eachLine, ": ")
+						switch name {
+						case "event":
+							event = value
+						case "data":
+							err := json.Unmarshal([]byte(value), frame)
+							if err != nil {
+								return nil, err
+							}
+						}
+					}
+					if event != frame.Type {
+						return nil, fmt.Errorf("expected event %v to match frame type %v", event, frame.Type)
+					}
+					frames = append(frames, frame)
+				}
+				return frames, nil
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			githubAPITestServer := newOrganizationTestServer(t)
+			defer githubAPITestServer.Close()
+
+			config := NewServerConfig("", 8777, githubAPITestServer.URL, "users")
+			s := NewServer(config)
+			responseRecorder := httptest.NewRecorder()
+			target := fmt.Sprintf("/retrieveUsers?usernames=org:missing,b,a,notfound&%v", test.Query)
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+			if test.Accept != "" {
+				request.Header.Set("Accept", test.Accept)
+			}
+			s.retrieveUsers(responseRecorder, request)
+
+			if contentType := responseRecorder.Header().Get("Content-Type"); contentType != test.ExpectedContentType {
+				t.Errorf("expected content type %v, got %v", test.ExpectedContentType, contentType)
+			}
+
+			frames, err := test.ParseFrames(responseRecorder.Body.Bytes())
+			if err != nil {
+				t.Fatalf("expected no error when parsing response, got %v", err)
+			}
+			if len(frames) != 5 {
+				t.Fatalf("expected 5 frames, got %v", len(frames))
+			}
+			if frames[0].Type != "error" || !strings.Contains(frames[0].Error.Message, "missing") {
+				t.Errorf("expected the organization error first, got %v", frames[0])
+			}
+
+			userCount, errorCount := 0, 0
+			for _, eachFrame := range frames[1:4] {
+				switch eachFrame.Type {
+				case "user":
+					userCount++
+				case "error":
+					errorCount++
+				}
+			}
+			if userCount != 2 || errorCount != 1 {
+				t.Errorf("expected 2 users and 1 error, got %v users and %v errors", userCount, errorCount)
+			}
+
+			summary := frames[4].Summary
+			if frames[4].Type != "summary" || summary == nil {
+				t.Fatalf("expected a summary frame last, got %v", frames[4])
+			}
+			if summary.UserCount != 2 || summary.ErrorCount != 2 || strings.Join(summary.Logins, ",") != "a,b" {
+				t.Errorf("unexpected summary %+v", summary)
+			}
+		})
+	}
+}
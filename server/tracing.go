@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TRACER_NAME instrumentation name of the spans created by the server
+	TRACER_NAME = "machshipgithubapi/server"
+	// TRACING_SERVICE_NAME service name of the exported spans, overridden by OTEL_SERVICE_NAME
+	TRACING_SERVICE_NAME = "machshipgithubapi"
+)
+
+// tracePropagator propagate the W3C trace context and baggage from the inbound requests to the github API calls
+var tracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// newTracerProvider return the tracer provider of the server, the spans are exported with OTLP over HTTP when an endpoint is configured
+func (s *Server) newTracerProvider() *sdktrace.TracerProvider {
+	options := make([]sdktrace.TracerProviderOption, 0)
+	tracingResource, err := resource.New(context.Background(),
+		resource.WithAttributes(semconv.ServiceName(TRACING_SERVICE_NAME)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		s.logger.warn("encounter err when detecting the tracing resource", logFields{"err": err})
+	}
+	if tracingResource != nil {
+		options = append(options, sdktrace.WithResource(tracingResource))
+	}
+
+	if s.config.otlpEndpoint != "" {
+		exporter, err := newOTLPExporter(s.config.otlpEndpoint)
+		if err != nil {
+			s.logger.error("encounter err when creating the OTLP exporter, spans are not exported", logFields{"endpoint": s.config.otlpEndpoint, "err": err})
+		} else {
+			options = append(options, sdktrace.WithBatcher(exporter))
+		}
+	}
+	return sdktrace.NewTracerProvider(options...)
+}
+
+// newOTLPExporter return an OTLP over HTTP exporter sending the spans to the /v1/traces path of the endpoint (http:// endpoints are called without TLS)
+func newOTLPExporter(endpoint string) (*otlptrace.Exporter, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpointURL.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(endpointURL.Path, "/") + "/v1/traces"),
+	}
+	if endpointURL.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(context.Background(), options...)
+}
+
+// tracedHandler start a server span for each request of the route, continuing the trace of the traceparent header
+func (s *Server) tracedHandler(route string, handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, route,
+		otelhttp.WithTracerProvider(s.tracerProvider),
+		otelhttp.WithPropagators(tracePropagator),
+		otelhttp.WithSpanOptions(trace.WithAttributes(semconv.HTTPRoute(route))),
+		otelhttp.WithSpanNameFormatter(func(route string, r *http.Request) string {
+			return r.Method + " " + route
+		}),
+	)
+}
+
+// githubTransport carry the context of the request a github client is created for, the github API calls are traced as children of its span
+type githubTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+// RoundTrip comply with http.RoundTripper interface
+func (t *githubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req)
+}
+
+// newGithubClient return the client calling github API on behalf of a request, each call start a client span and send the traceparent header
+func (s *Server) newGithubClient(ctx context.Context) *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &githubTransport{
+			ctx: ctx,
+			next: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithTracerProvider(s.tracerProvider),
+				otelhttp.WithPropagators(tracePropagator),
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "github " + r.Method
+				}),
+			),
+		},
+	}
+}
+
+// githubClientContext return the context of the request the client is created for (background for the other clients)
+func githubClientContext(client *http.Client) context.Context {
+	if transport, ok := client.Transport.(*githubTransport); ok {
+		return transport.ctx
+	}
+	return context.Background()
+}
+
+// tracedCacheGet get the cache value by key within a span recording the cache, the partition and whether the lookup hit, the lookup is counted in the request log
+func tracedCacheGet[T ICacheable](ctx context.Context, tracer trace.Tracer, cacheName string, cache *ServerCache[T], key string) *T {
+	_, span := tracer.Start(ctx, "cache.get "+cacheName, trace.WithAttributes(
+		attribute.String("cache.name", cacheName),
+		attribute.String("cache.key", key),
+		attribute.String("cache.partition", cache.PartitionID(key)),
+	))
+	defer span.End()
+
+	value := cache.Get(key)
+	span.SetAttributes(attribute.Bool("cache.hit", value != nil))
+	requestLogFromContext(ctx).countCacheLookup(value != nil)
+	return value
+}
+
+// traceGraphQLOperation record the name and type of the GraphQL operation on the span of the request
+func (s *Server) traceGraphQLOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	operationContext := graphql.GetOperationContext(ctx)
+	attributes := []attribute.KeyValue{attribute.String("graphql.operation.name", operationContext.OperationName)}
+	if operationContext.Operation != nil {
+		attributes = append(attributes, attribute.String("graphql.operation.type", string(operationContext.Operation.Operation)))
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attributes...)
+	return next(ctx)
+}
+
+// traceGraphQLResolver start a span for each resolver of the GraphQL operation, the REST handlers called by the resolver are traced as its children
+func (s *Server) traceGraphQLResolver(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fieldContext := graphql.GetFieldContext(ctx)
+	if fieldContext == nil || !fieldContext.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := s.tracer.Start(ctx, "graphql.resolve "+fieldContext.Object+"."+fieldContext.Field.Name, trace.WithAttributes(
+		attribute.String("graphql.field.parent", fieldContext.Object),
+		attribute.String("graphql.field.name", fieldContext.Field.Name),
+		attribute.String("graphql.field.path", fieldContext.Path().String()),
+	))
+	defer span.End()
+
+	result, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return result, err
+}
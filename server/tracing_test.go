@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	testTraceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testTraceParent  = "00-" + testTraceID + "-00f067aa0ba902b7-01"
+	testParentSpanID = "00f067aa0ba902b7"
+)
+
+// spanAttribute return the value of the span attribute (empty if there is none)
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, eachAttribute := range span.Attributes() {
+		if eachAttribute.Key == key {
+			return eachAttribute.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracing(t *testing.T) {
+	tests := map[string]struct {
+		Method            string
+		Target            string
+		Body              string
+		ExpectedSpanNames []string
+		ExpectedRootSpan  string
+	}{
+		"Test REST request": {
+			Method:            http.MethodGet,
+			Target:            "/v1/retrieveUsers?usernames=a",
+			ExpectedSpanNames: []string{"GET /v1/retrieveUsers", "cache.get user_info", "github GET"},
+			ExpectedRootSpan:  "GET /v1/retrieveUsers",
+		},
+		"Test GraphQL request": {
+			Method:            http.MethodPost,
+			Target:            "/graphql/query",
+			Body:              `{"query":"{retrieveUsers(usernames:[\"a\"]){users{login}}}"}`,
+			ExpectedSpanNames: []string{"POST /graphql/query", "graphql.resolve Query.retrieveUsers", "cache.get user_info", "github GET"},
+			ExpectedRootSpan:  "POST /graphql/query",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			lock := &sync.Mutex{}
+			upstreamTraceParents := make([]string, 0)
+			githubAPITestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lock.Lock()
+				upstreamTraceParents = append(upstreamTraceParents, r.Header.Get("traceparent"))
+				lock.Unlock()
+				jsonString, _ := json.Marshal(model.GithubUserInfo{Login: "a"})
+				w.Write(jsonString)
+			}))
+			defer githubAPITestServer.Close()
+
+			s := NewServer(NewServerConfig("", 8777, githubAPITestServer.URL, "users"))
+			spanRecorder := tracetest.NewSpanRecorder()
+			s.tracerProvider.RegisterSpanProcessor(spanRecorder)
+			s.registerHandlers()
+
+			request := httptest.NewRequest(test.Method, test.Target, strings.NewReader(test.Body))
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set("traceparent", testTraceParent)
+			responseRecorder := httptest.NewRecorder()
+			s.serverMux.ServeHTTP(responseRecorder, request)
+			if responseRecorder.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v: %s", http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+			}
+
+			spans := make(map[string]sdktrace.ReadOnlySpan)
+			for _, eachSpan := range spanRecorder.Ended() {
+				if eachSpan.SpanContext().TraceID().String() != testTraceID {
+					t.Errorf("expected span %q in trace %v, got %v", eachSpan.Name(), testTraceID, eachSpan.SpanContext().TraceID())
+				}
+				spans[eachSpan.Name()] = eachSpan
+			}
+			for _, eachName := range test.ExpectedSpanNames {
+				if _, ok := spans[eachName]; !ok {
+					t.Errorf("expected span %q, got %v", eachName, spanRecorder.Ended())
+				}
+			}
+
+			if rootSpan, ok := spans[test.ExpectedRootSpan]; ok && rootSpan.Parent().SpanID().String() != testParentSpanID {
+				t.Errorf("expected %q to continue the inbound trace, got parent %v", test.ExpectedRootSpan, rootSpan.Parent().SpanID())
+			}
+			if cacheSpan, ok := spans["cache.get user_info"]; ok {
+				if hit := spanAttribute(cacheSpan, "cache.hit"); hit != "false" {
+					t.Errorf("expected cache miss, got cache.hit %q", hit)
+				}
+				if partition := spanAttribute(cacheSpan, "cache.partition"); partition != s.githubUserInfoCache.PartitionID("a") {
+					t.Errorf("expected cache partition %v, got %q", s.githubUserInfoCache.PartitionID("a"), partition)
+				}
+			}
+			if githubSpan, ok := spans["github GET"]; ok {
+				if len(upstreamTraceParents) != 1 || !strings.Contains(upstreamTraceParents[0], githubSpan.SpanContext().SpanID().String()) {
+					t.Errorf("expected traceparent of the github span, got %v", upstreamTraceParents)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"machshipgithubapi/graph/model"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// GITHUB_UPSTREAM_REST fetch users with one REST API call per user
+	GITHUB_UPSTREAM_REST = "rest"
+	// GITHUB_UPSTREAM_GRAPHQL fetch users in batches with the GraphQL v4 API (require a token)
+	GITHUB_UPSTREAM_GRAPHQL = "graphql"
+)
+
+// userFetchResult result of fetching a user from upstream, user not found is returned with the github message
+type userFetchResult struct {
+	userInfo *model.GithubUserInfo
+	err      error
+}
+
+// githubUserFetcher fetch user profiles from github
+type githubUserFetcher interface {
+	// fetchUsers return the fetch result of every username
+	fetchUsers(client *http.Client, usernames []string) map[string]*userFetchResult
+	// batchSize return the number of users fetched together by a single upstream call
+	batchSize() int
+}
+
+// newGithubUserFetcher return the user fetcher selected by the configuration, GraphQL falls back to REST when no token is configured
+func (s *Server) newGithubUserFetcher() githubUserFetcher {
+	if s.config.githubUpstream == GITHUB_UPSTREAM_GRAPHQL {
+		if s.config.githubToken != "" {
+			return &graphqlUserFetcher{
+				server: s,
+			}
+		}
+		s.logger.warn("github GraphQL upstream require a token, falling back to REST upstream", nil)
+	}
+	return &restUserFetcher{
+		server: s,
+	}
+}
+
+// restUserFetcher fetch users with one REST API call per user
+type restUserFetcher struct {
+	server *Server
+}
+
+// fetchUsers comply with githubUserFetcher interface
+func (f *restUserFetcher) fetchUsers(client *http.Client, usernames []string) map[string]*userFetchResult {
+	userFetchResults := make(map[string]*userFetchResult)
+	for _, eachUsername := range usernames {
+		userInfo, err := f.fetchUser(client, eachUsername)
+		userFetchResults[eachUsername] = &userFetchResult{
+			userInfo: userInfo,
+			err:      err,
+		}
+	}
+	return userFetchResults
+}
+
+// batchSize comply with githubUserFetcher interface, REST fetch one user per call
+func (f *restUserFetcher) batchSize() int {
+	return 1
+}
+
+// fetchUser fetch a single user with the REST API
+func (f *restUserFetcher) fetchUser(client *http.Client, username string) (*model.GithubUserInfo, error) {
+	apiURL := fmt.Sprintf("%s/%s/%s", f.server.config.githubAPIURL, f.server.config.githubAPIUser, url.PathEscape(username))
+	req, err := f.server.newGithubRequest(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.server.doGithubRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// User not found is answered with a message, other failures should not be mistaken for a user
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return nil, &githubAPIError{
+			StatusCode: resp.StatusCode,
+			URL:        apiURL,
+		}
+	}
+
+	userInfo := &model.GithubUserInfo{}
+	err = json.Unmarshal(responseData, &userInfo)
+	if err != nil {
+		return nil, err
+	}
+	return userInfo, nil
+}